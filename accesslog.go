@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// accessLog writes a line per request in the Combined Log Format (followed
+// by the latency in milliseconds and the go-get flag) or as JSON objects.
+type accessLog struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+}
+
+func newAccessLog(dst, format string) (*accessLog, error) {
+	if format != "common" && format != "json" {
+		return nil, fmt.Errorf("invalid access log format %q", format)
+	}
+
+	var w io.Writer = os.Stdout
+
+	if dst != "-" {
+		f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		w = f
+	}
+
+	return &accessLog{w: w, format: format}, nil
+}
+
+type accessEntry struct {
+	Time      time.Time `json:"time"`
+	Remote    string    `json:"remote"`
+	Method    string    `json:"method"`
+	Host      string    `json:"host"`
+	URI       string    `json:"uri"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	LatencyMS float64   `json:"latency_ms"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent"`
+	GoGet     bool      `json:"go_get"`
+}
+
+func newAccessEntry(r *http.Request, sw *statusWriter, start time.Time) accessEntry {
+	remote := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(remote); err == nil {
+		remote = h
+	}
+
+	return accessEntry{
+		Time:      start,
+		Remote:    remote,
+		Method:    r.Method,
+		Host:      r.Host,
+		URI:       r.RequestURI,
+		Proto:     r.Proto,
+		Status:    sw.status,
+		Bytes:     sw.bytes,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+		GoGet:     requestClient(r) == "go-get",
+	}
+}
+
+func (l *accessLog) log(e accessEntry) {
+	var line []byte
+
+	if l.format == "json" {
+		line, _ = json.Marshal(e)
+		line = append(line, '\n')
+	} else {
+		referer := e.Referer
+		if referer == "" {
+			referer = "-"
+		}
+
+		line = fmt.Appendf(nil, "%s - - [%s] %q %d %d %q %q %.3f go-get=%t\n",
+			e.Remote, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+			e.Method+" "+e.URI+" "+e.Proto, e.Status, e.Bytes,
+			referer, e.UserAgent, e.LatencyMS, e.GoGet,
+		)
+	}
+
+	l.mu.Lock()
+	l.w.Write(line)
+	l.mu.Unlock()
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const letsEncryptURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+// certManager obtains and renews a certificate for the given domains from
+// an ACME (RFC 8555) certificate authority, using HTTP-01 challenges.
+// Certificates and the account key are cached in a directory.
+type certManager struct {
+	directory string
+	email     string
+	domains   []string
+	cacheDir  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+
+	tokens sync.Map // Challenge token -> key authorization.
+}
+
+func newCertManager(directory, email, cacheDir string, domains []string) (*certManager, error) {
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return nil, err
+	}
+
+	m := &certManager{
+		directory: directory,
+		email:     email,
+		domains:   domains,
+		cacheDir:  cacheDir,
+	}
+
+	if cert, err := m.loadCert(); err == nil {
+		m.cert = cert
+	}
+
+	return m, nil
+}
+
+func (m *certManager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.cert == nil {
+		return nil, errors.New("acme: certificate not available yet")
+	}
+
+	return m.cert, nil
+}
+
+// HTTPHandler answers HTTP-01 challenges and passes any other request to
+// fallback.
+func (m *certManager) HTTPHandler(fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.URL.Path, "/.well-known/acme-challenge/")
+		if !ok {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+
+		keyAuth, ok := m.tokens.Load(token)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, keyAuth.(string))
+	})
+}
+
+// renewBefore is how long before expiration certificates are renewed.
+const renewBefore = 30 * 24 * time.Hour
+
+// Run obtains a certificate if there is no valid one, and keeps renewing it
+// until stop is closed.
+func (m *certManager) Run(stop <-chan struct{}) {
+	for {
+		if m.needsRenewal() {
+			if err := m.renew(); err != nil {
+				log.Printf("acme: %v", err)
+			} else {
+				log.Printf("acme: obtained certificate for %s", strings.Join(m.domains, ", "))
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Hour):
+		}
+	}
+}
+
+func (m *certManager) needsRenewal() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.cert == nil || time.Until(m.cert.Leaf.NotAfter) < renewBefore
+}
+
+func (m *certManager) certFile() string {
+	return filepath.Join(m.cacheDir, strings.Join(m.domains, "+")+".pem")
+}
+
+func (m *certManager) loadCert() (*tls.Certificate, error) {
+	data, err := os.ReadFile(m.certFile())
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, d := range m.domains {
+		if err := cert.Leaf.VerifyHostname(d); err != nil {
+			return nil, err
+		}
+	}
+
+	return &cert, nil
+}
+
+func (m *certManager) renew() error {
+	accountKey, err := loadOrCreateKey(filepath.Join(m.cacheDir, "acme_account.key"))
+	if err != nil {
+		return err
+	}
+
+	c, err := newACMEClient(m.directory, accountKey)
+	if err != nil {
+		return err
+	}
+
+	if err := c.register(m.email); err != nil {
+		return err
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	chain, err := c.obtain(m.domains, certKey, &m.tokens)
+	if err != nil {
+		return err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(certKey)
+	if err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	data = append(data, chain...)
+
+	if err := os.WriteFile(m.certFile(), data, 0600); err != nil {
+		return err
+	}
+
+	cert, err := m.loadCert()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.cert = cert
+	m.mu.Unlock()
+
+	return nil
+}
+
+func loadOrCreateKey(name string) (*ecdsa.PrivateKey, error) {
+	if data, err := os.ReadFile(name); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s: invalid key", name)
+		}
+
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+
+	return key, os.WriteFile(name, data, 0600)
+}
+
+type acmeClient struct {
+	key   *ecdsa.PrivateKey
+	kid   string
+	nonce string
+
+	dir struct {
+		NewNonce   string `json:"newNonce"`
+		NewAccount string `json:"newAccount"`
+		NewOrder   string `json:"newOrder"`
+	}
+}
+
+func newACMEClient(directory string, key *ecdsa.PrivateKey) (*acmeClient, error) {
+	c := &acmeClient{key: key}
+
+	res, err := http.Get(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("acme: GET %s: %s", directory, res.Status)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&c.dir); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *acmeClient) register(email string) error {
+	payload := map[string]any{"termsOfServiceAgreed": true}
+	if email != "" {
+		payload["contact"] = []string{"mailto:" + email}
+	}
+
+	res, err := c.post(c.dir.NewAccount, payload, nil)
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+	c.kid = res.Header.Get("Location")
+
+	return nil
+}
+
+type acmeOrder struct {
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate"`
+}
+
+// obtain orders a certificate for the given domains and returns its PEM
+// encoded chain. Challenge tokens are stored in tokens while the order is
+// processed.
+func (c *acmeClient) obtain(domains []string, key crypto.Signer, tokens *sync.Map) ([]byte, error) {
+	var ids []map[string]string
+	for _, d := range domains {
+		ids = append(ids, map[string]string{"type": "dns", "value": d})
+	}
+
+	var order acmeOrder
+
+	res, err := c.post(c.dir.NewOrder, map[string]any{"identifiers": ids}, &order)
+	if err != nil {
+		return nil, err
+	}
+
+	orderURL := res.Header.Get("Location")
+
+	for _, authzURL := range order.Authorizations {
+		if err := c.authorize(authzURL, tokens); err != nil {
+			return nil, err
+		}
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	csrPayload := map[string]string{"csr": b64(csr)}
+	if _, err := c.post(order.Finalize, csrPayload, &order); err != nil {
+		return nil, err
+	}
+
+	for i := 0; order.Status != "valid"; i++ {
+		if order.Status == "invalid" || i == 30 {
+			return nil, fmt.Errorf("acme: order %s", order.Status)
+		}
+
+		time.Sleep(2 * time.Second)
+
+		if _, err := c.post(orderURL, nil, &order); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err = c.post(order.Certificate, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	return io.ReadAll(res.Body)
+}
+
+func (c *acmeClient) authorize(authzURL string, tokens *sync.Map) error {
+	var authz struct {
+		Status     string `json:"status"`
+		Challenges []struct {
+			Type  string `json:"type"`
+			URL   string `json:"url"`
+			Token string `json:"token"`
+		} `json:"challenges"`
+	}
+
+	if _, err := c.post(authzURL, nil, &authz); err != nil {
+		return err
+	}
+
+	if authz.Status == "valid" {
+		return nil
+	}
+
+	for _, ch := range authz.Challenges {
+		if ch.Type != "http-01" {
+			continue
+		}
+
+		tokens.Store(ch.Token, ch.Token+"."+jwkThumbprint(&c.key.PublicKey))
+		defer tokens.Delete(ch.Token)
+
+		res, err := c.post(ch.URL, struct{}{}, nil)
+		if err != nil {
+			return err
+		}
+
+		res.Body.Close()
+
+		for i := 0; authz.Status != "valid"; i++ {
+			if authz.Status == "invalid" || i == 30 {
+				return fmt.Errorf("acme: authorization %s", authz.Status)
+			}
+
+			time.Sleep(2 * time.Second)
+
+			if _, err := c.post(authzURL, nil, &authz); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return errors.New("acme: no http-01 challenge offered")
+}
+
+// post sends a JWS signed request. A nil payload sends a POST-as-GET
+// request. If v is not nil, the response body is decoded into it.
+func (c *acmeClient) post(url string, payload, v any) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		res, err := c.doPost(url, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode >= 400 {
+			var problem struct {
+				Type   string `json:"type"`
+				Detail string `json:"detail"`
+			}
+
+			json.NewDecoder(res.Body).Decode(&problem)
+			res.Body.Close()
+
+			if problem.Type == "urn:ietf:params:acme:error:badNonce" && retry < 3 {
+				continue
+			}
+
+			return nil, fmt.Errorf("acme: POST %s: %s: %s", url, res.Status, problem.Detail)
+		}
+
+		if v != nil {
+			defer res.Body.Close()
+
+			if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+				return nil, err
+			}
+		}
+
+		return res, nil
+	}
+}
+
+func (c *acmeClient) doPost(url string, payload any) (*http.Response, error) {
+	if c.nonce == "" {
+		res, err := http.Head(c.dir.NewNonce)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Body.Close()
+		c.nonce = res.Header.Get("Replay-Nonce")
+	}
+
+	protected := map[string]any{"alg": "ES256", "nonce": c.nonce, "url": url}
+	if c.kid != "" {
+		protected["kid"] = c.kid
+	} else {
+		protected["jwk"] = jwk(&c.key.PublicKey)
+	}
+
+	header, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if payload != nil {
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	signingInput := b64(header) + "." + b64(body)
+	hash := sha256.Sum256([]byte(signingInput))
+
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	jws, err := json.Marshal(map[string]string{
+		"protected": b64(header),
+		"payload":   b64(body),
+		"signature": b64(sig),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.Post(url, "application/jose+json", bytes.NewReader(jws))
+	if err != nil {
+		return nil, err
+	}
+
+	c.nonce = res.Header.Get("Replay-Nonce")
+
+	return res, nil
+}
+
+func jwk(pub *ecdsa.PublicKey) map[string]string {
+	k, err := pub.ECDH()
+	if err != nil {
+		panic(err)
+	}
+
+	// Uncompressed point, 0x04 || x || y.
+	p := k.Bytes()
+
+	return map[string]string{"crv": "P-256", "kty": "EC", "x": b64(p[1:33]), "y": b64(p[33:])}
+}
+
+// jwkThumbprint returns the RFC 7638 thumbprint of the key, for which
+// members must be in lexicographical order.
+func jwkThumbprint(pub *ecdsa.PublicKey) string {
+	k := jwk(pub)
+	s := fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, k["crv"], k["kty"], k["x"], k["y"])
+	sum := sha256.Sum256([]byte(s))
+
+	return b64(sum[:])
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
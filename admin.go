@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// adminHandler serves the admin API:
+//
+//	POST /-/rebuild         reloads the configuration and regenerates all.
+//	POST /-/rebuild/<repo>  regenerates the repository with the given name,
+//	                        leaving the site files (see Site) as they are.
+//
+// Requests must be authenticated with the admin token as a bearer token,
+// or with the token of a team for regenerating its repositories (see
+// Team). Responses are the generation summary as JSON.
+type adminHandler struct {
+	s     *Server
+	token []byte
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	var team *Team // Nil for the admin token.
+
+	if subtle.ConstantTimeCompare([]byte(token), h.token) != 1 {
+		team = h.s.g.state.Load().cfg.teamOf([]byte(token))
+	}
+
+	if team == nil && subtle.ConstantTimeCompare([]byte(token), h.token) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="vanitic"`)
+		writeJSONError(w, http.StatusUnauthorized, "invalid token")
+
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+
+		return
+	}
+
+	var (
+		sum *Summary
+		err error
+	)
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/-/rebuild"), "/")
+
+	if name == "" {
+		if team != nil {
+			writeJSONError(w, http.StatusForbidden, "teams can only regenerate their repositories")
+			return
+		}
+
+		sum, err = h.s.Reload()
+	} else {
+		repos := h.repos(name, team)
+		if repos == nil {
+			writeJSONError(w, http.StatusNotFound, "repository not configured")
+			return
+		}
+
+		sum, err = h.s.g.GenerateRepos(repos)
+	}
+
+	switch {
+	case errors.Is(err, errReloading):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	case sum == nil:
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, sum)
+	default:
+		writeJSON(w, http.StatusOK, sum)
+	}
+}
+
+// repos returns the modules of the repository with the given name, only
+// those of the team if not nil.
+func (h *adminHandler) repos(name string, team *Team) []*Repo {
+	var repos []*Repo
+
+	for _, r := range h.s.g.Repos() {
+		if repoName(r.URL) == name && (team == nil || r.Team == team.Name) {
+			repos = append(repos, r)
+		}
+	}
+
+	return repos
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
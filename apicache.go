@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// apiCache keeps the responses of the discovery APIs by URL, for
+// conditional requests (which don't count against the GitHub rate limits)
+// and for listing repositories when an API is rate limited or the request
+// budget of the discovery runs over. Responses are kept in memory across
+// reloads, and in a directory if any (see Options.DiscoveryCache).
+type apiCache struct {
+	dir     string
+	ttl     time.Duration // Responses younger than it are used without requests.
+	budget  int           // Requests per discovery run, 0 for no limit.
+	maxWait time.Duration // Longest wait for a rate limit reset.
+
+	mu       sync.Mutex
+	entries  map[string]*apiResponse
+	requests int // Of the discovery run.
+}
+
+type apiResponse struct {
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	Link         []string        `json:"link,omitempty"`
+	Body         json.RawMessage `json:"body"`
+	Time         time.Time       `json:"time"`
+}
+
+func newAPICache(opts *Options) *apiCache {
+	return &apiCache{
+		dir:     opts.DiscoveryCache,
+		ttl:     opts.DiscoveryTTL,
+		budget:  opts.DiscoveryBudget,
+		maxWait: opts.DiscoveryMaxWait,
+		entries: make(map[string]*apiResponse),
+	}
+}
+
+// reset starts a discovery run.
+func (c *apiCache) reset() {
+	c.mu.Lock()
+	c.requests = 0
+	c.mu.Unlock()
+}
+
+// spend counts a request of the discovery run, and reports if it is within
+// the budget.
+func (c *apiCache) spend() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.budget > 0 && c.requests >= c.budget {
+		return false
+	}
+
+	c.requests++
+
+	return true
+}
+
+// cacheKey identifies the responses of an URL with the given credentials,
+// which may see different repositories. Credentials aren't stored.
+func cacheKey(rawURL, auth string) string {
+	sum := sha256.Sum256([]byte(rawURL + "\x00" + auth))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *apiCache) load(key string) *apiResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r := c.entries[key]; r != nil || c.dir == "" {
+		return r
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+	if err != nil {
+		return nil
+	}
+
+	var r apiResponse
+	if json.Unmarshal(data, &r) != nil {
+		return nil
+	}
+
+	c.entries[key] = &r
+
+	return &r
+}
+
+func (c *apiCache) store(key string, r *apiResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = r
+
+	if c.dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(c.dir, key+".json"), data, 0600)
+}
+
+// rateLimitWait returns how long to wait before retrying a rate limited
+// response, from its Retry-After header, or the reset time of the GitHub
+// (X-RateLimit-Reset) and GitLab (RateLimit-Reset) exhausted limits.
+func rateLimitWait(res *http.Response) (time.Duration, bool) {
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusForbidden {
+		return 0, false
+	}
+
+	if v := res.Header.Get("Retry-After"); v != "" {
+		if s, err := strconv.Atoi(v); err == nil {
+			return time.Duration(s) * time.Second, true
+		}
+
+		if t, err := http.ParseTime(v); err == nil {
+			return max(time.Until(t), 0), true
+		}
+	}
+
+	for _, h := range []string{"X-RateLimit", "RateLimit"} {
+		if res.Header.Get(h+"-Remaining") != "0" {
+			continue
+		}
+
+		if reset, err := strconv.ParseInt(res.Header.Get(h+"-Reset"), 10, 64); err == nil {
+			return max(time.Until(time.Unix(reset, 0)), 0), true
+		}
+	}
+
+	// Secondary limits of GitHub may not tell when to retry.
+	return time.Minute, res.StatusCode == http.StatusTooManyRequests
+}
+
+var errRateLimited = errors.New("rate limited")
+
+// stale returns the cached response of a request that can't be done, or
+// the reason as an error.
+func (r *apiResponse) stale(rawURL string, reason error) (*apiResponse, error) {
+	if r == nil {
+		return nil, fmt.Errorf("GET %s: %w", rawURL, reason)
+	}
+
+	log.Printf("discovery: GET %s: %v, using the response of %s", rawURL, reason, r.Time.Format(time.RFC3339))
+
+	return r, nil
+}
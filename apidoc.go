@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PackageDoc is the exported API of a package, as shown by godoc.
+type PackageDoc struct {
+	Doc    template.HTML
+	Consts []DocDecl
+	Vars   []DocDecl
+	Funcs  []DocDecl
+	Types  []DocType
+
+	Examples []DocExample
+	Symbols  []DocSymbol
+}
+
+// DocSymbol is an exported type or function and its anchor in the
+// pkg.go.dev documentation, like Type.Method.
+type DocSymbol struct {
+	Kind   string // "type", "func" or "method".
+	Name   string
+	Anchor string
+}
+
+// DocExample is an Example function from the package tests.
+type DocExample struct {
+	Name   string // Documented identifier and suffix, like "Type.Method (suffix)".
+	ID     string
+	Doc    template.HTML
+	Code   string
+	Output string
+}
+
+// DocDecl is a declaration and its doc comment.
+type DocDecl struct {
+	Name string
+	Decl string // Formatted source, without function bodies.
+	Doc  template.HTML
+}
+
+type DocType struct {
+	DocDecl
+	Consts  []DocDecl
+	Vars    []DocDecl
+	Funcs   []DocDecl // Constructors.
+	Methods []DocDecl
+}
+
+// readPackageDoc parses the Go files of the package in dir and returns its
+// documentation. Test files are only parsed for examples.
+func readPackageDoc(dir, importPath string, opts *Options) (*PackageDoc, error) {
+	api, examples := opts.API, opts.Examples
+
+	fset := token.NewFileSet()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*ast.File
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || !examples && strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, f)
+	}
+
+	p, err := doc.NewFromFiles(fset, files, importPath)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &docRenderer{fset: fset, pkg: p}
+	pd := &PackageDoc{}
+
+	if examples {
+		pd.Examples = r.examples("", p.Examples)
+
+		for _, f := range p.Funcs {
+			pd.Examples = append(pd.Examples, r.examples(f.Name, f.Examples)...)
+		}
+
+		for _, t := range p.Types {
+			pd.Examples = append(pd.Examples, r.examples(t.Name, t.Examples)...)
+
+			for _, f := range t.Funcs {
+				pd.Examples = append(pd.Examples, r.examples(f.Name, f.Examples)...)
+			}
+
+			for _, m := range t.Methods {
+				pd.Examples = append(pd.Examples, r.examples(t.Name+"."+m.Name, m.Examples)...)
+			}
+		}
+	}
+
+	if opts.Symbols {
+		pd.Symbols = symbols(p)
+	}
+
+	if !api {
+		return pd, nil
+	}
+
+	pd = &PackageDoc{
+		Examples: pd.Examples,
+		Symbols:  pd.Symbols,
+		Doc:      r.html(p.Doc),
+		Consts:   r.values(p.Consts),
+		Vars:     r.values(p.Vars),
+		Funcs:    r.funcs(p.Funcs),
+	}
+
+	for _, t := range p.Types {
+		pd.Types = append(pd.Types, DocType{
+			DocDecl: r.decl(t.Name, t.Decl, t.Doc),
+			Consts:  r.values(t.Consts),
+			Vars:    r.values(t.Vars),
+			Funcs:   r.funcs(t.Funcs),
+			Methods: r.funcs(t.Methods),
+		})
+	}
+
+	return pd, nil
+}
+
+type docRenderer struct {
+	fset *token.FileSet
+	pkg  *doc.Package
+}
+
+func (r *docRenderer) html(text string) template.HTML {
+	return template.HTML(r.pkg.HTML(text))
+}
+
+func (r *docRenderer) decl(name string, node any, text string) DocDecl {
+	var buf bytes.Buffer
+
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	cfg.Fprint(&buf, r.fset, node)
+
+	return DocDecl{Name: name, Decl: buf.String(), Doc: r.html(text)}
+}
+
+func (r *docRenderer) examples(name string, examples []*doc.Example) []DocExample {
+	var docs []DocExample
+
+	for _, ex := range examples {
+		var buf bytes.Buffer
+
+		cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+
+		if ex.Play != nil {
+			cfg.Fprint(&buf, r.fset, ex.Play)
+		} else {
+			cfg.Fprint(&buf, r.fset, ex.Code)
+		}
+
+		code := buf.String()
+
+		// Blocks are printed with their braces and indented.
+		if ex.Play == nil {
+			if s, ok := strings.CutPrefix(code, "{"); ok {
+				s = strings.TrimSuffix(strings.TrimSpace(s), "}")
+				code = strings.ReplaceAll(strings.TrimSpace(s), "\n\t", "\n")
+			}
+		}
+
+		display, id := name, "example-"+name
+		if display == "" {
+			display, id = "Package", "example-package"
+		}
+
+		if ex.Suffix != "" {
+			display += " (" + ex.Suffix + ")"
+			id += "-" + ex.Suffix
+		}
+
+		docs = append(docs, DocExample{
+			Name:   display,
+			ID:     id,
+			Doc:    r.html(ex.Doc),
+			Code:   code,
+			Output: ex.Output,
+		})
+	}
+
+	return docs
+}
+
+func (r *docRenderer) values(values []*doc.Value) []DocDecl {
+	var decls []DocDecl
+
+	for _, v := range values {
+		decls = append(decls, r.decl(strings.Join(v.Names, ", "), v.Decl, v.Doc))
+	}
+
+	return decls
+}
+
+func (r *docRenderer) funcs(funcs []*doc.Func) []DocDecl {
+	var decls []DocDecl
+
+	for _, f := range funcs {
+		decl := *f.Decl
+		decl.Body = nil
+		decl.Doc = nil
+
+		name := f.Name
+		if f.Recv != "" {
+			name = strings.TrimPrefix(f.Recv, "*") + "." + f.Name
+		}
+
+		decls = append(decls, r.decl(name, &decl, f.Doc))
+	}
+
+	return decls
+}
+
+// symbols returns the exported functions and types of the package, with
+// their constructors and methods after them, in godoc order.
+func symbols(p *doc.Package) []DocSymbol {
+	var syms []DocSymbol
+
+	for _, f := range p.Funcs {
+		syms = append(syms, DocSymbol{Kind: "func", Name: f.Name, Anchor: f.Name})
+	}
+
+	for _, t := range p.Types {
+		syms = append(syms, DocSymbol{Kind: "type", Name: t.Name, Anchor: t.Name})
+
+		for _, f := range t.Funcs {
+			syms = append(syms, DocSymbol{Kind: "func", Name: f.Name, Anchor: f.Name})
+		}
+
+		for _, m := range t.Methods {
+			name := t.Name + "." + m.Name
+			syms = append(syms, DocSymbol{Kind: "method", Name: name, Anchor: name})
+		}
+	}
+
+	return syms
+}
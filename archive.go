@@ -0,0 +1,121 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteArchive writes the files of dir into a tar.gz (or .tgz) or zip
+// archive, by the extension of dst.
+func WriteArchive(dst, dir string) (err error) {
+	var add func(name string, fi fs.FileInfo, r io.Reader) error
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if e := f.Close(); err == nil {
+			err = e
+		}
+
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	switch {
+	case strings.HasSuffix(dst, ".tar.gz"), strings.HasSuffix(dst, ".tgz"):
+		gw := gzip.NewWriter(f)
+		tw := tar.NewWriter(gw)
+
+		defer func() {
+			if e := tw.Close(); err == nil {
+				err = e
+			}
+
+			if e := gw.Close(); err == nil {
+				err = e
+			}
+		}()
+
+		add = func(name string, fi fs.FileInfo, r io.Reader) error {
+			hdr, err := tar.FileInfoHeader(fi, "")
+			if err != nil {
+				return err
+			}
+
+			hdr.Name = name
+
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+
+			_, err = io.Copy(tw, r)
+
+			return err
+		}
+	case strings.HasSuffix(dst, ".zip"):
+		zw := zip.NewWriter(f)
+
+		defer func() {
+			if e := zw.Close(); err == nil {
+				err = e
+			}
+		}()
+
+		add = func(name string, fi fs.FileInfo, r io.Reader) error {
+			hdr, err := zip.FileInfoHeader(fi)
+			if err != nil {
+				return err
+			}
+
+			hdr.Name = name
+			hdr.Method = zip.Deflate
+
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(w, r)
+
+			return err
+		}
+	default:
+		return fmt.Errorf("archive: unsupported format %q", filepath.Base(dst))
+	}
+
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		r, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+
+		defer r.Close()
+
+		return add(filepath.ToSlash(rel), fi, r)
+	})
+}
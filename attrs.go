@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFile lists patterns of the repository paths left out of the
+// catalog, one per line like in .gitignore files. They are applied on top
+// of the export-ignore attributes, patterns starting with ! keep paths
+// ignored by those. Module zips are left as the Go toolchain builds them,
+// so their checksums match the public ones.
+const ignoreFile = ".vaniticignore"
+
+// ignoreAttrsHeader starts the info/attributes files written from the
+// ignore file by previous versions, which changed the module zips.
+const ignoreAttrsHeader = "# Generated by vanitic from " + ignoreFile + "."
+
+// removeIgnoreAttrs removes the info/attributes file of the checkout if it
+// was written from its ignore file.
+func (c cmdRunner) removeIgnoreAttrs(repo string) error {
+	output, err := c.runCmdOutput(repo, nil, "git", "rev-parse", "--git-path", "info/attributes")
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Join(repo, filepath.FromSlash(string(bytes.TrimSpace(output))))
+
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !bytes.HasPrefix(data, []byte(ignoreAttrsHeader)) {
+		return nil
+	}
+
+	return os.Remove(name)
+}
+
+// ignorePattern is a pattern of the ignore file.
+type ignorePattern struct {
+	elems  []string // Slash separated elements, ** matches any number.
+	base   bool     // Without slashes, matches the last element only.
+	negate bool
+}
+
+// readIgnoreFile returns the patterns of the ignore file of the checkout,
+// none without ignore file.
+func readIgnoreFile(repo string) ([]ignorePattern, error) {
+	data, err := os.ReadFile(filepath.Join(repo, ignoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var patterns []ignorePattern
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var p ignorePattern
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			line, p.negate = rest, true
+		}
+
+		if line = strings.TrimSuffix(line, "/"); line == "" {
+			continue
+		}
+
+		p.base = !strings.Contains(line, "/")
+		p.elems = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		patterns = append(patterns, p)
+	}
+
+	return patterns, nil
+}
+
+// match reports if the pattern matches the slash path p, relative to the
+// checkout.
+func (p ignorePattern) match(name string) bool {
+	elems := strings.Split(name, "/")
+	if p.base {
+		ok, _ := path.Match(p.elems[0], elems[len(elems)-1])
+		return ok
+	}
+
+	return matchElems(p.elems, elems)
+}
+
+func matchElems(pattern, elems []string) bool {
+	if len(pattern) == 0 {
+		return len(elems) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(elems); i++ {
+			if matchElems(pattern[1:], elems[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(elems) == 0 {
+		return false
+	}
+
+	if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+		return false
+	}
+
+	return matchElems(pattern[1:], elems[1:])
+}
+
+// exportAttrs are the paths of a checkout with the export-ignore and
+// export-subst attributes set, by slash path relative to the checkout.
+type exportAttrs struct {
+	ignore map[string]bool
+	subst  map[string]bool
+}
+
+// readExportAttrs returns the export attributes of the given paths, as git
+// archive sees them, with the paths of the ignore file as ignored.
+func (c cmdRunner) readExportAttrs(repo string, paths []string) (*exportAttrs, error) {
+	attrs := &exportAttrs{ignore: make(map[string]bool), subst: make(map[string]bool)}
+	if len(paths) == 0 {
+		return attrs, nil
+	}
+
+	args := append([]string{"git", "check-attr", "-z", "export-ignore", "export-subst", "--"}, paths...)
+
+	output, err := c.runCmdOutput(repo, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Path, attribute and value triplets.
+	fields := strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		if fields[i+2] != "set" {
+			continue
+		}
+
+		switch fields[i+1] {
+		case "export-ignore":
+			attrs.ignore[fields[i]] = true
+		case "export-subst":
+			attrs.subst[fields[i]] = true
+		}
+	}
+
+	patterns, err := readIgnoreFile(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last matching pattern wins.
+	for _, p := range paths {
+		for i := len(patterns) - 1; i >= 0; i-- {
+			if patterns[i].match(p) {
+				attrs.ignore[p] = !patterns[i].negate
+				break
+			}
+		}
+	}
+
+	return attrs, nil
+}
+
+// ignored reports if the package in dir with the given Go files is left
+// out of the catalog: dir or one of its parents is ignored, or every file
+// is.
+func (a *exportAttrs) ignored(dir string, files []string) bool {
+	for d := dir; d != "." && d != "/"; d = path.Dir(d) {
+		if a.ignore[d] {
+			return true
+		}
+	}
+
+	for _, f := range files {
+		if !a.ignore[path.Join(dir, f)] {
+			return false
+		}
+	}
+
+	return len(files) > 0
+}
+
+// substituted reports if any of the Go files of the package in dir has
+// export-subst placeholders expanded by git archive.
+func (a *exportAttrs) substituted(dir string, files []string) bool {
+	for _, f := range files {
+		if a.subst[path.Join(dir, f)] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exportPaths returns the paths checked for the package in dir: itself, its
+// parents and its files.
+func exportPaths(dir string, files []string) []string {
+	var paths []string
+
+	for d := dir; d != "." && d != "/"; d = path.Dir(d) {
+		paths = append(paths, d)
+	}
+
+	for _, f := range files {
+		paths = append(paths, path.Join(dir, f))
+	}
+
+	return paths
+}
+
+var formatRe = regexp.MustCompile(`\$Format:([^$\n]*)\$`)
+
+// expandSubst expands the $Format:...$ placeholders of text with the
+// pretty format of the checkout HEAD, like git archive does for files with
+// the export-subst attribute.
+func (c cmdRunner) expandSubst(repo, text string) string {
+	return formatRe.ReplaceAllStringFunc(text, func(m string) string {
+		format := formatRe.FindStringSubmatch(m)[1]
+
+		output, err := c.runCmdOutput(repo, nil, "git", "log", "-1", "--format="+format, "HEAD")
+		if err != nil {
+			return m
+		}
+
+		return strings.TrimSuffix(string(output), "\n")
+	})
+}
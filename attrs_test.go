@@ -0,0 +1,86 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestModuleZipIgnoreFile(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	repo := t.TempDir()
+
+	files := map[string]string{
+		"go.mod":          "module go.example.com/mod\n",
+		"mod.go":          "package mod\n",
+		"tools/tools.go":  "package tools\n",
+		"tools/keep/k.go": "package keep\n",
+		ignoreFile:        "tools\n!tools/keep\n",
+	}
+
+	for name, data := range files {
+		p := filepath.Join(repo, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var c cmdRunner
+
+	for _, args := range [][]string{
+		{"git", "init", "-q"},
+		{"git", "add", "."},
+		{"git", "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+		{"git", "tag", "v1.0.0"},
+	} {
+		if err := c.runCmd(repo, nil, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attrs, err := c.readExportAttrs(repo, exportPaths("tools/keep", []string{"k.go"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !attrs.ignored("tools", []string{"tools.go"}) {
+		t.Error("tools isn't ignored")
+	}
+
+	if attrs.ignore["tools/keep"] {
+		t.Error("tools/keep is ignored")
+	}
+
+	mod := &Module{Path: "go.example.com/mod", RepoDir: repo, cmd: c}
+
+	var buf bytes.Buffer
+	if err := mod.WriteZip(&buf, "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]bool)
+	for _, f := range zr.File {
+		got[f.Name] = true
+	}
+
+	for _, name := range []string{"tools/tools.go", "tools/keep/k.go", ignoreFile} {
+		if !got["go.example.com/mod@v1.0.0/"+name] {
+			t.Errorf("module zip doesn't have %s", name)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const azureVersion = "2021-08-06"
+
+// uploadAzure syncs the directory to an Azure Storage container, with URLs
+// like azure://account/container/prefix. The container defaults to $web,
+// the one served by the static website feature. Credentials are taken from
+// the AZURE_STORAGE_SAS_TOKEN or AZURE_STORAGE_KEY variables.
+func uploadAzure(u *url.URL, dir string, _ cmdRunner) error {
+	c := &azureClient{
+		account:   u.Host,
+		container: "$web",
+		sas:       strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"),
+	}
+
+	if key := os.Getenv("AZURE_STORAGE_KEY"); key != "" && c.sas == "" {
+		k, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return fmt.Errorf("azure: invalid AZURE_STORAGE_KEY: %w", err)
+		}
+
+		c.key = k
+	}
+
+	if c.sas == "" && c.key == nil {
+		return errors.New("azure: missing AZURE_STORAGE_SAS_TOKEN or AZURE_STORAGE_KEY")
+	}
+
+	p := strings.Trim(u.Path, "/")
+	if container, prefix, _ := strings.Cut(p, "/"); container != "" {
+		c.container = container
+		c.prefix = storagePrefix(&url.URL{Path: prefix})
+	}
+
+	remote, err := c.list()
+	if err != nil {
+		return err
+	}
+
+	local, err := localFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	upload, remove := syncPlan(local, remote, func(sum []byte) string {
+		return base64.StdEncoding.EncodeToString(sum)
+	})
+
+	for _, f := range upload {
+		if err := c.put(f); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range remove {
+		if err := c.delete(name); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("azure: %s: %d uploaded, %d deleted", u, len(upload), len(remove))
+
+	return nil
+}
+
+type azureClient struct {
+	account   string
+	container string
+	prefix    string
+
+	sas string
+	key []byte // Shared key, used if there is no SAS token.
+}
+
+func (c *azureClient) url(name string, q url.Values) string {
+	p := "/" + c.container
+	if name != "" {
+		for _, elem := range strings.Split(name, "/") {
+			p += "/" + url.PathEscape(elem)
+		}
+	}
+
+	query := q.Encode()
+	if c.sas != "" {
+		query = strings.TrimPrefix(query+"&"+c.sas, "&")
+	}
+
+	u := "https://" + c.account + ".blob.core.windows.net" + p
+	if query != "" {
+		u += "?" + query
+	}
+
+	return u
+}
+
+func (c *azureClient) do(req *http.Request, v any) error {
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureVersion)
+
+	if c.key != nil {
+		req.Header.Set("Authorization", "SharedKey "+c.account+":"+c.sign(req))
+	}
+
+	if v == nil {
+		return storageDo(req, nil)
+	}
+
+	req.Header.Set("User-Agent", "vanitic")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Redacted(), res.Status)
+	}
+
+	return xml.NewDecoder(res.Body).Decode(v)
+}
+
+// sign returns the Shared Key signature of the request.
+func (c *azureClient) sign(req *http.Request) string {
+	length := ""
+	if req.ContentLength > 0 {
+		length = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	h := req.Header
+	parts := []string{
+		req.Method,
+		h.Get("Content-Encoding"),
+		h.Get("Content-Language"),
+		length,
+		h.Get("Content-MD5"),
+		h.Get("Content-Type"),
+		"", // Date, x-ms-date is used instead.
+		h.Get("If-Modified-Since"),
+		h.Get("If-Match"),
+		h.Get("If-None-Match"),
+		h.Get("If-Unmodified-Since"),
+		h.Get("Range"),
+	}
+
+	var msHeaders []string
+	for name := range h {
+		if name := strings.ToLower(name); strings.HasPrefix(name, "x-ms-") {
+			msHeaders = append(msHeaders, name)
+		}
+	}
+
+	sort.Strings(msHeaders)
+
+	var b strings.Builder
+
+	b.WriteString(strings.Join(parts, "\n") + "\n")
+
+	for _, name := range msHeaders {
+		b.WriteString(name + ":" + strings.TrimSpace(h.Get(name)) + "\n")
+	}
+
+	b.WriteString("/" + c.account + req.URL.EscapedPath())
+
+	q := req.URL.Query()
+	for _, k := range sortedKeys(q) {
+		vs := q[k]
+		sort.Strings(vs)
+		b.WriteString("\n" + strings.ToLower(k) + ":" + strings.Join(vs, ","))
+	}
+
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(b.String()))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// list returns the MD5 checksums of the blobs under the prefix, by name
+// relative to it.
+func (c *azureClient) list() (map[string]string, error) {
+	blobs := make(map[string]string)
+	marker := ""
+
+	for {
+		q := url.Values{
+			"restype": {"container"},
+			"comp":    {"list"},
+			"prefix":  {c.prefix},
+		}
+
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, c.url("", q), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var res struct {
+			Blobs []struct {
+				Name       string `xml:"Name"`
+				ContentMD5 string `xml:"Properties>Content-MD5"`
+			} `xml:"Blobs>Blob"`
+			NextMarker string `xml:"NextMarker"`
+		}
+
+		if err := c.do(req, &res); err != nil {
+			return nil, err
+		}
+
+		for _, b := range res.Blobs {
+			blobs[strings.TrimPrefix(b.Name, c.prefix)] = b.ContentMD5
+		}
+
+		if marker = res.NextMarker; marker == "" {
+			return blobs, nil
+		}
+	}
+}
+
+func (c *azureClient) put(f localFile) error {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, c.url(c.prefix+f.Name, nil), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", f.ContentType)
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(f.MD5))
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-blob-content-type", f.ContentType)
+	req.Header.Set("x-ms-blob-cache-control", f.CacheControl)
+
+	return c.do(req, nil)
+}
+
+func (c *azureClient) delete(name string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.url(c.prefix+name, nil), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(req, nil)
+}
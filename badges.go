@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Badge is a status image shown on the module page, like a CI build or a
+// coverage badge.
+type Badge struct {
+	Name  string
+	Image string
+	Link  string // Optional.
+}
+
+// parseBadge parses a badge attribute value, "name,image URL[,link URL]".
+func parseBadge(value string) (Badge, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return Badge{}, fmt.Errorf("invalid badge %q, expected name,image[,link]", value)
+	}
+
+	b := Badge{Name: parts[0], Image: parts[1]}
+	if len(parts) == 3 {
+		b.Link = parts[2]
+	}
+
+	for _, u := range []string{b.Image, b.Link} {
+		if u == "" {
+			continue
+		}
+
+		if pu, err := url.Parse(u); err != nil || pu.Scheme != "https" && pu.Scheme != "http" {
+			return Badge{}, fmt.Errorf("invalid badge URL %q", u)
+		}
+	}
+
+	return b, nil
+}
+
+// deriveBadges returns the CI badges of known providers for the repository
+// in dir: a badge per GitHub Actions workflow, and the GitLab pipeline and
+// coverage badges of the branch, if it is known.
+func deriveBadges(repoURL, branch, dir string) []Badge {
+	u, err := url.Parse(strings.TrimSuffix(repoURL, ".git"))
+	if err != nil || u.Scheme != "https" {
+		return nil
+	}
+
+	base := "https://" + u.Host + u.Path
+
+	switch u.Host {
+	case "github.com":
+		files, _ := filepath.Glob(filepath.Join(dir, ".github", "workflows", "*.y*ml"))
+		sort.Strings(files)
+
+		var badges []Badge
+
+		for _, f := range files {
+			name := filepath.Base(f)
+			wf := base + "/actions/workflows/" + name
+
+			badges = append(badges, Badge{
+				Name:  strings.TrimSuffix(name, filepath.Ext(name)),
+				Image: wf + "/badge.svg",
+				Link:  wf,
+			})
+		}
+
+		return badges
+	case "gitlab.com":
+		if branch == "" || branch == "HEAD" {
+			return nil
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".gitlab-ci.yml")); err != nil {
+			return nil
+		}
+
+		return []Badge{
+			{Name: "pipeline", Image: base + "/badges/" + branch + "/pipeline.svg", Link: base + "/-/pipelines"},
+			{Name: "coverage", Image: base + "/badges/" + branch + "/coverage.svg", Link: base + "/-/pipelines"},
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"text/template"
+)
+
+// writeCaddy writes a Caddyfile site block serving the site directory, to
+// be imported from the main Caddyfile.
+func writeCaddy(s *Site) error {
+	root := s.Host
+	if s.Dir != "" {
+		abs, err := filepath.Abs(s.Dir)
+		if err != nil {
+			return err
+		}
+
+		root = filepath.ToSlash(abs) // Caddy takes forward slashes on every OS.
+	}
+
+	var paths []string
+	for _, pkg := range s.Packages {
+		p := s.Path(pkg)
+		paths = append(paths, p)
+
+		if p != "/" {
+			paths = append(paths, p+"/")
+		}
+	}
+
+	data := map[string]any{
+		"Site":         s,
+		"Root":         strconv.Quote(root),
+		"Paths":        paths,
+		"Headers":      securityHeaders,
+		"CacheControl": strconv.Quote(pagesCacheControl),
+		"GoGetPage":    goGetPage,
+	}
+
+	return s.writeTemplate("Caddyfile", caddyTmpl, data)
+}
+
+var caddyTmpl = template.Must(template.New("caddy").Parse(`# Generated by vanitic.
+
+{{ .Site.Host }} {
+	root * {{ .Root }}
+	encode gzip
+
+	header {
+{{- range .Headers }}
+		{{ index . 0 }} "{{ index . 1 }}"
+{{- end }}
+		Cache-Control {{ .CacheControl }}
+	}
+
+	@goget {
+		query go-get=1
+		file {path}/{{ .GoGetPage }}
+	}
+
+	rewrite @goget {path}/{{ .GoGetPage }}
+{{- if .Site.DocsRedirect }}
+
+	@docs {
+		not query go-get=1
+		path{{ range .Paths }} {{ . }}{{ end }}
+	}
+
+	redir @docs https://pkg.go.dev/{host}{path} 302
+{{- end }}
+
+{{- if .Site.Flat }}
+
+	try_files {path}.html {path}
+{{- end }}
+
+	file_server {
+		hide Caddyfile
+	}
+}
+`))
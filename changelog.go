@@ -0,0 +1,90 @@
+package main
+
+import (
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+const changelogPage = "changelog.html"
+
+// Changelog is the release history of a module, from its CHANGELOG.md file
+// or, if it doesn't have one, from its tags.
+type Changelog struct {
+	Package  Package
+	File     template.HTML
+	Releases []Release // Newest first.
+}
+
+type Release struct {
+	Version string
+	Time    time.Time
+	Notes   template.HTML // Annotated tag message.
+}
+
+// writeChangelog writes the changelog page of the module, and reports
+// whether it has any content.
+func writeChangelog(out Output, mod *Module, pkg Package, repoURL string) (bool, error) {
+	cl := Changelog{Package: pkg}
+
+	for _, dir := range []string{mod.Dir(), mod.RepoDir} {
+		if data, err := os.ReadFile(filepath.Join(dir, "CHANGELOG.md")); err == nil {
+			cl.File = renderMarkdown(data, repoURL, pkg.Info.ref())
+			break
+		}
+	}
+
+	if cl.File == "" {
+		versions, err := mod.Versions()
+		if err != nil {
+			return false, err
+		}
+
+		for i := len(versions) - 1; i >= 0; i-- {
+			rel := Release{Version: versions[i]}
+			rel.Time, _ = mod.VersionTime(rel.Version)
+
+			msg, err := mod.cmd.gitTagMessage(mod.RepoDir, mod.TagPrefix()+rel.Version)
+			if err != nil {
+				return false, err
+			}
+
+			if msg != "" {
+				rel.Notes = renderMarkdown([]byte(msg), repoURL, pkg.Info.ref())
+			}
+
+			cl.Releases = append(cl.Releases, rel)
+		}
+	}
+
+	if cl.File == "" && len(cl.Releases) == 0 {
+		return false, nil
+	}
+
+	return true, writeTemplate(out, path.Join(pkg.ImportPath, changelogPage), changelogTmpl, cl)
+}
+
+var changelogTmpl = template.Must(template.New("changelog").Parse(`<!DOCTYPE html>
+<html lang="{{ .Package.Locale.HTMLLang }}">
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="viewport" content="width=device-width, initial-scale=1"/>
+  <link rel="stylesheet" href="/style.css"/>
+  <title>{{ .Package.Locale.T "%s changelog" .Package.DisplayPath }}</title>
+</head>
+<body>
+  <h1><a href="{{ .Package.URL }}">{{ .Package.DisplayPath }}</a> {{ .Package.Locale.T "changelog" }}</h1>
+  {{- with .File }}
+  {{ . }}
+  {{- end }}
+  {{- range .Releases }}
+  <h2 id="{{ .Version }}">{{ .Version }}{{ with .Time }} ({{ .Format "2006-01-02" }}){{ end }}</h2>
+  {{- with .Notes }}
+  {{ . }}
+  {{- end }}
+  {{- end }}
+</body>
+</html>
+`))
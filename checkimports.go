@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// checkImportsCmd reports the imports and requirements of the Go code in
+// the given directories (the current one by default) that reference the
+// repositories of the configuration by their forge paths (like
+// github.com/org/x) instead of their vanity import paths.
+func checkImportsCmd(args []string) error {
+	opts := DefaultOptions()
+	fset := opts.FlagSet("vanitic check-imports")
+
+	if err := opts.Parse(fset, args); err != nil {
+		return err
+	}
+
+	g, err := NewGenerator(opts)
+	if err != nil {
+		return err
+	}
+
+	// The module paths, without touching the output directory.
+	if _, err := g.GenerateTo(NewMemFS()); err != nil {
+		return err
+	}
+
+	vanity := g.vanityPaths()
+
+	dirs := fset.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	found := 0
+
+	report := func(pos, p string) {
+		if v, ok := vanityPath(vanity, p); ok {
+			fmt.Printf("%s: %s should be %s\n", pos, p, v)
+			found++
+		}
+	}
+
+	for _, dir := range dirs {
+		if err := checkImports(dir, report); err != nil {
+			return err
+		}
+	}
+
+	if found > 0 {
+		return fmt.Errorf("check-imports: %d imports don't use vanity paths", found)
+	}
+
+	return nil
+}
+
+// vanityPaths returns the import paths of the repositories roots by their
+// forge paths, mirrors included.
+func (g *Generator) vanityPaths() map[string]string {
+	g.modsMu.RLock()
+	defer g.modsMu.RUnlock()
+
+	paths := make(map[string]string)
+
+	for _, m := range g.modules {
+		root := m.Path
+		if major := pathMajor(root); major != "" {
+			root = path.Dir(root)
+		}
+
+		if dir := strings.TrimSuffix(m.TagPrefix(), "/"); dir != "" {
+			root = strings.TrimSuffix(root, "/"+dir)
+		}
+
+		urls := []string{m.Repo.URL}
+		for _, u := range m.Repo.Mirrors {
+			urls = append(urls, u)
+		}
+
+		for _, u := range urls {
+			if p, ok := forgePath(u); ok && p != root {
+				paths[p] = root
+			}
+		}
+	}
+
+	return paths
+}
+
+// forgePath returns the import path of a repository URL, as in
+// https://github.com/org/x.git or git@github.com:org/x.git to
+// github.com/org/x. URLs without a host, like file URLs, don't have one.
+func forgePath(repoURL string) (string, bool) {
+	var host, p string
+
+	if u, err := url.Parse(repoURL); err == nil && u.Scheme != "" {
+		host, p = u.Hostname(), u.Path
+	} else if _, h, sp, ok := scpURL(repoURL); ok {
+		host, p = h, sp
+	}
+
+	p = strings.TrimSuffix(strings.Trim(p, "/"), ".git")
+	if !strings.Contains(host, ".") || p == "" {
+		return "", false
+	}
+
+	return strings.ToLower(host) + "/" + p, true
+}
+
+// vanityPath returns the vanity import path of p if it is under a forge
+// path of vanity, the longest one wins.
+func vanityPath(vanity map[string]string, p string) (string, bool) {
+	var match string
+
+	for forge := range vanity {
+		if rest, ok := strings.CutPrefix(p, forge); ok && (rest == "" || rest[0] == '/') && len(forge) > len(match) {
+			match = forge
+		}
+	}
+
+	if match == "" {
+		return "", false
+	}
+
+	return vanity[match] + strings.TrimPrefix(p, match), true
+}
+
+// checkImports calls report with the position and path of every import and
+// go.mod requirement under dir. Vendored code, testdata and directories
+// ignored by the go command are skipped.
+func checkImports(dir string, report func(pos, p string)) error {
+	files := token.NewFileSet()
+
+	return filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		base := d.Name()
+
+		if d.IsDir() {
+			if name != dir && (base == "vendor" || base == "testdata" || base[0] == '.' || base[0] == '_') {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		switch {
+		case base == "go.mod":
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return err
+			}
+
+			reqs := parseGoMod(data).Require
+			sort.Slice(reqs, func(i, j int) bool { return reqs[i].Path < reqs[j].Path })
+
+			for _, req := range reqs {
+				report(name, req.Path)
+			}
+		case strings.HasSuffix(base, ".go"):
+			f, err := parser.ParseFile(files, name, nil, parser.ImportsOnly)
+			if err != nil {
+				return err
+			}
+
+			for _, imp := range f.Imports {
+				if p, err := strconv.Unquote(imp.Path.Value); err == nil {
+					report(files.Position(imp.Pos()).String(), p)
+				}
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"text/template"
+)
+
+// writeCloudflare writes a Cloudflare Pages advanced mode worker, which
+// also works as a standalone Worker. The packages are embedded as its
+// routing table, static assets are served when deployed with Pages.
+func writeCloudflare(s *Site) error {
+	// Meta tags are the same as in the pages.
+	type route struct {
+		GoImport string `json:"goImport"`
+		GoSource string `json:"goSource"`
+	}
+
+	routes := make(map[string]route, len(s.Packages))
+	for _, pkg := range s.Packages {
+		routes[s.Path(pkg)] = route{pkg.GoImport(), pkg.GoSource()}
+	}
+
+	routesJSON, err := json.Marshal(routes)
+	if err != nil {
+		return err
+	}
+
+	headersJSON, err := json.Marshal(securityHeaders)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]any{
+		"Routes":       string(routesJSON),
+		"Headers":      string(headersJSON),
+		"CacheControl": pagesCacheControl,
+		"DocsRedirect": s.DocsRedirect,
+	}
+
+	return s.writeTemplate("_worker.js", cloudflareTmpl, data)
+}
+
+var cloudflareTmpl = template.Must(template.New("cloudflare").Parse(`// Generated by vanitic, do not edit.
+
+const routes = {{ .Routes }};
+const headers = {{ .Headers }};
+const docsRedirect = {{ .DocsRedirect }};
+
+function escape(s) {
+  return s.replace(/[&<>"']/g, (c) => "&#" + c.charCodeAt(0) + ";");
+}
+
+function lookup(path) {
+  for (let p = path; ; p = p.slice(0, p.lastIndexOf("/")) || "/") {
+    if (p in routes) {
+      return routes[p];
+    }
+
+    if (p === "/") {
+      return null;
+    }
+  }
+}
+
+function respond(body, status, type) {
+  const res = new Response(body, { status });
+  res.headers.set("Content-Type", type);
+  res.headers.set("Cache-Control", {{ printf "%q" .CacheControl }});
+
+  for (const [name, value] of headers) {
+    res.headers.set(name, value);
+  }
+
+  return res;
+}
+
+export default {
+  async fetch(request, env) {
+    const url = new URL(request.url);
+    const path = url.pathname.replace(/\/+$/, "") || "/";
+    const route = lookup(path);
+    const importPath = url.hostname + (path === "/" ? "" : path);
+
+    if (route && url.searchParams.get("go-get") === "1") {
+      return respond(
+        "<!DOCTYPE html>\n" +
+          '<meta name="go-import" content="' + escape(route.goImport) + '">\n' +
+          '<meta name="go-source" content="' + escape(route.goSource) + '">\n',
+        200,
+        "text/html; charset=utf-8",
+      );
+    }
+
+    if (route && docsRedirect) {
+      return Response.redirect("https://pkg.go.dev/" + importPath, 302);
+    }
+
+    if (env && env.ASSETS) {
+      return env.ASSETS.fetch(request);
+    }
+
+    if (!route) {
+      return respond("not found\n", 404, "text/plain; charset=utf-8");
+    }
+
+    return respond(
+      "<!DOCTYPE html>\n<h1>" + escape(importPath) + "</h1>\n" +
+        '<p><a href="https://pkg.go.dev/' + escape(importPath) + '/">See the package documentation.</a></p>\n',
+      200,
+      "text/html; charset=utf-8",
+    );
+  },
+};
+`))
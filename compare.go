@@ -0,0 +1,265 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// compareCmd reports the modules and packages added, removed and changed
+// between two generated sites, given as output directories or snapshot
+// files written with -o.
+func compareCmd(args []string) error {
+	fset := flag.NewFlagSet("vanitic compare", flag.ExitOnError)
+
+	snapshot := fset.String(
+		"o", "",
+		"Write the snapshot of the new site into the given file, for comparing against it later.",
+	)
+
+	failRemoved := fset.Bool(
+		"fail-removed", false,
+		"Fail if modules or packages were removed.",
+	)
+
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	if fset.NArg() != 2 {
+		return errors.New("compare: expected the old and new sites, as directories or snapshot files")
+	}
+
+	old, err := readSnapshot(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cur, err := readSnapshot(fset.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if *snapshot != "" {
+		data, err := json.MarshalIndent(cur, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(*snapshot, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+
+	removed := 0
+
+	for _, c := range compareSnapshots(old, cur) {
+		fmt.Println(c)
+
+		if c[0] == '-' {
+			removed++
+		}
+	}
+
+	if *failRemoved && removed > 0 {
+		return fmt.Errorf("compare: %d modules or packages removed", removed)
+	}
+
+	return nil
+}
+
+// SiteSnapshot is the inventory of a generated site.
+type SiteSnapshot struct {
+	Packages map[string]SnapshotPackage `json:"packages"` // By import path.
+
+	// The site has package data files, so packages have their versions.
+	Versions bool `json:"versions"`
+}
+
+type SnapshotPackage struct {
+	Module   string `json:"module"`
+	GoImport string `json:"go_import"`
+	Version  string `json:"version,omitempty"`
+}
+
+// readSnapshot reads the snapshot file at name, or takes the snapshot of the
+// output directory at name.
+func readSnapshot(name string) (*SiteSnapshot, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		return takeSnapshot(name)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &SiteSnapshot{}
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return snap, nil
+}
+
+// takeSnapshot returns the packages of the output directory: the ones with
+// an index.json data file if there are data files, or every page with a
+// go-import meta tag otherwise. Import paths of host-stripped layouts get
+// the host of their go-import prefix.
+func takeSnapshot(dir string) (*SiteSnapshot, error) {
+	pages := make(map[string]SnapshotPackage)
+	data := make(map[string]PackageData)
+
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+
+		switch base := path.Base(rel); {
+		case base == "index.json":
+			content, err := os.ReadFile(name)
+			if err != nil {
+				return err
+			}
+
+			var pd PackageData
+			if err := json.Unmarshal(content, &pd); err != nil || pd.ImportPath == "" {
+				return nil // Not a package data file.
+			}
+
+			data[pd.ImportPath] = pd
+		case base == goGetPage || !strings.HasSuffix(base, ".html"):
+		default:
+			content, err := os.ReadFile(name)
+			if err != nil {
+				return err
+			}
+
+			imports := goMetas(content)["go-import"]
+			if len(imports) != 1 {
+				return nil
+			}
+
+			fields := strings.Fields(imports[0])
+			if len(fields) < 3 {
+				return nil
+			}
+
+			p := strings.TrimSuffix(strings.TrimSuffix(rel, "index.html"), "/")
+			if base != "index.html" {
+				p = strings.TrimSuffix(rel, ".html") // Flat layout and page variants.
+			}
+
+			prefix := fields[0]
+			if host, _, _ := strings.Cut(prefix, "/"); p != prefix && !strings.HasPrefix(p, prefix+"/") {
+				p = strings.TrimSuffix(host+"/"+p, "/")
+			}
+
+			pages[p] = SnapshotPackage{Module: prefix, GoImport: imports[0]}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &SiteSnapshot{Packages: pages}
+
+	if len(data) > 0 {
+		snap.Packages = make(map[string]SnapshotPackage)
+		snap.Versions = true
+
+		for p, pd := range data {
+			pkg := pages[p]
+			pkg.Module = pd.Module
+			pkg.Version = pd.Version
+			snap.Packages[p] = pkg
+		}
+	}
+
+	return snap, nil
+}
+
+// compareSnapshots returns the changes from old to cur, modules first, as
+// lines starting with +, - or ~ for added, removed and changed. Versions
+// are only compared if both snapshots have them.
+func compareSnapshots(old, cur *SiteSnapshot) []string {
+	modules := func(s *SiteSnapshot) map[string]SnapshotPackage {
+		mods := make(map[string]SnapshotPackage)
+
+		for p, pkg := range s.Packages {
+			if _, ok := mods[pkg.Module]; !ok || p == pkg.Module {
+				mods[pkg.Module] = pkg
+			}
+		}
+
+		return mods
+	}
+
+	var changes []string
+
+	versions := old.Versions && cur.Versions
+
+	diff := func(kind string, old, cur map[string]SnapshotPackage) {
+		for _, p := range sortedKeys(old) {
+			if _, ok := cur[p]; !ok {
+				changes = append(changes, fmt.Sprintf("- %s %s", kind, p))
+			}
+		}
+
+		for _, p := range sortedKeys(cur) {
+			o, ok := old[p]
+			c := cur[p]
+
+			if !ok {
+				line := "+ " + kind + " " + p
+				if kind == "module" && c.Version != "" {
+					line += " " + c.Version
+				}
+
+				changes = append(changes, line)
+
+				continue
+			}
+
+			// Packages change with their module.
+			if kind != "module" {
+				continue
+			}
+
+			if o.Version != c.Version && versions {
+				changes = append(changes, fmt.Sprintf("~ module %s version %s -> %s", p, cmp.Or(o.Version, "none"), cmp.Or(c.Version, "none")))
+			}
+
+			if o.GoImport != c.GoImport && o.GoImport != "" && c.GoImport != "" {
+				changes = append(changes, fmt.Sprintf("~ module %s go-import %q -> %q", p, o.GoImport, c.GoImport))
+			}
+		}
+	}
+
+	diff("module", modules(old), modules(cur))
+	diff("package", old.Packages, cur.Packages)
+
+	return changes
+}
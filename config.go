@@ -0,0 +1,564 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Config is the content of a configuration file.
+//
+// Every line that is not empty or a comment (starting with '#') is a
+// repository entry: a repository URL followed by optional key=value
+// attributes. Values may be double-quoted to include spaces.
+//
+//	https://github.com/ntrrg/ntgo
+//	https://github.com/acme/private user=bot token=env:GITHUB_TOKEN
+//
+// Credentials are never written in the configuration file, attributes like
+// token reference a secret source instead (see readSecret).
+//
+// Lines starting with a name followed by a colon are directives:
+//
+//	env: GOPRIVATE=go.example.com/* GOFLAGS=-mod=mod
+//
+//	description: go.example.com/pkg/sub "Sub does sub things."
+//
+//	mirrors: github primary
+//
+//	security: Contact=mailto:security@example.com Policy=https://example.com/security
+//
+//	well-known: funding-manifest-urls files/funding.txt
+//
+//	team: payments teams/payments.vanitic namespace=go.example.com/payments
+//
+//	policy: license go-mod tag=warn
+//
+//	rewrite: git@git.example.com: https://git.example.com/
+//
+// env sets Go environment variables for the go commands run on every
+// repository. description overrides the description of an import path.
+// Descriptions with the html: marker are HTML snippets, sanitized to
+// inline elements (see sanitizeHTML). mirrors is the order for choosing
+// the repository URL of the pages among the mirror.<name>=URL attributes
+// of the repositories, primary being the repository URL (see
+// Options.Mirrors). security sets fields of the /.well-known/security.txt
+// file of the sites and well-known publishes a file under /.well-known/
+// with the given name. policy sets the modes of the publishing
+// requirements of the modules (see policies), repositories override them
+// with policy.<name>=mode attributes. rewrite replaces a prefix of the
+// repository URLs in the pages (see Config.publicURL), for cloning over
+// ssh while publishing HTTPS URLs. team reads the configuration fragment
+// of a team (see Team). Other directives discover repositories from forge
+// APIs (see Discovery).
+type Config struct {
+	Repos       []*Repo
+	Env         []string
+	Discoveries []*Discovery
+	Mirrors     []string
+
+	Descriptions map[string]string // By import path.
+
+	// Sanitized HTML of the descriptions with the html: marker, see
+	// parseDescription.
+	RichDescriptions map[string]template.HTML
+
+	// Fields of the security.txt file and files published under
+	// /.well-known/ by name, see writeWellKnown.
+	Security  [][2]string
+	WellKnown map[string]string
+
+	Teams []*Team
+
+	Policies map[string]string // Modes by policy name, see policies.
+
+	Rewrites [][2]string // Prefixes of the repository URLs and their replacements.
+}
+
+type Repo struct {
+	URL string
+
+	// Credentials for HTTPS cloning.
+	User  string
+	Token string
+
+	// Skip the repository if it doesn't have a go.mod file.
+	RequireGoMod bool
+
+	// Include the repository in public sites, see Options.PublicOnly.
+	Public bool
+
+	// Team owning the repository, the main configuration ones have none.
+	Team string
+
+	// Policy modes overriding the configuration ones, from policy.<name>=mode
+	// attributes.
+	Policies map[string]string
+
+	// Directory of the module root, relative to the repository root.
+	Subdir string
+
+	// Shown on the module pages, from repeated badge=name,image[,link]
+	// attributes.
+	Badges []Badge
+
+	// Description of the module, overrides its package synopsis.
+	Description     string
+	RichDescription template.HTML
+
+	// Free-form metadata for the templates, from meta.<key>=value
+	// attributes.
+	Meta map[string]string
+
+	// Other URLs of the repository that pages may point to, from
+	// mirror.<name>=URL attributes.
+	Mirrors map[string]string
+
+	pos string // File and line of the configuration entry.
+}
+
+// readConfig reads the configuration file and the fragments of its teams.
+func readConfig(configFile string) (*Config, error) {
+	cfg, err := parseConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.readTeams(filepath.Dir(configFile)); err != nil {
+		return nil, fmt.Errorf("%s: %w", configFile, err)
+	}
+
+	if err := cfg.checkRepos(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func parseConfigFile(configFile string) (*Config, error) {
+	f, err := os.Open(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	cfg := &Config{}
+	s := bufio.NewScanner(f)
+
+	for n := 1; s.Scan(); n++ {
+		repos := len(cfg.Repos)
+
+		if err := cfg.parseLine(s.Text()); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", configFile, n, err)
+		}
+
+		if len(cfg.Repos) > repos {
+			cfg.Repos[repos].pos = fmt.Sprintf("%s:%d", configFile, n)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (cfg *Config) parseLine(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return nil
+	}
+
+	fields, err := splitFields(line)
+	if err != nil {
+		return err
+	}
+
+	if name, ok := strings.CutSuffix(fields[0], ":"); ok {
+		return cfg.parseDirective(name, fields[1:])
+	}
+
+	repo := &Repo{URL: normalizeRepoURL(fields[0])}
+
+	if err := parseAttrs(fields[1:], repo.setAttr); err != nil {
+		return err
+	}
+
+	cfg.Repos = append(cfg.Repos, repo)
+
+	return nil
+}
+
+func (cfg *Config) parseDirective(name string, args []string) error {
+	switch name {
+	case "env":
+		for _, arg := range args {
+			key, _, ok := strings.Cut(arg, "=")
+			if !ok || !strings.HasPrefix(key, "GO") {
+				return fmt.Errorf("invalid Go environment variable %q", arg)
+			}
+
+			cfg.Env = append(cfg.Env, arg)
+		}
+	case "mirrors":
+		for _, arg := range args {
+			if !validMetaKey(arg) {
+				return fmt.Errorf("invalid mirror name %q", arg)
+			}
+		}
+
+		cfg.Mirrors = args
+	case "security":
+		for _, arg := range args {
+			f, err := parseSecurityField(arg)
+			if err != nil {
+				return err
+			}
+
+			cfg.Security = append(cfg.Security, f)
+		}
+	case "well-known":
+		if len(args) != 2 {
+			return fmt.Errorf("invalid well-known file, expected a name and a file")
+		}
+
+		if name := args[0]; name == "security.txt" || path.Clean(name) != name || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `\`) {
+			return fmt.Errorf("invalid well-known file name %q", name)
+		}
+
+		if cfg.WellKnown == nil {
+			cfg.WellKnown = make(map[string]string)
+		}
+
+		cfg.WellKnown[args[0]] = args[1]
+	case "policy":
+		for _, arg := range args {
+			name, mode, err := parsePolicy(arg)
+			if err != nil {
+				return err
+			}
+
+			if cfg.Policies == nil {
+				cfg.Policies = make(map[string]string)
+			}
+
+			cfg.Policies[name] = mode
+		}
+	case "rewrite":
+		if len(args) != 2 || args[0] == "" {
+			return fmt.Errorf("invalid rewrite, expected an URL prefix and its replacement")
+		}
+
+		cfg.Rewrites = append(cfg.Rewrites, [2]string{args[0], args[1]})
+	case "team":
+		t, err := parseTeam(args)
+		if err != nil {
+			return err
+		}
+
+		cfg.Teams = append(cfg.Teams, t)
+	case "description":
+		if len(args) != 2 {
+			return fmt.Errorf("invalid description, expected an import path and a text")
+		}
+
+		if cfg.Descriptions == nil {
+			cfg.Descriptions = make(map[string]string)
+			cfg.RichDescriptions = make(map[string]template.HTML)
+		}
+
+		host, rest, _ := strings.Cut(args[0], "/")
+
+		host, err := hostToASCII(host)
+		if err != nil {
+			return err
+		}
+
+		importPath := host + strings.TrimSuffix("/"+rest, "/")
+		cfg.Descriptions[importPath], cfg.RichDescriptions[importPath] = parseDescription(args[1])
+	default:
+		if _, ok := discoverers[name]; !ok {
+			return fmt.Errorf("unknown directive %q", name)
+		}
+
+		d, err := newDiscovery(name, args)
+		if err != nil {
+			return err
+		}
+
+		cfg.Discoveries = append(cfg.Discoveries, d)
+	}
+
+	return nil
+}
+
+func (repo *Repo) setAttr(key, value string) error {
+	switch key {
+	case "user":
+		repo.User = value
+	case "token":
+		if _, _, ok := strings.Cut(value, ":"); !ok {
+			return fmt.Errorf("invalid secret source %q", value)
+		}
+
+		repo.Token = value
+	case "gomod":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		repo.RequireGoMod = v
+	case "public":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		repo.Public = v
+	case "subdir":
+		dir := path.Clean(strings.Trim(value, "/"))
+		if dir == ".." || strings.HasPrefix(dir, "../") || strings.Contains(value, `\`) {
+			return fmt.Errorf("invalid subdirectory %q", value)
+		}
+
+		if dir != "." {
+			repo.Subdir = dir
+		}
+	case "description":
+		repo.Description, repo.RichDescription = parseDescription(value)
+	case "badge":
+		b, err := parseBadge(value)
+		if err != nil {
+			return err
+		}
+
+		repo.Badges = append(repo.Badges, b)
+	default:
+		if k, ok := strings.CutPrefix(key, "meta."); ok && validMetaKey(k) {
+			if repo.Meta == nil {
+				repo.Meta = make(map[string]string)
+			}
+
+			repo.Meta[k] = value
+
+			return nil
+		}
+
+		if k, ok := strings.CutPrefix(key, "policy."); ok {
+			name, mode, err := parsePolicy(k + "=" + value)
+			if err != nil {
+				return err
+			}
+
+			if repo.Policies == nil {
+				repo.Policies = make(map[string]string)
+			}
+
+			repo.Policies[name] = mode
+
+			return nil
+		}
+
+		if k, ok := strings.CutPrefix(key, "mirror."); ok && validMetaKey(k) && k != primaryMirror && k != localMirror {
+			if repo.Mirrors == nil {
+				repo.Mirrors = make(map[string]string)
+			}
+
+			repo.Mirrors[k] = value
+
+			return nil
+		}
+
+		return fmt.Errorf("unknown attribute %q", key)
+	}
+
+	return nil
+}
+
+func validMetaKey(k string) bool {
+	for _, r := range k {
+		if !(r == '_' || r == '-' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+			return false
+		}
+	}
+
+	return k != ""
+}
+
+// primaryMirror is the name of the repository URL in mirror orders.
+const primaryMirror = "primary"
+
+// SourceURL returns the repository URL for the pages, the first of the
+// given mirrors the repository has. The repository URL is used if none
+// matches.
+func (repo *Repo) SourceURL(mirrors []string) string {
+	for _, name := range mirrors {
+		if name == primaryMirror {
+			break
+		}
+
+		if u, ok := repo.Mirrors[name]; ok {
+			return u
+		}
+	}
+
+	return repo.URL
+}
+
+// repoKey returns a key for comparing repository URLs, ignoring the
+// differences between web and clone URLs.
+func repoKey(url string) string {
+	return strings.TrimSuffix(normalizeRepoURL(url), ".git")
+}
+
+// key identifies the module of the repository, its URL key (see repoKey)
+// and subdirectory.
+func (repo *Repo) key() string {
+	return repoKey(repo.URL) + "\x00" + repo.Subdir
+}
+
+// hasRepo reports if the repository is configured, with the same module
+// subdirectory.
+func (cfg *Config) hasRepo(r *Repo) bool {
+	for _, c := range cfg.Repos {
+		if c.key() == r.key() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkRepos returns an error if a repository is configured twice, with
+// the same module subdirectory, or if the source directories of different
+// repositories (see repoPath) would be the same or nested.
+func (cfg *Config) checkRepos() error {
+	seen := make(map[string]*Repo, len(cfg.Repos))
+
+	for _, r := range cfg.Repos {
+		key := r.key()
+		if o, ok := seen[key]; ok {
+			what := r.URL
+			if r.Subdir != "" {
+				what += " (subdir " + r.Subdir + ")"
+			}
+
+			return fmt.Errorf("%s: duplicated repository %s, first configured at %s", r.pos, what, o.pos)
+		}
+
+		seen[key] = r
+	}
+
+	dirs := make(map[string]*Repo, len(cfg.Repos))
+
+	for _, r := range cfg.Repos {
+		p := repoPath(r.URL)
+		if o, ok := dirs[p]; ok && repoKey(o.URL) != repoKey(r.URL) {
+			return fmt.Errorf("%s: repository %s has the source directory of %s, configured at %s", r.pos, r.URL, o.URL, o.pos)
+		}
+
+		dirs[p] = r
+	}
+
+	for _, r := range cfg.Repos {
+		for d := path.Dir(repoPath(r.URL)); d != "."; d = path.Dir(d) {
+			if o, ok := dirs[d]; ok {
+				return fmt.Errorf("%s: repository %s would be checked out inside %s, configured at %s", r.pos, r.URL, o.URL, o.pos)
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseAttrs(fields []string, set func(key, value string) error) error {
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return fmt.Errorf("invalid attribute %q, expected key=value", field)
+		}
+
+		if err := set(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func splitFields(line string) ([]string, error) {
+	var (
+		fields  []string
+		field   strings.Builder
+		inField bool
+		quoted  bool
+		escaped bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case !quoted && unicode.IsSpace(r):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quoted value")
+	}
+
+	if inField {
+		fields = append(fields, field.String())
+	}
+
+	return fields, nil
+}
+
+// readSecret reads a secret from the given source, which may be:
+//
+//	env:NAME   the value of the environment variable NAME.
+//	file:PATH  the content of the file at PATH, without surrounding spaces.
+func readSecret(src string) (string, error) {
+	kind, ref, _ := strings.Cut(src, ":")
+
+	switch kind {
+	case "env":
+		v, ok := os.LookupEnv(ref)
+		if !ok {
+			return "", fmt.Errorf("environment variable %q is not set", ref)
+		}
+
+		return v, nil
+	case "file":
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	return "", fmt.Errorf("unknown secret source %q", kind)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Contributor is a commit author of a module.
+type Contributor struct {
+	Name    string
+	Email   string // Masked or empty, depending on the email mode.
+	Commits int
+}
+
+// Email modes of the contributors list.
+var emailModes = []string{"mask", "hide", "show"}
+
+// readContributors returns the commit authors of the module, sorted by
+// number of commits.
+func readContributors(mod *Module, emails string) ([]Contributor, error) {
+	args := []string{"git", "shortlog", "-sne", "HEAD"}
+	if mod.Subdir != "" {
+		args = append(args, "--", mod.Subdir)
+	}
+
+	output, err := mod.cmd.runCmdOutput(mod.RepoDir, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var cs []Contributor
+
+	for _, line := range bytes.Split(bytes.TrimSpace(output), []byte{'\n'}) {
+		count, author, ok := strings.Cut(strings.TrimSpace(string(line)), "\t")
+		if !ok {
+			continue
+		}
+
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return nil, fmt.Errorf("shortlog: invalid line %q", line)
+		}
+
+		c := Contributor{Name: author, Commits: n}
+
+		if i := strings.LastIndex(author, " <"); i >= 0 && strings.HasSuffix(author, ">") {
+			c.Name, c.Email = author[:i], author[i+2:len(author)-1]
+		}
+
+		switch emails {
+		case "hide":
+			c.Email = ""
+		case "mask":
+			c.Email = maskEmail(c.Email)
+		}
+
+		cs = append(cs, c)
+	}
+
+	return cs, nil
+}
+
+// maskEmail keeps the first letter of the local part and the domain of the
+// email address.
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return ""
+	}
+
+	return local[:1] + "***@" + domain
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+)
+
+// requireURL returns the page of a module requirement: its vanity page if
+// it is hosted by this generator (a registered module, or one in the same
+// host), or its pkg.go.dev page otherwise.
+func (g *Generator) requireURL(mod *Module, req ModRequire) string {
+	g.modsMu.RLock()
+	_, ok := g.modules[req.Path]
+	g.modsMu.RUnlock()
+
+	host, _, _ := strings.Cut(mod.Path, "/")
+	reqHost, _, _ := strings.Cut(req.Path, "/")
+
+	if ok || host == reqHost {
+		return "https://" + req.Path
+	}
+
+	return "https://pkg.go.dev/" + req.Path + "@" + req.Version
+}
+
+const depsPage = "deps.html"
+
+// depsModule is a module of the dependency graph page.
+type depsModule struct {
+	Package
+	Require []ModRequire
+	UsedBy  []string // Modules of the site requiring it.
+}
+
+// writeDepsGraph writes the dependency graph page of the site, with the
+// direct requirements of every module and the site modules requiring it.
+func writeDepsGraph(s *Site) error {
+	var mods []*depsModule
+
+	byPath := make(map[string]*depsModule)
+
+	for _, pkg := range s.Packages {
+		if !pkg.IsModule() {
+			continue
+		}
+
+		m := &depsModule{Package: pkg}
+		if pkg.Info != nil && pkg.Info.GoMod != nil {
+			m.Require = pkg.Info.GoMod.Direct()
+		}
+
+		mods = append(mods, m)
+		byPath[pkg.Module] = m
+	}
+
+	for _, m := range mods {
+		for _, r := range m.Require {
+			if dep, ok := byPath[r.Path]; ok {
+				dep.UsedBy = append(dep.UsedBy, m.Module)
+			}
+		}
+	}
+
+	return s.writeTemplate(depsPage, depsTmpl, struct {
+		Site    *Site
+		Modules []*depsModule
+	}{s, mods})
+}
+
+var depsTmpl = template.Must(template.New("deps").Funcs(template.FuncMap{
+	"displayPath": displayPath,
+}).Parse(`<!DOCTYPE html>
+<html lang="{{ .Site.Locale.HTMLLang }}">
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="viewport" content="width=device-width, initial-scale=1"/>
+  <link rel="stylesheet" href="/style.css"/>
+  <title>{{ .Site.Locale.T "%s dependencies" .Site.DisplayHost }}</title>
+</head>
+<body>
+  <h1>{{ .Site.Locale.T "%s dependencies" .Site.DisplayHost }}</h1>
+  {{- range .Modules }}
+  <h2 id="{{ .Module }}"><a href="{{ $.Site.Path .Package }}">{{ .DisplayPath }}</a></h2>
+  {{- with .Require }}
+  <p>{{ $.Site.Locale.T "Requires:" }}</p>
+  <ul>
+  {{- range . }}
+    <li><a href="{{ .URL }}">{{ .DisplayPath }}</a> {{ .Version }}</li>
+  {{- end }}
+  </ul>
+  {{- else }}
+  <p>{{ $.Site.Locale.T "No dependencies." }}</p>
+  {{- end }}
+  {{- with .UsedBy }}
+  <p>{{ $.Site.Locale.T "Required by:" }}</p>
+  <ul>
+  {{- range . }}
+    <li><a href="{{ printf "#%s" . }}">{{ displayPath . }}</a></li>
+  {{- end }}
+  </ul>
+  {{- end }}
+  {{- end }}
+</body>
+</html>
+`))
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discovery is a configuration directive that lists repositories from a
+// forge API, like:
+//
+//	github-org: acme token=env:GITHUB_TOKEN
+//	gitlab-group: acme/go api=https://gitlab.acme.com/api/v4 visibility=public
+//	bitbucket-workspace: acme user=bot token=env:BITBUCKET_APP_PASSWORD
+//
+// Discovered repositories inherit the repository attributes of the
+// directive. By default archived repositories and forks are skipped, other
+// filters may be set with:
+//
+//	include=REGEXP  only repositories with matching names.
+//	exclude=REGEXP  skip repositories with matching names.
+//	topics=go,lib   only repositories with any of the given topics.
+//	archived=true   include archived repositories.
+//	forks=true      include forks.
+//	gomod=true      skip repositories without a go.mod file.
+type Discovery struct {
+	Kind       string
+	Name       string
+	API        string
+	Visibility string
+	Repo       Repo
+
+	Include  *regexp.Regexp
+	Exclude  *regexp.Regexp
+	Topics   []string
+	Archived bool
+	Forks    bool
+}
+
+type forgeRepo struct {
+	Name     string
+	URL      string
+	Archived bool
+	Fork     bool
+	Topics   []string
+}
+
+var discoverers = map[string]func(*apiClient, *Discovery) ([]forgeRepo, error){
+	"github-org":   discoverGitHubOrg,
+	"gitlab-group": discoverGitLabGroup,
+
+	"bitbucket-workspace": discoverBitbucketWorkspace,
+}
+
+func newDiscovery(kind string, args []string) (*Discovery, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s: missing name", kind)
+	}
+
+	d := &Discovery{Kind: kind, Name: args[0]}
+
+	if err := parseAttrs(args[1:], d.setAttr); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (d *Discovery) setAttr(key, value string) error {
+	switch key {
+	case "api":
+		d.API = strings.TrimSuffix(value, "/")
+	case "visibility":
+		switch value {
+		case "public", "internal", "private":
+		default:
+			return fmt.Errorf("invalid visibility %q", value)
+		}
+
+		d.Visibility = value
+	case "include", "exclude":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		if key == "include" {
+			d.Include = re
+		} else {
+			d.Exclude = re
+		}
+	case "topics":
+		d.Topics = strings.Split(value, ",")
+	case "archived", "forks":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		if key == "archived" {
+			d.Archived = v
+		} else {
+			d.Forks = v
+		}
+	default:
+		return d.Repo.setAttr(key, value)
+	}
+
+	return nil
+}
+
+func (d *Discovery) match(fr forgeRepo) bool {
+	switch {
+	case fr.Archived && !d.Archived, fr.Fork && !d.Forks:
+		return false
+	case d.Include != nil && !d.Include.MatchString(fr.Name):
+		return false
+	case d.Exclude != nil && d.Exclude.MatchString(fr.Name):
+		return false
+	}
+
+	if len(d.Topics) == 0 {
+		return true
+	}
+
+	for _, t := range d.Topics {
+		if slices.Contains(fr.Topics, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// discover appends the repositories found by the discovery directives to the
+// configured ones. Repositories not matching the directive filters or
+// already configured are not added.
+func (cfg *Config) discover(nrc netrc, cache *apiCache) error {
+	seen := make(map[string]bool, len(cfg.Repos))
+	for _, repo := range cfg.Repos {
+		seen[repoKey(repo.URL)] = true
+	}
+
+	cache.reset()
+
+	for _, d := range cfg.Discoveries {
+		c := &apiClient{netrc: nrc, header: http.Header{}, cache: cache}
+
+		// Teams only authenticate with their secrets.
+		if d.Repo.Team != "" {
+			c.netrc = nil
+		}
+
+		repos, err := discoverers[d.Kind](c, d)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", d.Kind, d.Name, err)
+		}
+
+		for _, fr := range repos {
+			if !d.match(fr) || seen[repoKey(fr.URL)] {
+				continue
+			}
+
+			if d.Repo.Team != "" && !networkURL(fr.URL) {
+				return fmt.Errorf("%s %s: %s isn't a network repository URL", d.Kind, d.Name, fr.URL)
+			}
+
+			seen[repoKey(fr.URL)] = true
+			repo := d.Repo
+			repo.URL = normalizeRepoURL(fr.URL)
+			repo.Policies = maps.Clone(d.Repo.Policies)
+			repo.Badges = slices.Clone(d.Repo.Badges)
+			repo.Meta = maps.Clone(d.Repo.Meta)
+			repo.Mirrors = maps.Clone(d.Repo.Mirrors)
+			cfg.Repos = append(cfg.Repos, &repo)
+		}
+	}
+
+	return nil
+}
+
+func discoverGitHubOrg(c *apiClient, d *Discovery) ([]forgeRepo, error) {
+	api := d.API
+	if api == "" {
+		api = "https://api.github.com"
+	}
+
+	c.header.Set("Accept", "application/vnd.github+json")
+	c.header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	if d.Repo.Token != "" {
+		token, err := readSecret(d.Repo.Token)
+		if err != nil {
+			return nil, err
+		}
+
+		c.authorize(api, "Authorization", "Bearer "+token)
+	}
+
+	q := url.Values{}
+	q.Set("type", "all")
+	q.Set("per_page", "100")
+
+	if d.Visibility != "" {
+		q.Set("type", d.Visibility)
+	}
+
+	var repos []forgeRepo
+	next := api + "/orgs/" + url.PathEscape(d.Name) + "/repos?" + q.Encode()
+
+	for next != "" {
+		var page []struct {
+			Name     string   `json:"name"`
+			HTMLURL  string   `json:"html_url"`
+			Archived bool     `json:"archived"`
+			Fork     bool     `json:"fork"`
+			Topics   []string `json:"topics"`
+		}
+
+		header, err := c.getJSON(next, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range page {
+			repos = append(repos, forgeRepo{
+				Name:     r.Name,
+				URL:      r.HTMLURL,
+				Archived: r.Archived,
+				Fork:     r.Fork,
+				Topics:   r.Topics,
+			})
+		}
+
+		next = nextLink(header)
+	}
+
+	return repos, nil
+}
+
+// discoverGitLabGroup lists the projects of a GitLab group and its
+// subgroups.
+func discoverGitLabGroup(c *apiClient, d *Discovery) ([]forgeRepo, error) {
+	api := d.API
+	if api == "" {
+		api = "https://gitlab.com/api/v4"
+	}
+
+	if d.Repo.Token != "" {
+		token, err := readSecret(d.Repo.Token)
+		if err != nil {
+			return nil, err
+		}
+
+		c.authorize(api, "PRIVATE-TOKEN", token)
+	}
+
+	q := url.Values{}
+	q.Set("include_subgroups", "true")
+	q.Set("per_page", "100")
+	q.Set("order_by", "id")
+
+	if d.Visibility != "" {
+		q.Set("visibility", d.Visibility)
+	}
+
+	var repos []forgeRepo
+	next := api + "/groups/" + url.PathEscape(d.Name) + "/projects?" + q.Encode()
+
+	for next != "" {
+		var page []struct {
+			Path       string   `json:"path"`
+			WebURL     string   `json:"web_url"`
+			Archived   bool     `json:"archived"`
+			Topics     []string `json:"topics"`
+			TagList    []string `json:"tag_list"`
+			ForkedFrom *struct {
+				ID int `json:"id"`
+			} `json:"forked_from_project"`
+		}
+
+		header, err := c.getJSON(next, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range page {
+			// tag_list is deprecated in favor of topics, but older
+			// instances only provide it.
+			topics := p.Topics
+			if topics == nil {
+				topics = p.TagList
+			}
+
+			repos = append(repos, forgeRepo{
+				Name:     p.Path,
+				URL:      p.WebURL,
+				Archived: p.Archived,
+				Fork:     p.ForkedFrom != nil,
+				Topics:   topics,
+			})
+		}
+
+		next = nextLink(header)
+	}
+
+	return repos, nil
+}
+
+// discoverBitbucketWorkspace lists the repositories of a Bitbucket Cloud
+// workspace, authenticating with an app password.
+func discoverBitbucketWorkspace(c *apiClient, d *Discovery) ([]forgeRepo, error) {
+	api := d.API
+	if api == "" {
+		api = "https://api.bitbucket.org/2.0"
+	}
+
+	if d.Repo.Token != "" {
+		if d.Repo.User == "" {
+			return nil, fmt.Errorf("app passwords require an user")
+		}
+
+		password, err := readSecret(d.Repo.Token)
+		if err != nil {
+			return nil, err
+		}
+
+		c.authorize(api, "Authorization", basicAuth(d.Repo.User, password))
+	}
+
+	q := url.Values{}
+	q.Set("pagelen", "100")
+
+	switch d.Visibility {
+	case "public":
+		q.Set("q", "is_private=false")
+	case "private":
+		q.Set("q", "is_private=true")
+	}
+
+	var repos []forgeRepo
+	next := api + "/repositories/" + url.PathEscape(d.Name) + "?" + q.Encode()
+
+	for next != "" {
+		var page struct {
+			Values []struct {
+				Slug  string `json:"slug"`
+				Links struct {
+					HTML struct {
+						Href string `json:"href"`
+					} `json:"html"`
+				} `json:"links"`
+				Parent *struct {
+					FullName string `json:"full_name"`
+				} `json:"parent"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+
+		if _, err := c.getJSON(next, &page); err != nil {
+			return nil, err
+		}
+
+		for _, r := range page.Values {
+			repos = append(repos, forgeRepo{
+				Name: r.Slug,
+				URL:  r.Links.HTML.Href,
+				Fork: r.Parent != nil,
+			})
+		}
+
+		next = page.Next
+	}
+
+	return repos, nil
+}
+
+func basicAuth(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+type apiClient struct {
+	netrc  netrc
+	header http.Header
+	cache  *apiCache
+
+	// Credentials, only sent to the API host.
+	auth     http.Header
+	authHost string
+}
+
+// authorize sets a credential header for the requests to the host of the
+// api URL.
+func (c *apiClient) authorize(api, key, value string) {
+	if c.auth == nil {
+		c.auth = http.Header{}
+	}
+
+	if u, err := url.Parse(api); err == nil {
+		c.authHost = u.Host
+	}
+
+	c.auth.Set(key, value)
+}
+
+// apiHTTPClient drops the credentials on redirects to other hosts, the
+// default client keeps the ones it doesn't know, like PRIVATE-TOKEN.
+var apiHTTPClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		if req.URL.Host != via[0].URL.Host {
+			req.Header.Del("Authorization")
+			req.Header.Del("PRIVATE-TOKEN")
+		}
+
+		return nil
+	},
+}
+
+// maxAPIResponse bounds the size of the API responses.
+const maxAPIResponse = 32 << 20
+
+// Bounds of the retries of rate limited requests.
+const (
+	maxAPIAttempts   = 5
+	minRateLimitWait = time.Second
+)
+
+// getJSON decodes the response of the URL into v, and returns its Link
+// headers.
+func (c *apiClient) getJSON(rawURL string, v any) (http.Header, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, vs := range c.header {
+		req.Header[k] = vs
+	}
+
+	// Pagination links come from the server, the credentials are only sent
+	// to the configured API.
+	if req.URL.Host == c.authHost {
+		for k, vs := range c.auth {
+			req.Header[k] = vs
+		}
+	}
+
+	req.Header.Set("User-Agent", "vanitic")
+	c.netrc.setAuth(req)
+
+	key := cacheKey(req.URL.String(), req.Header.Get("Authorization")+req.Header.Get("PRIVATE-TOKEN"))
+
+	r, err := c.do(req, c.cache.load(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return nil, fmt.Errorf("GET %s: %w", req.URL.Redacted(), err)
+	}
+
+	if err := c.cache.store(key, r); err != nil {
+		return nil, err
+	}
+
+	return http.Header{"Link": r.Link}, nil
+}
+
+// do returns the response of the request, or the cached one while it is
+// fresh or unmodified. Rate limited requests are retried a few times after
+// the limit reset if it is soon enough, the cached response is used
+// otherwise, and when the request budget runs over.
+func (c *apiClient) do(req *http.Request, cached *apiResponse) (*apiResponse, error) {
+	if cached != nil && c.cache.ttl > 0 && time.Since(cached.Time) < c.cache.ttl {
+		return cached, nil
+	}
+
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	if cached != nil && cached.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if !c.cache.spend() {
+			return cached.stale(req.URL.Redacted(), errors.New("request budget exhausted"))
+		}
+
+		res, err := apiHTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := io.ReadAll(io.LimitReader(res.Body, maxAPIResponse))
+		res.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case res.StatusCode == http.StatusNotModified && cached != nil:
+			r := *cached
+			r.Time = time.Now().UTC()
+
+			return &r, nil
+		case res.StatusCode == http.StatusOK:
+			if !json.Valid(body) {
+				return nil, fmt.Errorf("GET %s: invalid JSON response", req.URL.Redacted())
+			}
+
+			return &apiResponse{
+				ETag:         res.Header.Get("ETag"),
+				LastModified: res.Header.Get("Last-Modified"),
+				Link:         res.Header.Values("Link"),
+				Body:         body,
+				Time:         time.Now().UTC(),
+			}, nil
+		}
+
+		if wait, ok := rateLimitWait(res); ok {
+			if attempt >= maxAPIAttempts {
+				return cached.stale(req.URL.Redacted(), fmt.Errorf("%w after %d attempts", errRateLimited, attempt))
+			}
+
+			// Servers may ask to retry right away, or at a reset already
+			// passed.
+			wait = max(wait, minRateLimitWait)
+
+			if wait > c.cache.maxWait {
+				return cached.stale(req.URL.Redacted(), fmt.Errorf("%w until %s", errRateLimited, time.Now().Add(wait).UTC().Format(time.RFC3339)))
+			}
+
+			log.Printf("discovery: GET %s: %s, retrying in %v", req.URL.Redacted(), res.Status, wait.Round(time.Second))
+			time.Sleep(wait)
+
+			continue
+		}
+
+		return nil, fmt.Errorf("GET %s: %s: %s", req.URL.Redacted(), res.Status, strings.TrimSpace(string(body[:min(len(body), 512)])))
+	}
+}
+
+var linkNextRe = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextLink returns the next page URL from a pagination Link header.
+func nextLink(header http.Header) string {
+	for _, link := range header.Values("Link") {
+		if m := linkNextRe.FindStringSubmatch(link); m != nil {
+			return m[1]
+		}
+	}
+
+	return ""
+}
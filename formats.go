@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// format writes the package pages in an output format. Formats for static
+// site generators also write data files with every package.
+type format struct {
+	page func(out Output, pkg Package) error
+	data func(out Output, pkgs []Package) error
+}
+
+var formats = map[string]format{
+	"html":   {page: writePackage},
+	"hugo":   {page: writeHugoPage, data: writeHugoData},
+	"jekyll": {page: writeJekyllPage, data: writeJekyllData},
+}
+
+// writeHugoPage writes the package as a Hugo section page, the site theme
+// renders the meta tags with the layouts/partials/go-import.html partial.
+func writeHugoPage(out Output, pkg Package) error {
+	return writeTemplate(out, path.Join("content", pkg.ImportPath, "_index.md"), hugoPageTmpl, pkg)
+}
+
+func writeHugoData(out Output, pkgs []Package) error {
+	if err := writeFile(out, "layouts/partials/go-import.html", []byte(hugoPartial)); err != nil {
+		return err
+	}
+
+	return writeFile(out, "data/packages.yaml", packagesYAML(pkgs))
+}
+
+const hugoPartial = `{{ with .Params.go_import }}<meta name="go-import" content="{{ . }}">{{ end }}
+{{ with .Params.go_source }}<meta name="go-source" content="{{ . }}">{{ end }}
+`
+
+var hugoPageTmpl = template.Must(template.New("hugo").Funcs(template.FuncMap{
+	"yaml": yamlString,
+	"url":  pageURL,
+}).Parse(`---
+title: {{ yaml .ImportPath }}
+description: {{ yaml .Description }}
+url: {{ yaml (url .ImportPath) }}
+go_import: {{ yaml .GoImport }}
+go_source: {{ yaml .GoSource }}
+{{- with .Info }}{{ with .Meta }}
+meta:
+{{- range $k, $v := . }}
+  {{ yaml $k }}: {{ yaml $v }}
+{{- end }}
+{{- end }}{{ end }}
+---
+
+{{ .Description }}
+
+[See the package documentation](https://pkg.go.dev/{{ .ImportPath }}/).
+`))
+
+// writeJekyllPage writes the package as a Jekyll page with the default
+// layout, which renders the meta tags by including _includes/go-import.html.
+func writeJekyllPage(out Output, pkg Package) error {
+	return writeTemplate(out, path.Join(pkg.ImportPath, "index.md"), jekyllPageTmpl, pkg)
+}
+
+func writeJekyllData(out Output, pkgs []Package) error {
+	if err := writeFile(out, "_includes/go-import.html", []byte(jekyllInclude)); err != nil {
+		return err
+	}
+
+	return writeFile(out, "_data/packages.yml", packagesYAML(pkgs))
+}
+
+const jekyllInclude = `{% if page.go_import %}<meta name="go-import" content="{{ page.go_import | escape }}">{% endif %}
+{% if page.go_source %}<meta name="go-source" content="{{ page.go_source | escape }}">{% endif %}
+`
+
+var jekyllPageTmpl = template.Must(template.New("jekyll").Funcs(template.FuncMap{
+	"yaml": yamlString,
+	"url":  pageURL,
+}).Parse(`---
+layout: default
+title: {{ yaml .ImportPath }}
+description: {{ yaml .Description }}
+permalink: {{ yaml (url .ImportPath) }}
+go_import: {{ yaml .GoImport }}
+go_source: {{ yaml .GoSource }}
+{{- with .Info }}{{ with .Meta }}
+meta:
+{{- range $k, $v := . }}
+  {{ yaml $k }}: {{ yaml $v }}
+{{- end }}
+{{- end }}{{ end }}
+---
+
+{{ .Description }}
+
+[See the package documentation](https://pkg.go.dev/{{ .ImportPath }}/).
+`))
+
+// packagesYAML returns the packages as a YAML list.
+func packagesYAML(pkgs []Package) []byte {
+	var b bytes.Buffer
+
+	for _, pkg := range pkgs {
+		fmt.Fprintf(&b, "- import_path: %s\n", yamlString(pkg.ImportPath))
+		fmt.Fprintf(&b, "  module: %s\n", yamlString(pkg.Module))
+		fmt.Fprintf(&b, "  source: %s\n", yamlString(pkg.Source))
+		fmt.Fprintf(&b, "  description: %s\n", yamlString(pkg.Description))
+		fmt.Fprintf(&b, "  url: %s\n", yamlString(pkg.URL()))
+
+		if pkg.Info != nil && pkg.Info.Latest != "" {
+			fmt.Fprintf(&b, "  version: %s\n", yamlString(pkg.Info.Latest))
+			fmt.Fprintf(&b, "  version_time: %s\n", pkg.Info.LatestTime.UTC().Format(time.RFC3339))
+		}
+
+		if pkg.Info != nil && pkg.Info.GoMod != nil && pkg.Info.GoMod.Go != "" {
+			fmt.Fprintf(&b, "  go: %s\n", yamlString(pkg.Info.GoMod.Go))
+		}
+
+		if pkg.Info != nil && len(pkg.Info.Meta) > 0 {
+			b.WriteString("  meta:\n")
+
+			for _, k := range sortedKeys(pkg.Info.Meta) {
+				fmt.Fprintf(&b, "    %s: %s\n", yamlString(k), yamlString(pkg.Info.Meta[k]))
+			}
+		}
+	}
+
+	return b.Bytes()
+}
+
+// yamlString returns s as a YAML double-quoted scalar, JSON strings are
+// valid ones.
+func yamlString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// pageURL returns the URL path of the import path page, relative to its
+// host. Flat pages don't have a trailing slash, see layouts.
+func pageURL(importPath string, flat bool) string {
+	_, p, ok := strings.Cut(importPath, "/")
+	if !ok {
+		return "/"
+	}
+
+	if flat {
+		return "/" + p
+	}
+
+	return "/" + p + "/"
+}
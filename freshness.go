@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// refreshFile records when the checkout was last cloned or updated, inside
+// its .git directory so it isn't part of the sources.
+const refreshFile = "vanitic-refreshed"
+
+func markRefreshed(repo string, t time.Time) error {
+	return os.WriteFile(
+		filepath.Join(repo, ".git", refreshFile),
+		[]byte(t.UTC().Format(time.RFC3339)+"\n"),
+		0644,
+	)
+}
+
+// lastRefresh returns when the checkout was last refreshed, the zero time if
+// it never was.
+func lastRefresh(repo string) time.Time {
+	data, err := os.ReadFile(filepath.Join(repo, ".git", refreshFile))
+	if err != nil {
+		return time.Time{}
+	}
+
+	t, _ := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+
+	return t
+}
+
+// Ago returns how long before the generation of the site t was, like
+// "3 days ago".
+func (pkg Package) Ago(t time.Time) string {
+	d := pkg.Site.Generated.Sub(t)
+
+	switch {
+	case d < time.Minute:
+		return pkg.Locale.T("just now")
+	case d < 2*time.Minute:
+		return pkg.Locale.T("a minute ago")
+	case d < time.Hour:
+		return pkg.Locale.T("%d minutes ago", int(d/time.Minute))
+	case d < 2*time.Hour:
+		return pkg.Locale.T("an hour ago")
+	case d < 24*time.Hour:
+		return pkg.Locale.T("%d hours ago", int(d/time.Hour))
+	case d < 48*time.Hour:
+		return pkg.Locale.T("a day ago")
+	}
+
+	return pkg.Locale.T("%d days ago", int(d/(24*time.Hour)))
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	gcsAPI   = "https://storage.googleapis.com/storage/v1"
+	gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+)
+
+// uploadGCS syncs the directory to a Google Cloud Storage bucket, with URLs
+// like gs://bucket/prefix. Credentials are taken from the
+// GOOGLE_OAUTH_ACCESS_TOKEN variable, the service account key file at
+// GOOGLE_APPLICATION_CREDENTIALS, or the metadata server, in that order.
+func uploadGCS(u *url.URL, dir string, _ cmdRunner) error {
+	token, err := gcsToken()
+	if err != nil {
+		return fmt.Errorf("gcs: %w", err)
+	}
+
+	c := &gcsClient{bucket: u.Host, prefix: storagePrefix(u), token: token}
+
+	remote, err := c.list()
+	if err != nil {
+		return err
+	}
+
+	local, err := localFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	upload, remove := syncPlan(local, remote, func(sum []byte) string {
+		return base64.StdEncoding.EncodeToString(sum)
+	})
+
+	for _, f := range upload {
+		if err := c.upload(f); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range remove {
+		if err := c.delete(name); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("gcs: %s: %d uploaded, %d deleted", u, len(upload), len(remove))
+
+	return nil
+}
+
+type gcsClient struct {
+	bucket string
+	prefix string
+	token  string
+}
+
+func (c *gcsClient) do(req *http.Request, v any) error {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	return storageDo(req, v)
+}
+
+// list returns the MD5 checksums of the objects under the prefix, by name
+// relative to it.
+func (c *gcsClient) list() (map[string]string, error) {
+	objects := make(map[string]string)
+	page := ""
+
+	for {
+		q := url.Values{
+			"prefix": {c.prefix},
+			"fields": {"items(name,md5Hash),nextPageToken"},
+		}
+
+		if page != "" {
+			q.Set("pageToken", page)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, gcsAPI+"/b/"+url.PathEscape(c.bucket)+"/o?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var res struct {
+			Items []struct {
+				Name    string `json:"name"`
+				MD5Hash string `json:"md5Hash"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+
+		if err := c.do(req, &res); err != nil {
+			return nil, err
+		}
+
+		for _, o := range res.Items {
+			objects[strings.TrimPrefix(o.Name, c.prefix)] = o.MD5Hash
+		}
+
+		if page = res.NextPageToken; page == "" {
+			return objects, nil
+		}
+	}
+}
+
+func (c *gcsClient) upload(f localFile) error {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(map[string]string{
+		"name":         c.prefix + f.Name,
+		"contentType":  f.ContentType,
+		"cacheControl": f.CacheControl,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		data        []byte
+	}{
+		{"application/json; charset=utf-8", meta},
+		{f.ContentType, data},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return err
+		}
+
+		pw.Write(part.data)
+	}
+
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	u := "https://storage.googleapis.com/upload/storage/v1/b/" + url.PathEscape(c.bucket) + "/o?uploadType=multipart"
+
+	req, err := http.NewRequest(http.MethodPost, u, &body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+
+	return c.do(req, nil)
+}
+
+func (c *gcsClient) delete(name string) error {
+	u := gcsAPI + "/b/" + url.PathEscape(c.bucket) + "/o/" + url.PathEscape(c.prefix+name)
+
+	req, err := http.NewRequest(http.MethodDelete, u, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.do(req, nil)
+}
+
+func gcsToken() (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	if name := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); name != "" {
+		return gcsServiceAccountToken(name)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var res struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := storageDo(req, &res); err != nil {
+		return "", fmt.Errorf("no credentials: %w", err)
+	}
+
+	return res.AccessToken, nil
+}
+
+// gcsServiceAccountToken exchanges a JWT signed with the service account key
+// for an access token.
+func gcsServiceAccountToken(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	var sa struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("%s: invalid private key", name)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New(name + ": private key is not RSA")
+	}
+
+	if sa.TokenURI == "" {
+		sa.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	now := time.Now()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   sa.ClientEmail,
+		"scope": gcsScope,
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	unsigned := b64(header) + "." + b64(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	sig, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + b64(sig)},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sa.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var res struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := storageDo(req, &res); err != nil {
+		return "", err
+	}
+
+	return res.AccessToken, nil
+}
@@ -0,0 +1,1005 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Generator writes the Go packages HTML files of the configured
+// repositories. Its methods are safe for concurrent use, generations are
+// run one at a time.
+type Generator struct {
+	opts  *Options
+	state atomic.Pointer[genState]
+
+	mu sync.Mutex // Serializes generations.
+
+	doneMu sync.Mutex
+	done   map[string]bool // Generated repositories.
+
+	modsMu  sync.RWMutex
+	modules map[string]*Module // By module path.
+
+	metrics *Metrics
+	stateDB *StateDB // Nil without Options.State.
+
+	signer crypto.Signer // Nil without Options.SigningKey.
+
+	apiCache *apiCache // Of the discovery directives.
+
+	// Enforced policies don't fail the generation, see policyCmd.
+	policyReport bool
+
+	// Limits of the git and go list commands, see Options.CloneJobs and
+	// Options.ListJobs.
+	cloneJobs semaphore
+	listJobs  semaphore
+
+	cmd cmdRunner
+}
+
+// Module is a Go module from a configured repository.
+type Module struct {
+	Path string
+	Repo *Repo
+
+	// Local checkout of the repository, and module root relative to it.
+	RepoDir string
+	Subdir  string
+
+	// Generated packages, the module itself included. Guarded by the
+	// generator modules lock.
+	Packages []Package
+
+	cmd cmdRunner // Of the generator.
+}
+
+func (m *Module) Dir() string {
+	return filepath.Join(m.RepoDir, filepath.FromSlash(m.Subdir))
+}
+
+type genState struct {
+	cfg    *Config
+	netrc  netrc
+	env    []string
+	locale *Locale
+	pages  *pageTemplates
+}
+
+func NewGenerator(opts *Options) (*Generator, error) {
+	g := &Generator{
+		opts:    opts,
+		done:    make(map[string]bool),
+		modules: make(map[string]*Module),
+
+		cloneJobs: newSemaphore(opts.CloneJobs),
+		listJobs:  newSemaphore(opts.ListJobs),
+
+		cmd: newCmdRunner(opts),
+
+		apiCache: newAPICache(opts),
+	}
+
+	if err := g.Reload(); err != nil {
+		return nil, err
+	}
+
+	if opts.State != "" {
+		db, err := openStateDB(opts.State)
+		if err != nil {
+			return nil, err
+		}
+
+		g.stateDB = db
+	}
+
+	if opts.SigningKey != "" {
+		key, err := readSigningKey(opts.SigningKey)
+		if err != nil {
+			return nil, err
+		}
+
+		g.signer = key
+	}
+
+	return g, nil
+}
+
+// Reload reads the configuration file and the page templates, and discovers
+// the configuration repositories again.
+// Running generations keep using the previous configuration.
+func (g *Generator) Reload() error {
+	cfg, err := readConfig(g.opts.Config)
+	if err != nil {
+		return err
+	}
+
+	nrc, err := readNetrc(g.opts.Netrc)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.discover(nrc, g.apiCache); err != nil {
+		return err
+	}
+
+	if g.opts.GitMirrorURL != "" {
+		for _, r := range cfg.Repos {
+			if r.Mirrors == nil {
+				r.Mirrors = make(map[string]string)
+			}
+
+			r.Mirrors[localMirror] = g.opts.GitMirrorURL + "/" + gitMirrorName(r)
+		}
+	}
+
+	if g.opts.PublicOnly {
+		cfg.Repos = slices.DeleteFunc(cfg.Repos, func(r *Repo) bool { return !r.Public })
+	}
+
+	locale, err := readLocale(g.opts.Lang, g.opts.Messages)
+	if err != nil {
+		return err
+	}
+
+	pages, err := readTemplates(g.opts)
+	if err != nil {
+		return err
+	}
+
+	g.state.Store(&genState{
+		cfg:    cfg,
+		netrc:  nrc,
+		env:    goEnv(g.opts, cfg),
+		locale: locale,
+		pages:  pages,
+	})
+
+	return nil
+}
+
+// Summary describes the result of a generation.
+type Summary struct {
+	Start    time.Time      `json:"start"`
+	Duration Duration       `json:"duration"`
+	Phases   Phases         `json:"phases"`
+	Repos    []*RepoSummary `json:"repos"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// Phases is the time spent in every phase of a generation: clone, list
+// (go list), render (pages) and write (site files, pruning).
+type Phases map[string]Duration
+
+func (p Phases) add(phase string, start time.Time) {
+	p[phase] += Duration(time.Since(start))
+}
+
+func (p Phases) merge(other Phases) {
+	for phase, d := range other {
+		p[phase] += d
+	}
+}
+
+type RepoSummary struct {
+	URL         string            `json:"url"`
+	Module      string            `json:"module,omitempty"`
+	Version     string            `json:"version,omitempty"`     // Latest release.
+	Go          string            `json:"go,omitempty"`          // Minimum Go version.
+	Vulns       []string          `json:"vulns,omitempty"`       // IDs of the known vulnerabilities.
+	Unpublished []string          `json:"unpublished,omitempty"` // Versions unknown to the public proxy.
+	Phases      Phases            `json:"phases"`
+	Packages    int               `json:"packages"`
+	Skipped     bool              `json:"skipped,omitempty"`
+	Refreshed   time.Time         `json:"refreshed"`         // Last clone or update of the repository.
+	Stale       bool              `json:"stale,omitempty"`   // Not refreshed for longer than Options.StaleAfter.
+	Ignored     []string          `json:"ignored,omitempty"` // Packages left out, see ignoreFile.
+	Policy      []PolicyViolation `json:"policy,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+func newSummary() *Summary {
+	return &Summary{Start: time.Now(), Phases: make(Phases)}
+}
+
+// Duration is a time.Duration encoded as a string in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (g *Generator) Generate() (sum *Summary, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sum = newSummary()
+	defer g.finish("full", sum, &err)
+	defer g.recordState(sum, &err)
+	defer g.notifyChanges(sum, &err)()
+
+	old, err := readManifest(g.opts.Output)
+	if err != nil {
+		return sum, err
+	}
+
+	if g.opts.Clean {
+		if err := removeManaged(g.opts.Output, old, nil); err != nil {
+			return sum, err
+		}
+	}
+
+	out := g.dirOutput(g.opts.Output)
+
+	if err := g.generate(sum, out); err != nil {
+		return sum, err
+	}
+
+	defer sum.Phases.add("write", time.Now())
+
+	// Pages of removed packages.
+	if err := removeManaged(g.opts.Output, old, out.manifest.names); err != nil {
+		return sum, err
+	}
+
+	return sum, g.writeManifest(out, out.manifest.names)
+}
+
+// dirOutput returns the output for the given directory, with the
+// permissions and layout of the options.
+func (g *Generator) dirOutput(dir string) DirOutput {
+	return DirOutput{
+		Dir:      dir,
+		DirMode:  g.opts.DirMode,
+		FileMode: g.opts.FileMode,
+		Layout:   layouts[g.opts.Layout],
+
+		SlashPaths: g.cmd.slashPaths,
+
+		manifest: newManifest(),
+	}
+}
+
+// GenerateTo writes all the files into the given output instead of the
+// output directory.
+func (g *Generator) GenerateTo(out Output) (sum *Summary, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sum = newSummary()
+	defer g.finish("full", sum, &err)
+
+	return sum, g.generate(sum, out)
+}
+
+// GenerateAtomic writes all the files into a new directory, and calls swap
+// with it to replace the output directory. No other generation runs until
+// swap returns.
+func (g *Generator) GenerateAtomic(swap func(next string) error) (sum *Summary, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sum = newSummary()
+	defer g.finish("full", sum, &err)
+	defer g.recordState(sum, &err)
+	defer g.notifyChanges(sum, &err)()
+
+	next := g.opts.Output + ".next"
+	if err := os.RemoveAll(next); err != nil {
+		return sum, err
+	}
+
+	out := g.dirOutput(next)
+
+	if err := g.generate(sum, out); err != nil {
+		os.RemoveAll(next)
+		return sum, err
+	}
+
+	if err := copyUnmanaged(g.opts.Output, out); err != nil {
+		os.RemoveAll(next)
+		return sum, err
+	}
+
+	if err := g.writeManifest(out, out.manifest.names); err != nil {
+		os.RemoveAll(next)
+		return sum, err
+	}
+
+	return sum, swap(next)
+}
+
+func (g *Generator) generate(sum *Summary, out Output) error {
+	if dir, ok := out.(DirOutput); ok {
+		if err := dir.mkdirAll(dir.Dir); err != nil {
+			return err
+		}
+	}
+
+	st := g.state.Load()
+
+	rss, err := g.genRepos(st, out, st.cfg.Repos)
+	for _, rs := range rss {
+		sum.Repos = append(sum.Repos, rs)
+		sum.Phases.merge(rs.Phases)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer sum.Phases.add("write", time.Now())
+
+	return g.writeSites(st, out)
+}
+
+// GenerateRepo writes the files of a single repository, given as its
+// configured modules (see ReposOf).
+func (g *Generator) GenerateRepo(repos ...*Repo) (sum *Summary, err error) {
+	return g.generateRepos(repos, true)
+}
+
+// GenerateRepos writes the files of the given repositories, leaving the
+// files of the other ones as they are. The site files (see Site) aren't
+// written, since they need every package.
+func (g *Generator) GenerateRepos(repos []*Repo) (sum *Summary, err error) {
+	return g.generateRepos(repos, false)
+}
+
+func (g *Generator) generateRepos(repos []*Repo, sites bool) (sum *Summary, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sum = newSummary()
+	defer g.finish("repo", sum, &err)
+	defer g.recordState(sum, &err)
+	defer g.notifyChanges(sum, &err)()
+
+	st := g.state.Load()
+
+	out := g.dirOutput(g.opts.Output)
+
+	rss, err := g.genRepos(st, out, repos)
+	for _, rs := range rss {
+		sum.Repos = append(sum.Repos, rs)
+		sum.Phases.merge(rs.Phases)
+	}
+
+	if err != nil {
+		return sum, err
+	}
+
+	defer sum.Phases.add("write", time.Now())
+
+	if sites {
+		if err := g.writeSites(st, out); err != nil {
+			return sum, err
+		}
+	}
+
+	names, err := readManifest(g.opts.Output)
+	if err != nil {
+		return sum, err
+	}
+
+	for name := range out.manifest.names {
+		names[name] = true
+	}
+
+	return sum, g.writeManifest(out, names)
+}
+
+// writeManifest writes the manifest of the output directory, and signs the
+// written files with Options.SigningKey.
+func (g *Generator) writeManifest(out DirOutput, names map[string]bool) error {
+	if g.signer != nil {
+		if err := signManifest(out, names, g.signer); err != nil {
+			return err
+		}
+	}
+
+	return writeManifest(out, names)
+}
+
+func (g *Generator) finish(kind string, sum *Summary, err *error) {
+	d := time.Since(sum.Start)
+	sum.Duration = Duration(d)
+
+	if *err != nil {
+		sum.Error = (*err).Error()
+	}
+
+	if g.metrics != nil {
+		g.metrics.observeGeneration(kind, d, *err)
+	}
+}
+
+// recordState records the generation into the state database, if there is
+// one. Generations into other outputs (see GenerateTo) aren't recorded.
+func (g *Generator) recordState(sum *Summary, err *error) {
+	if g.stateDB == nil {
+		return
+	}
+
+	if serr := g.stateDB.Record(sum); serr != nil && *err == nil {
+		*err = serr
+	}
+}
+
+// Pending returns the configured repositories that haven't been generated
+// yet.
+func (g *Generator) Pending() []*Repo {
+	g.doneMu.Lock()
+	defer g.doneMu.Unlock()
+
+	var repos []*Repo
+
+	for _, r := range g.state.Load().cfg.Repos {
+		if !g.done[r.key()] {
+			repos = append(repos, r)
+		}
+	}
+
+	return repos
+}
+
+func (g *Generator) genRepo(st *genState, out Output, r *Repo) (*RepoSummary, error) {
+	rs := &RepoSummary{URL: r.URL, Phases: make(Phases)}
+
+	if err := g.writeRepo(st, out, r, rs); err != nil {
+		rs.Error = err.Error()
+		return rs, err
+	}
+
+	g.markDone(r)
+
+	return rs, nil
+}
+
+func (g *Generator) writeRepo(st *genState, out Output, r *Repo, rs *RepoSummary) error {
+	repo := g.repoDir(r)
+
+	start := time.Now()
+
+	unlock, err := lockSource(repo)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	err = g.cloneJobs.do(func() error {
+		if err := g.cmd.cloneRepo(repo, r, st.netrc); err != nil {
+			return err
+		}
+
+		if g.opts.GitMirrors != "" {
+			return g.cmd.updateGitMirror(g.opts.GitMirrors, r, st.netrc)
+		}
+
+		return nil
+	})
+
+	refreshed := time.Now().UTC()
+
+	if err == nil {
+		err = markRefreshed(repo, refreshed)
+	} else if last := lastRefresh(repo); g.opts.StaleAfter > 0 && !last.IsZero() {
+		// Keep generating from the last checkout, until it is stale.
+		log.Printf("%s: %v, using the checkout of %s", r.URL, err, last.Format(time.RFC3339))
+		refreshed, err = last, nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := g.cmd.removeIgnoreAttrs(repo); err != nil {
+		return err
+	}
+
+	rs.Phases.add("clone", start)
+
+	modDir := filepath.Join(repo, filepath.FromSlash(r.Subdir))
+
+	if r.RequireGoMod {
+		if _, err := os.Stat(filepath.Join(modDir, "go.mod")); os.IsNotExist(err) {
+			rs.Skipped = true
+			return nil
+		}
+	}
+
+	start = time.Now()
+
+	var output []byte
+
+	err = g.listJobs.do(func() (err error) {
+		output, err = g.cmd.runCmdOutput(modDir, st.env, "go", "list", "-m")
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	rs.Phases.add("list", start)
+	start = time.Now()
+	defer func() { rs.Phases.add("render", start) }()
+
+	mod := &Module{
+		Path:    string(bytes.TrimSpace(output)),
+		Repo:    r,
+		RepoDir: repo,
+		Subdir:  r.Subdir,
+
+		cmd: g.cmd,
+	}
+
+	if err := checkIDNHost(mod.Path); err != nil {
+		return err
+	}
+
+	if host, _, _ := strings.Cut(mod.Path, "/"); g.opts.Host != "" && host != g.opts.Host {
+		return fmt.Errorf("module %s isn't under the %s host", mod.Path, g.opts.Host)
+	}
+
+	if err := st.cfg.checkNamespace(r, mod.Path); err != nil {
+		return err
+	}
+
+	if err := g.addModule(st.cfg, mod); err != nil {
+		return err
+	}
+
+	pkg := Package{
+		Info:   &ModuleInfo{SourcePages: g.opts.SourcePages},
+		Site:   g.siteInfo(mod.Path),
+		Locale: st.locale,
+	}
+
+	mirrors := g.opts.Mirrors
+	if mirrors == nil {
+		mirrors = st.cfg.Mirrors
+	}
+
+	pkg.Source = st.cfg.publicURL(r.SourceURL(mirrors))
+	pkg.Web = webURL(pkg.Source)
+	pkg.Subdir = r.Subdir
+	pkg.Module = mod.Path
+	pkg.ImportPath = pkg.Module
+	fallback := markdownSummary(findREADME(mod))
+	pkg.Description, pkg.RichDescription = describe(st.cfg, r, mod, pkg.ImportPath, "", fallback)
+	rs.Module = pkg.Module
+
+	if versions, err := mod.Versions(); err == nil {
+		if latest := latestSemver(versions); latest != "" {
+			pkg.Info.Latest = latest
+			pkg.Info.LatestTag = mod.TagPrefix() + latest
+			pkg.Info.LatestTime, _ = mod.VersionTime(latest)
+			rs.Version = latest
+		}
+	}
+
+	pkg.Info.Branch, _ = g.cmd.gitBranch(repo)
+
+	pkg.Info.Refreshed = refreshed
+	if g.opts.StaleAfter > 0 && time.Since(refreshed) > g.opts.StaleAfter {
+		log.Printf("%s: not updated since %s", r.URL, refreshed.Format(time.RFC3339))
+		pkg.Info.Stale = true
+	}
+
+	rs.Refreshed, rs.Stale = refreshed, pkg.Info.Stale
+
+	pkg.Info.License = detectLicense(mod)
+
+	if g.stateDB != nil {
+		pkg.Info.FirstSeen = g.stateDB.FirstSeen(mod.Path)
+		if pkg.Info.FirstSeen.IsZero() {
+			pkg.Info.FirstSeen = time.Now().UTC()
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(modDir, "go.mod")); err == nil {
+		pkg.Info.GoMod = parseGoMod(data)
+
+		for i, req := range pkg.Info.GoMod.Require {
+			pkg.Info.GoMod.Require[i].URL = g.requireURL(mod, req)
+		}
+		rs.Go = pkg.Info.GoMod.Go
+	}
+
+	if rs.Policy, err = g.checkPolicies(st.cfg, r, mod, pkg.Info); err != nil {
+		return err
+	}
+
+	if g.opts.ImportGraph {
+		err := g.listJobs.do(func() (err error) {
+			pkg.Info.Imports, err = g.cmd.listImports(modDir, st.env, mod.Path)
+			return err
+		})
+
+		if err != nil {
+			return fmt.Errorf("go list -deps: %w", err)
+		}
+	}
+
+	if g.opts.README {
+		pkg.Info.README = readREADME(mod, pkg.Web, pkg.Info.ref())
+	}
+
+	pkg.Info.Badges = r.Badges
+	pkg.Info.Meta = r.Meta
+	if len(pkg.Info.Badges) == 0 && g.opts.Badges {
+		pkg.Info.Badges = deriveBadges(pkg.Web, pkg.Info.Branch, repo)
+	}
+
+	if g.opts.Vulncheck {
+		if pkg.Info.Vulns, err = runVulncheck(mod, st.env); err != nil {
+			return fmt.Errorf("govulncheck: %w", err)
+		}
+
+		pkg.Info.VulnChecked = true
+
+		for _, v := range pkg.Info.Vulns {
+			rs.Vulns = append(rs.Vulns, v.ID)
+		}
+	}
+
+	if g.opts.PublicProxy != "" && r.Public {
+		versions, err := mod.Versions()
+		if err != nil {
+			return err
+		}
+
+		if pkg.Info.Unpublished, err = unpublishedVersions(g.opts.PublicProxy, mod.Path, versions); err != nil {
+			return fmt.Errorf("public proxy: %w", err)
+		}
+
+		pkg.Info.PublishChecked = true
+		rs.Unpublished = pkg.Info.Unpublished
+	}
+
+	if g.opts.Contributors {
+		if pkg.Info.Contributors, err = readContributors(mod, g.opts.ContributorEmails); err != nil {
+			return err
+		}
+	}
+
+	if g.opts.Changelog {
+		if pkg.Info.Changelog, err = writeChangelog(out, mod, pkg, pkg.Web); err != nil {
+			return err
+		}
+	}
+
+	if g.opts.SBOM != "" {
+		if pkg.Info.SBOM, err = writeSBOM(out, mod, pkg.Info, g.opts.SBOM, pkg.flat()); err != nil {
+			return fmt.Errorf("sbom: %w", err)
+		}
+	}
+
+	pkgs := []Package{pkg}
+
+	rs.Phases.add("render", start)
+	start = time.Now()
+
+	err = g.listJobs.do(func() (err error) {
+		output, err = g.cmd.runCmdOutput(modDir, st.env, "go", "list",
+			"-f", "{{ .ImportPath }}\t{{ .Dir }}\t{{ join .GoFiles \"/\" }}\t{{ .Doc }}",
+			"./...",
+		)
+
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	rs.Phases.add("list", start)
+	start = time.Now()
+
+	type listedPackage struct {
+		importPath, dir, doc string
+
+		rel   string // Slash path of dir relative to the checkout.
+		files []string
+	}
+
+	var (
+		entries []listedPackage
+		paths   []string
+	)
+
+	root, err := filepath.Abs(repo) // go list directories are absolute.
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		x := strings.SplitN(line, "\t", 4) // Doc may be empty.
+		if len(x) < 4 {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, x[1])
+		if err != nil {
+			return err
+		}
+
+		e := listedPackage{importPath: x[0], dir: x[1], doc: x[3], rel: filepath.ToSlash(rel)}
+		if x[2] != "" {
+			e.files = strings.Split(x[2], "/") // File names can't have slashes.
+		}
+
+		entries = append(entries, e)
+		paths = append(paths, exportPaths(e.rel, e.files)...)
+	}
+
+	// Packages left out by the export-ignore attributes and the ignore file
+	// aren't in the catalog, the module itself is always there.
+	attrs, err := g.cmd.readExportAttrs(repo, paths)
+	if err != nil {
+		return err
+	}
+
+	listed := map[string]bool{mod.Path: true}
+
+	entries = slices.DeleteFunc(entries, func(e listedPackage) bool {
+		if e.importPath != mod.Path && attrs.ignored(e.rel, e.files) {
+			rs.Ignored = append(rs.Ignored, e.importPath)
+			return true
+		}
+
+		listed[e.importPath] = true
+
+		return false
+	})
+
+	for _, e := range entries {
+		pkg.ImportPath = e.importPath
+		pkg.Breadcrumbs = breadcrumbs(mod.Path, pkg.ImportPath, listed, pkg.flat())
+
+		synopsis := e.doc
+		if attrs.substituted(e.rel, e.files) {
+			synopsis = g.cmd.expandSubst(repo, synopsis)
+		}
+
+		pkg.Description, pkg.RichDescription = describe(st.cfg, r, mod, pkg.ImportPath, synopsis, fallback)
+
+		pkg.Doc = nil
+		if g.opts.API || g.opts.Examples || g.opts.Symbols {
+			if pkg.Doc, err = readPackageDoc(e.dir, pkg.ImportPath, g.opts); err != nil {
+				return err
+			}
+		}
+
+		pkg.Files = nil
+		if g.opts.SourcePages {
+			if pkg.Files, err = writeSourcePages(out, e.dir, pkg); err != nil {
+				return err
+			}
+		}
+
+		if pkg.ImportPath == mod.Path {
+			pkgs[0] = pkg
+		} else {
+			pkgs = append(pkgs, pkg)
+		}
+
+		rs.Packages++
+	}
+
+	// Pages are written once every package is known, for listing them.
+	info := pkgs[0].Info
+
+	for _, p := range pkgs {
+		info.Packages = append(info.Packages, PackageRef{ImportPath: p.ImportPath, Description: p.Description, RichDescription: p.RichDescription, flat: p.flat()})
+	}
+
+	slices.SortFunc(info.Packages, func(a, b PackageRef) int {
+		return strings.Compare(a.ImportPath, b.ImportPath)
+	})
+
+	page := formats[g.opts.Format].page
+	if g.opts.Format == "html" {
+		page = st.pages.write
+	}
+
+	for _, p := range pkgs {
+		if err := page(out, p); err != nil {
+			return err
+		}
+
+		if g.opts.Format == "html" {
+			if err := writePackageData(out, p, g.opts.PackageData); err != nil {
+				return err
+			}
+		}
+	}
+
+	if g.opts.GoBadge {
+		if err := writeGoBadge(out, pkgs[0]); err != nil {
+			return err
+		}
+	}
+
+	g.modsMu.Lock()
+	mod.Packages = pkgs
+	g.modsMu.Unlock()
+
+	return nil
+}
+
+// siteInfo returns the site information of the module pages.
+func (g *Generator) siteInfo(modPath string) *SiteInfo {
+	host, _, _ := strings.Cut(modPath, "/")
+
+	return &SiteInfo{
+		Title:     cmp.Or(g.opts.Title, hostToUnicode(host)),
+		BaseURL:   cmp.Or(g.opts.BaseURL, "https://"+host),
+		Flat:      g.opts.Layout == "flat",
+		Generated: time.Now().UTC(),
+	}
+}
+
+// describe returns the description of the import path: its configured
+// description, the repository description for the module root, the package
+// synopsis or, if the package has no doc comment, the fallback. Configured
+// HTML descriptions also return their sanitized HTML.
+func describe(cfg *Config, r *Repo, mod *Module, importPath, synopsis, fallback string) (string, template.HTML) {
+	if d, ok := cfg.Descriptions[importPath]; ok {
+		return d, cfg.RichDescriptions[importPath]
+	}
+
+	if importPath == mod.Path && r.Description != "" {
+		return r.Description, r.RichDescription
+	}
+
+	if synopsis == "" {
+		return fallback, ""
+	}
+
+	return synopsis, ""
+}
+
+func (g *Generator) markDone(r *Repo) {
+	g.doneMu.Lock()
+	g.done[r.key()] = true
+	g.doneMu.Unlock()
+}
+
+// Module returns the generated module with the given path.
+func (g *Generator) Module(path string) *Module {
+	g.modsMu.RLock()
+	defer g.modsMu.RUnlock()
+
+	return g.modules[path]
+}
+
+// addModule records the module of a repository, replacing its previous one.
+// Modules can't have the path of the module of another repository.
+func (g *Generator) addModule(cfg *Config, mod *Module) error {
+	g.modsMu.Lock()
+	defer g.modsMu.Unlock()
+
+	for p, o := range g.modules {
+		if o.sameRepo(mod) && p != mod.Path {
+			delete(g.modules, p)
+		}
+	}
+
+	if o := g.modules[mod.Path]; o != nil && !o.sameRepo(mod) && cfg.hasRepo(o.Repo) {
+		return fmt.Errorf("module %s of %s is already the module of %s", mod.Path, mod.Repo.URL, o.Repo.URL)
+	}
+
+	g.modules[mod.Path] = mod
+
+	return nil
+}
+
+// sameRepo reports if the modules are from the same repository and
+// subdirectory.
+func (m *Module) sameRepo(o *Module) bool {
+	return m.Repo.key() == o.Repo.key()
+}
+
+// modulePaths returns the paths of the generated modules.
+func (g *Generator) modulePaths() []string {
+	g.modsMu.RLock()
+	defer g.modsMu.RUnlock()
+
+	paths := make([]string, 0, len(g.modules))
+	for p := range g.modules {
+		paths = append(paths, p)
+	}
+
+	return paths
+}
+
+// Teams returns the configured teams.
+func (g *Generator) Teams() []*Team {
+	return g.state.Load().cfg.Teams
+}
+
+// Repos returns the configured repositories.
+func (g *Generator) Repos() []*Repo {
+	return g.state.Load().cfg.Repos
+}
+
+// ReposOf returns the configured modules of the repository with the given
+// URL, one per subdirectory.
+func (g *Generator) ReposOf(url string) []*Repo {
+	key := repoKey(url)
+
+	var repos []*Repo
+
+	for _, r := range g.state.Load().cfg.Repos {
+		if repoKey(r.URL) == key {
+			repos = append(repos, r)
+		}
+	}
+
+	return repos
+}
+
+// goEnv returns the environment for running go commands, so the Go
+// toolchain authenticates with the same credentials. Variables from the
+// configuration file take precedence over the process environment.
+func goEnv(opts *Options, cfg *Config) []string {
+	env := os.Environ()
+
+	if opts.Netrc != "" {
+		env = append(env, "NETRC="+opts.Netrc)
+	}
+
+	return append(env, cfg.Env...)
+}
+
+// readREADME returns the rendered README of the module, from its directory
+// or the repository root. Relative links are resolved against the
+// repository URL, at the given branch.
+func readREADME(mod *Module, repoURL, branch string) template.HTML {
+	if data := findREADME(mod); data != nil {
+		return renderMarkdown(data, repoURL, branch)
+	}
+
+	return ""
+}
+
+// findREADME returns the content of the module README, or the repository
+// one.
+func findREADME(mod *Module) []byte {
+	for _, dir := range []string{mod.Dir(), mod.RepoDir} {
+		for _, name := range []string{"README.md", "README.markdown", "readme.md"} {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+
+			if bytes.Contains(data, []byte("$Format:")) {
+				rel, _ := filepath.Rel(mod.RepoDir, filepath.Join(dir, name))
+				rel = filepath.ToSlash(rel)
+
+				if attrs, err := mod.cmd.readExportAttrs(mod.RepoDir, []string{rel}); err == nil && attrs.subst[rel] {
+					data = []byte(mod.cmd.expandSubst(mod.RepoDir, string(data)))
+				}
+			}
+
+			return data
+		}
+	}
+
+	return nil
+}
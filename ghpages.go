@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+)
+
+// writeGHPages writes the files GitHub Pages needs for serving the site
+// from a custom domain: CNAME, .nojekyll (so files are published as they
+// are) and a root index if there is no package at the root.
+func writeGHPages(s *Site) error {
+	if err := s.writeFile("CNAME", []byte(s.Host+"\n")); err != nil {
+		return err
+	}
+
+	if err := s.writeFile(".nojekyll", nil); err != nil {
+		return err
+	}
+
+	for _, pkg := range s.Packages {
+		if s.Path(pkg) == "/" {
+			return nil
+		}
+	}
+
+	return writeListing(s.Host, s.Packages, s.PageSize, s.Flat, func(name string, pkgs []Package, pager Pager) error {
+		data := struct {
+			*Site
+			Packages []Package
+			Pager    Pager
+		}{s, pkgs, pager}
+
+		return writeTemplate(s.out, name, siteIndexTmpl, data)
+	})
+}
+
+var siteIndexTmpl = template.Must(template.Must(template.New("site").Parse(pagerTmpl)).Parse(`<!DOCTYPE html>
+<html lang="{{ .Locale.HTMLLang }}">
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="viewport" content="width=device-width, initial-scale=1"/>
+  <link rel="stylesheet" href="/style.css"/>
+  <title>{{ .DisplayHost }}</title>
+</head>
+<body>
+  <h1>{{ .DisplayHost }}</h1>
+  <ul>
+  {{- range .Packages }}
+    <li><a href="{{ $.Path . }}">{{ .DisplayPath }}</a>{{ if .Description }} - {{ .DescriptionHTML }}{{ end }}</li>
+  {{- end }}
+  </ul>
+  {{- template "pager" . }}
+</body>
+</html>
+`))
+
+// PublishGHPages commits the generated site to the gh-pages branch of the
+// given repository and pushes it, replacing the branch history. Credentials
+// of the repository are used if it is configured.
+func (g *Generator) PublishGHPages(url string) error {
+	st := g.state.Load()
+
+	sites := g.sites(st, g.dirOutput(g.opts.Output))
+	if len(sites) != 1 {
+		return errors.New("gh-pages: the output must have exactly one host")
+	}
+
+	repo := &Repo{URL: url}
+	if repos := g.ReposOf(url); repos != nil {
+		repo = repos[0] // Modules of a repository share its credentials.
+	}
+
+	env, err := gitEnv(repo, st.netrc)
+	if err != nil {
+		return fmt.Errorf("gh-pages: %w", err)
+	}
+
+	gitDir, err := os.MkdirTemp("", "vanitic-gh-pages-")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(gitDir)
+
+	workTree, err := filepath.Abs(sites[0].Dir)
+	if err != nil {
+		return err
+	}
+
+	git := func(args ...string) error {
+		args = append([]string{"--git-dir", g.cmd.cmdPath(gitDir), "--work-tree", g.cmd.cmdPath(workTree)}, args...)
+		return g.cmd.runCmd(workTree, env, gitCmd(env != nil, args...)...)
+	}
+
+	commit := []string{"commit", "-q", "-m", "Update vanity pages"}
+	if _, err := g.cmd.runCmdOutput(workTree, env, "git", "config", "user.email"); err != nil {
+		commit = append([]string{"-c", "user.name=vanitic", "-c", "user.email=vanitic@localhost"}, commit...)
+	}
+
+	steps := [][]string{
+		{"init", "-q"},
+		{"symbolic-ref", "HEAD", "refs/heads/gh-pages"},
+		{"add", "-A"},
+		commit,
+		{"push", "-q", "--force", repo.URL, "HEAD:refs/heads/gh-pages"},
+	}
+
+	for _, args := range steps {
+		if err := git(args...); err != nil {
+			return fmt.Errorf("gh-pages: git %s: %w", args[0], err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Git asks for HTTPS credentials by running GIT_ASKPASS, which is vanitic
+// itself in askpass mode. Credentials are passed down through the
+// environment, so they are never written to disk or shown in command lines.
+const (
+	askpassEnv         = "VANITIC_ASKPASS"
+	askpassUserEnv     = "VANITIC_ASKPASS_USER"
+	askpassPasswordEnv = "VANITIC_ASKPASS_PASSWORD"
+)
+
+func askpass(args []string) {
+	prompt := ""
+	if len(args) > 0 {
+		prompt = strings.ToLower(args[0])
+	}
+
+	if strings.HasPrefix(prompt, "username") {
+		fmt.Println(os.Getenv(askpassUserEnv))
+		return
+	}
+
+	fmt.Println(os.Getenv(askpassPasswordEnv))
+}
+
+// cloneRepo clones the repository into dst, or updates it to the remote
+// default branch if it already exists. Callers running concurrently with
+// other processes must hold the source lock (see lockSource).
+func (c cmdRunner) cloneRepo(dst string, repo *Repo, nrc netrc) error {
+	if dst == "" {
+		dst = repoName(repo.URL)
+	}
+
+	env, err := gitEnv(repo, nrc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", repo.URL, err)
+	}
+
+	if _, err := os.Stat(dst); err == nil {
+		branch, err := c.remoteBranch(dst, env)
+		if err != nil {
+			return err
+		}
+
+		if err := c.runCmd(dst, env, gitCmd(env != nil, "fetch", "-q", "--tags", "--force", "origin", cmp.Or(branch, "HEAD"))...); err != nil {
+			return err
+		}
+
+		// Checkouts only follow the remote, local changes are discarded
+		// instead of merged.
+		if branch == "" {
+			return c.runCmd(dst, nil, "git", "reset", "-q", "--hard", "FETCH_HEAD")
+		}
+
+		return c.runCmd(dst, nil, "git", "checkout", "-q", "-f", "-B", branch, "FETCH_HEAD")
+	}
+
+	// Clones are moved into place once complete, so interrupted ones are
+	// started over.
+	tmp := dst + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+
+	if err := c.runCmd(".", env, gitCmd(env != nil, "clone", repo.URL, c.cmdPath(tmp))...); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+func gitCmd(auth bool, args ...string) []string {
+	cmd := []string{"git"}
+
+	// Credential helpers are disabled when using askpass, so its credentials
+	// can't be stored by them.
+	if auth {
+		cmd = append(cmd, "-c", "credential.helper=")
+	}
+
+	// Import paths of deep packages easily exceed MAX_PATH.
+	if runtime.GOOS == "windows" {
+		cmd = append(cmd, "-c", "core.longpaths=true")
+	}
+
+	return append(cmd, args...)
+}
+
+// gitEnv returns the environment for running git commands on the given
+// repository. Credentials are taken from the repository token, or from the
+// netrc file if the repository has no token. Team repositories only use
+// their token (see Team), the shared credentials of the netrc file and the
+// credential helpers aren't available to them.
+func gitEnv(repo *Repo, nrc netrc) ([]string, error) {
+	user, password := repo.User, ""
+
+	if repo.Token != "" {
+		token, err := readSecret(repo.Token)
+		if err != nil {
+			return nil, err
+		}
+
+		password = token
+	} else if repo.Team == "" {
+		u, err := url.Parse(repo.URL)
+		if err != nil || u.Scheme != "https" {
+			return nil, nil
+		}
+
+		login, pw, ok := nrc.lookup(u.Hostname())
+		if !ok {
+			return nil, nil
+		}
+
+		user, password = login, pw
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	if user == "" {
+		user = "git"
+	}
+
+	env := append(os.Environ(),
+		"GIT_ASKPASS="+exe,
+		"GIT_TERMINAL_PROMPT=0",
+		askpassEnv+"=1",
+		askpassUserEnv+"="+user,
+		askpassPasswordEnv+"="+password,
+	)
+
+	return env, nil
+}
+
+func (c cmdRunner) gitTags(dir string) ([]string, error) {
+	output, err := c.runCmdOutput(dir, nil, "git", "tag", "--list")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(string(output)), nil
+}
+
+// remoteBranch returns the default branch of the origin remote of the
+// checkout, empty if the remote doesn't tell (like dumb HTTP servers).
+func (c cmdRunner) remoteBranch(dir string, env []string) (string, error) {
+	output, err := c.runCmdOutput(dir, env, gitCmd(env != nil, "ls-remote", "--symref", "origin", "HEAD")...)
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if ref, ok := strings.CutPrefix(line, "ref: refs/heads/"); ok {
+			branch, _, _ := strings.Cut(ref, "\t")
+			return branch, nil
+		}
+	}
+
+	return "", nil
+}
+
+// gitBranch returns the checked out branch.
+func (c cmdRunner) gitBranch(dir string) (string, error) {
+	output, err := c.runCmdOutput(dir, nil, "git", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// gitRevision returns the commit hash of the given revision.
+func (c cmdRunner) gitRevision(dir, rev string) (string, error) {
+	output, err := c.runCmdOutput(dir, nil, "git", "rev-parse", "--verify", "-q", rev+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// gitCommitTime returns the committer time of the given revision.
+func (c cmdRunner) gitCommitTime(dir, rev string) (time.Time, error) {
+	output, err := c.runCmdOutput(dir, nil, "git", "log", "-1", "--format=%cI", rev+"^{commit}", "--")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(output)))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return t.UTC(), nil
+}
+
+// gitTagMessage returns the message of an annotated tag, or an empty
+// string for lightweight tags.
+func (c cmdRunner) gitTagMessage(dir, tag string) (string, error) {
+	output, err := c.runCmdOutput(dir, nil, "git", "for-each-ref",
+		"--format=%(if:equals=tag)%(objecttype)%(then)%(contents)%(end)",
+		"refs/tags/"+tag,
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// gitShow returns the content of a file at the given revision.
+func (c cmdRunner) gitShow(dir, rev, name string) ([]byte, error) {
+	return c.runCmdOutput(dir, nil, "git", "show", rev+":"+name)
+}
+
+// gitArchive returns a tar archive of the given revision, limited to the
+// given subdirectory if not empty. Files marked with export-ignore are not
+// included. Line endings are kept as committed whatever the git
+// configuration is, so archives of a revision are always the same.
+func (c cmdRunner) gitArchive(dir, rev, subdir string) ([]byte, error) {
+	args := []string{"git", "-c", "core.autocrlf=input", "-c", "core.eol=lf", "archive", "--format=tar", rev}
+	if subdir != "" {
+		args = append(args, "--", subdir)
+	}
+
+	return c.runCmdOutput(dir, nil, args...)
+}
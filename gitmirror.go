@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// localMirror is the name of the bare mirrors of Options.GitMirrors in
+// mirror orders.
+const localMirror = "local"
+
+// gitMirrorName returns the slash path of the bare mirror of the repository,
+// relative to the mirrors directory (see repoPath).
+func gitMirrorName(r *Repo) string {
+	return repoPath(r.URL) + ".git"
+}
+
+// updateGitMirror clones the repository as a bare mirror into dir, or
+// fetches it if it already exists. Files for serving the mirror with the
+// dumb HTTP protocol (static hosts) are updated too, git-http-backend
+// serves it as it is.
+func (c cmdRunner) updateGitMirror(dir string, r *Repo, nrc netrc) error {
+	env, err := gitEnv(r, nrc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r.URL, err)
+	}
+
+	dst := filepath.Join(dir, filepath.FromSlash(gitMirrorName(r)))
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(dst + ".lock")
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	if _, err := os.Stat(dst); err == nil {
+		err = c.runCmd(dst, env, gitCmd(env != nil, "remote", "update", "--prune")...)
+	} else if err = c.runCmd(".", env, gitCmd(env != nil, "clone", "--mirror", r.URL, c.cmdPath(dst))...); err != nil {
+		os.RemoveAll(dst)
+	}
+
+	if err != nil {
+		return fmt.Errorf("git mirror %s: %w", r.URL, err)
+	}
+
+	return c.runCmd(dst, nil, "git", "update-server-info")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"path"
+)
+
+// goBadgeDir is the directory of the module badges, relative to its pages.
+const goBadgeDir = "badge"
+
+// GoBadge is the Go version badge of a module, in the shields.io endpoint
+// format (https://shields.io/badges/endpoint-badge).
+type GoBadge struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+// writeGoBadge writes the badge of the go directive of the module, as
+// badge/go.json for shields.io and badge/go.svg. Modules without go
+// directive don't get one.
+func writeGoBadge(out Output, pkg Package) error {
+	if pkg.Info == nil || pkg.Info.GoMod == nil || pkg.Info.GoMod.Go == "" {
+		return nil
+	}
+
+	b := GoBadge{
+		SchemaVersion: 1,
+		Label:         "Go",
+		Message:       "≥ " + pkg.Info.GoMod.Go,
+		Color:         "00add8",
+	}
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	dir := path.Join(pkg.ImportPath, goBadgeDir)
+	if err := out.WriteFile(path.Join(dir, "go.json"), append(data, '\n')); err != nil {
+		return err
+	}
+
+	return writeTemplate(out, path.Join(dir, "go.svg"), goBadgeTmpl, struct {
+		GoBadge
+		LabelWidth, MessageWidth int
+	}{b, badgeTextWidth(b.Label), badgeTextWidth(b.Message)})
+}
+
+// badgeTextWidth approximates the width in pixels of a badge text, 11px
+// Verdana averages 7px per character.
+func badgeTextWidth(s string) int {
+	return len([]rune(s))*7 + 10
+}
+
+var goBadgeTmpl = template.Must(template.New("go-badge").Funcs(template.FuncMap{
+	"add":  func(a, b int) int { return a + b },
+	"half": func(n int) int { return n / 2 },
+}).Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{ add .LabelWidth .MessageWidth }}" height="20" role="img" aria-label="{{ .Label }}: {{ .Message }}">
+  <title>{{ .Label }}: {{ .Message }}</title>
+  <rect width="{{ .LabelWidth }}" height="20" fill="#555"/>
+  <rect x="{{ .LabelWidth }}" width="{{ .MessageWidth }}" height="20" fill="#{{ .Color }}"/>
+  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+    <text x="{{ half .LabelWidth }}" y="14">{{ .Label }}</text>
+    <text x="{{ add .LabelWidth (half .MessageWidth) }}" y="14">{{ .Message }}</text>
+  </g>
+</svg>
+`))
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// GoModFile is the subset of a go.mod file shown on the module pages.
+type GoModFile struct {
+	Module    string
+	Go        string // Minimum Go version.
+	Toolchain string
+	Require   []ModRequire
+	Replace   []ModReplace
+}
+
+// ModReplace is a replace directive, New is a module path or a local
+// directory. Versions are empty if not given.
+type ModReplace struct {
+	Old, New               string
+	OldVersion, NewVersion string
+}
+
+// Local reports if the replacement is a directory.
+func (r ModReplace) Local() bool {
+	return strings.HasPrefix(r.New, "./") || strings.HasPrefix(r.New, "../") || strings.HasPrefix(r.New, "/") || r.New == "." || r.New == ".."
+}
+
+// ModRequire is a module requirement.
+type ModRequire struct {
+	Path     string
+	Version  string
+	Indirect bool
+
+	URL string // Page of the module, set by the generator.
+}
+
+// DisplayPath returns the module path with its host in Unicode form.
+func (r ModRequire) DisplayPath() string {
+	return displayPath(r.Path)
+}
+
+// Direct returns the direct requirements.
+func (gm *GoModFile) Direct() []ModRequire {
+	var reqs []ModRequire
+
+	for _, r := range gm.Require {
+		if !r.Indirect {
+			reqs = append(reqs, r)
+		}
+	}
+
+	return reqs
+}
+
+// parseGoMod parses the directives of a go.mod file, unknown ones and
+// malformed lines are ignored.
+func parseGoMod(data []byte) *GoModFile {
+	gm := &GoModFile{}
+
+	var block string // Directive of the open block, if any.
+
+	for _, line := range strings.Split(string(data), "\n") {
+		var comment string
+		if i := strings.Index(line, "//"); i >= 0 {
+			line, comment = line[:i], line[i+2:]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if block != "" {
+			if fields[0] == ")" {
+				block = ""
+			} else if block == "require" && len(fields) == 2 {
+				gm.require(fields, comment)
+			} else if block == "replace" {
+				gm.replace(fields)
+			}
+
+			continue
+		}
+
+		if len(fields) == 2 && fields[1] == "(" {
+			block = fields[0]
+			continue
+		}
+
+		switch {
+		case fields[0] == "module" && len(fields) == 2:
+			gm.Module = strings.Trim(fields[1], `"`)
+		case fields[0] == "go" && len(fields) == 2:
+			gm.Go = fields[1]
+		case fields[0] == "toolchain" && len(fields) == 2:
+			gm.Toolchain = fields[1]
+		case fields[0] == "require" && len(fields) == 3:
+			gm.require(fields[1:], comment)
+		case fields[0] == "replace":
+			gm.replace(fields[1:])
+		}
+	}
+
+	return gm
+}
+
+func (gm *GoModFile) require(fields []string, comment string) {
+	gm.Require = append(gm.Require, ModRequire{
+		Path:     strings.Trim(fields[0], `"`),
+		Version:  fields[1],
+		Indirect: strings.HasPrefix(strings.TrimSpace(comment), "indirect"),
+	})
+}
+
+// replace adds a replacement from the fields of a replace directive, like
+// "old [version] => new [version]".
+func (gm *GoModFile) replace(fields []string) {
+	i := slices.Index(fields, "=>")
+	if i < 1 || i+1 >= len(fields) {
+		return
+	}
+
+	r := ModReplace{
+		Old: strings.Trim(fields[0], `"`),
+		New: strings.Trim(fields[i+1], `"`),
+	}
+
+	if i == 2 {
+		r.OldVersion = fields[1]
+	}
+
+	if i+2 < len(fields) {
+		r.NewVersion = fields[i+2]
+	}
+
+	gm.Replace = append(gm.Replace, r)
+}
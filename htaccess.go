@@ -0,0 +1,56 @@
+package main
+
+import (
+	"text/template"
+)
+
+// writeHtaccess writes an Apache .htaccess file for the site directory,
+// which must be the document root. Directories are rewritten to their pages
+// instead of being redirected with a trailing slash.
+func writeHtaccess(s *Site) error {
+	data := map[string]any{
+		"Site":         s,
+		"Headers":      securityHeaders,
+		"CacheControl": pagesCacheControl,
+		"GoGetPage":    goGetPage,
+	}
+
+	return s.writeTemplate(".htaccess", htaccessTmpl, data)
+}
+
+var htaccessTmpl = template.Must(template.New("htaccess").Parse(`# Generated by vanitic.
+
+AddDefaultCharset utf-8
+Options -Indexes
+DirectorySlash Off
+
+<IfModule mod_headers.c>
+{{- range .Headers }}
+  Header set {{ index . 0 }} "{{ index . 1 }}"
+{{- end }}
+  Header set Cache-Control "{{ .CacheControl }}"
+</IfModule>
+
+<IfModule mod_rewrite.c>
+  RewriteEngine On
+  RewriteBase /
+
+  RewriteCond %{QUERY_STRING} (^|&)go-get=1(&|$)
+  RewriteCond %{REQUEST_FILENAME}/{{ .GoGetPage }} -f
+  RewriteRule ^(.*?)/?$ $1/{{ .GoGetPage }} [L]
+{{- if .Site.DocsRedirect }}
+
+  RewriteCond %{QUERY_STRING} !(^|&)go-get=1(&|$)
+  RewriteCond %{REQUEST_FILENAME}{{ if .Site.Flat }}.html{{ else }}/index.html{{ end }} -f
+  RewriteRule ^(.*?)/?$ https://pkg.go.dev/{{ .Site.Host }}/$1 [R=302,L]
+{{- end }}
+
+  RewriteCond %{REQUEST_FILENAME}/index.html -f
+  RewriteRule ^(.*?)/?$ $1/index.html [L]
+{{- if .Site.Flat }}
+
+  RewriteCond %{REQUEST_FILENAME}.html -f
+  RewriteRule ^(.*?)/?$ $1.html [L]
+{{- end }}
+</IfModule>
+`))
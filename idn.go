@@ -0,0 +1,298 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Vanity hosts with non-ASCII labels are used in their ASCII form (IDNA,
+// with punycode labels prefixed by "xn--") in import paths, meta tags and
+// the output directories, and shown in their Unicode form on the pages.
+
+const acePrefix = "xn--"
+
+// hostToASCII returns the ASCII form of the host.
+func hostToASCII(host string) (string, error) {
+	labels := strings.Split(host, ".")
+
+	for i, l := range labels {
+		if isASCII(l) {
+			labels[i] = strings.ToLower(l)
+			continue
+		}
+
+		enc, err := punyEncode(strings.ToLower(l))
+		if err != nil {
+			return "", fmt.Errorf("host %q: %w", host, err)
+		}
+
+		labels[i] = acePrefix + enc
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// hostToUnicode returns the Unicode form of the host, labels that can't be
+// decoded are kept as they are.
+func hostToUnicode(host string) string {
+	labels := strings.Split(host, ".")
+
+	for i, l := range labels {
+		if rest, ok := strings.CutPrefix(strings.ToLower(l), acePrefix); ok {
+			if dec, err := punyDecode(rest); err == nil {
+				labels[i] = dec
+			}
+		}
+	}
+
+	return strings.Join(labels, ".")
+}
+
+// checkIDNHost reports an error if the host of the import path isn't in
+// ASCII form, or has punycode labels that don't round-trip.
+func checkIDNHost(importPath string) error {
+	host, _, _ := strings.Cut(importPath, "/")
+
+	for _, l := range strings.Split(host, ".") {
+		if !isASCII(l) {
+			return fmt.Errorf("%s: host must be in its ASCII form", importPath)
+		}
+
+		rest, ok := strings.CutPrefix(l, acePrefix)
+		if !ok {
+			continue
+		}
+
+		dec, err := punyDecode(rest)
+		if err != nil {
+			return fmt.Errorf("%s: invalid label %q: %w", importPath, l, err)
+		}
+
+		if enc, err := punyEncode(dec); err != nil || acePrefix+enc != l {
+			return fmt.Errorf("%s: label %q isn't in canonical form", importPath, l)
+		}
+	}
+
+	return nil
+}
+
+// displayPath returns the import path with its host in Unicode form.
+func displayPath(importPath string) string {
+	host, rest, ok := strings.Cut(importPath, "/")
+
+	host = hostToUnicode(host)
+	if ok {
+		return host + "/" + rest
+	}
+
+	return host
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Punycode parameters, from RFC 3492.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+var errPunycode = errors.New("invalid punycode")
+
+func punyAdapt(delta, numPoints int32, first bool) int32 {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+
+	delta += delta / numPoints
+
+	k := int32(0)
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyThreshold(k, bias int32) int32 {
+	switch t := k - bias; {
+	case t < punyTMin:
+		return punyTMin
+	case t > punyTMax:
+		return punyTMax
+	default:
+		return t
+	}
+}
+
+func punyDigit(d int32) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+
+	return byte('0' + d - 26)
+}
+
+func punyEncode(s string) (string, error) {
+	var b strings.Builder
+
+	runes := []rune(s)
+
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+		}
+	}
+
+	basic := int32(b.Len())
+	h := basic
+
+	if basic > 0 {
+		b.WriteByte('-')
+	}
+
+	n, delta, bias := int32(punyInitialN), int32(0), int32(punyInitialBias)
+
+	for h < int32(len(runes)) {
+		m := int32(utf8.MaxRune + 1)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+
+		delta += (m - n) * (h + 1)
+		if delta < 0 {
+			return "", errPunycode
+		}
+
+		n = m
+
+		for _, r := range runes {
+			if r < n {
+				delta++
+				if delta < 0 {
+					return "", errPunycode
+				}
+			}
+
+			if r != n {
+				continue
+			}
+
+			q := delta
+			for k := int32(punyBase); ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+
+				b.WriteByte(punyDigit(t + (q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+
+			b.WriteByte(punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == basic)
+			delta = 0
+			h++
+		}
+
+		delta++
+		n++
+	}
+
+	return b.String(), nil
+}
+
+func punyDecode(s string) (string, error) {
+	var output []rune
+
+	pos := 0
+	if i := strings.LastIndex(s, "-"); i >= 0 {
+		for _, r := range s[:i] {
+			if r >= utf8.RuneSelf {
+				return "", errPunycode
+			}
+
+			output = append(output, r)
+		}
+
+		pos = i + 1
+	}
+
+	n, i, bias := int32(punyInitialN), int32(0), int32(punyInitialBias)
+
+	for pos < len(s) {
+		oldI, w := i, int32(1)
+
+		for k := int32(punyBase); ; k += punyBase {
+			if pos >= len(s) {
+				return "", errPunycode
+			}
+
+			c := s[pos]
+			pos++
+
+			var digit int32
+
+			switch {
+			case 'a' <= c && c <= 'z':
+				digit = int32(c - 'a')
+			case 'A' <= c && c <= 'Z':
+				digit = int32(c - 'A')
+			case '0' <= c && c <= '9':
+				digit = int32(c-'0') + 26
+			default:
+				return "", errPunycode
+			}
+
+			i += digit * w
+			if i < 0 {
+				return "", errPunycode
+			}
+
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+
+			w *= punyBase - t
+			if w < 0 {
+				return "", errPunycode
+			}
+		}
+
+		x := int32(len(output) + 1)
+		bias = punyAdapt(i-oldI, x, oldI == 0)
+		n += i / x
+		i %= x
+
+		if n > utf8.MaxRune || n < punyInitialN {
+			return "", errPunycode
+		}
+
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = n
+		i++
+	}
+
+	return string(output), nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"slices"
+	"strings"
+)
+
+const (
+	importsPage  = "imports.html"
+	importsDOT   = "imports.dot"
+	importsGraph = "imports.svg"
+)
+
+// listImports returns the modules of the packages imported by the module in
+// dir, itself and the standard library excluded, sorted.
+func (c cmdRunner) listImports(dir string, env []string, modPath string) ([]string, error) {
+	output, err := c.runCmdOutput(dir, env, "go", "list", "-e", "-deps",
+		"-f", "{{ with .Module }}{{ .Path }}{{ end }}",
+		"./...",
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var mods []string
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if p := strings.TrimSpace(line); p != "" && p != modPath && !slices.Contains(mods, p) {
+			mods = append(mods, p)
+		}
+	}
+
+	slices.Sort(mods)
+
+	return mods, nil
+}
+
+// importsNode is a module of the import graph.
+type importsNode struct {
+	Package
+	Imports []string // Site modules imported by its packages.
+	Depth   int      // Longest import chain down to a module without imports.
+
+	X, Y, Width int // Position in the rendered graph.
+}
+
+// writeImportGraph writes the graph of the imports between the site
+// modules, as a DOT file, an SVG rendering of it and a page showing both.
+func writeImportGraph(s *Site) error {
+	var nodes []*importsNode
+
+	byPath := make(map[string]*importsNode)
+
+	for _, pkg := range s.Packages {
+		if pkg.IsModule() {
+			n := &importsNode{Package: pkg}
+			nodes = append(nodes, n)
+			byPath[pkg.Module] = n
+		}
+	}
+
+	for _, n := range nodes {
+		if n.Info == nil {
+			continue
+		}
+
+		for _, p := range n.Info.Imports {
+			if _, ok := byPath[p]; ok {
+				n.Imports = append(n.Imports, p)
+			}
+		}
+	}
+
+	// Depths are relaxed as many times as there are nodes, so import cycles
+	// between modules don't loop forever.
+	for range nodes {
+		for _, n := range nodes {
+			for _, p := range n.Imports {
+				n.Depth = max(n.Depth, byPath[p].Depth+1)
+			}
+		}
+	}
+
+	width, height := layoutImportGraph(nodes)
+
+	var dot bytes.Buffer
+
+	fmt.Fprintf(&dot, "digraph %q {\n", s.Host)
+	for _, n := range nodes {
+		fmt.Fprintf(&dot, "  %q;\n", n.Module)
+	}
+
+	for _, n := range nodes {
+		for _, p := range n.Imports {
+			fmt.Fprintf(&dot, "  %q -> %q;\n", n.Module, p)
+		}
+	}
+
+	dot.WriteString("}\n")
+
+	if err := s.writeFile(importsDOT, dot.Bytes()); err != nil {
+		return err
+	}
+
+	data := struct {
+		Site          *Site
+		Nodes         []*importsNode
+		ByPath        map[string]*importsNode
+		Width, Height int
+	}{s, nodes, byPath, width, height}
+
+	if err := s.writeTemplate(importsGraph, importsSVGTmpl, data); err != nil {
+		return err
+	}
+
+	return s.writeTemplate(importsPage, importsTmpl, data)
+}
+
+const (
+	importsNodeHeight = 30
+	importsRowHeight  = 80
+	importsGap        = 20
+)
+
+// layoutImportGraph places the nodes in rows by depth, importers above the
+// modules they import, and returns the size of the graph.
+func layoutImportGraph(nodes []*importsNode) (width, height int) {
+	maxDepth := 0
+	for _, n := range nodes {
+		maxDepth = max(maxDepth, n.Depth)
+	}
+
+	rowX := make([]int, maxDepth+1)
+	for i := range rowX {
+		rowX[i] = importsGap
+	}
+
+	for _, n := range nodes {
+		n.Width = len([]rune(n.DisplayPath()))*7 + 20
+		n.X = rowX[n.Depth]
+		n.Y = (maxDepth-n.Depth)*importsRowHeight + importsGap
+		rowX[n.Depth] += n.Width + importsGap
+		width = max(width, rowX[n.Depth])
+	}
+
+	return width, (maxDepth+1)*importsRowHeight - importsRowHeight + importsNodeHeight + 2*importsGap
+}
+
+var importsFuncs = template.FuncMap{
+	"add":  func(a, b int) int { return a + b },
+	"half": func(n int) int { return n / 2 },
+}
+
+var importsSVGTmpl = template.Must(template.New("imports-svg").Funcs(importsFuncs).Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{ .Width }}" height="{{ .Height }}" font-family="ui-monospace, Menlo, Consolas, monospace" font-size="12">
+  <defs>
+    <marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto-start-reverse">
+      <path d="M 0 0 L 10 5 L 0 10 z" fill="#888"/>
+    </marker>
+  </defs>
+  {{- range $n := .Nodes }}{{ range .Imports }}{{ with index $.ByPath . }}
+  <line x1="{{ add $n.X (half $n.Width) }}" y1="{{ add $n.Y 30 }}" x2="{{ add .X (half .Width) }}" y2="{{ .Y }}" stroke="#888" marker-end="url(#arrow)"/>
+  {{- end }}{{ end }}{{ end }}
+  {{- range .Nodes }}
+  <a href="{{ $.Site.Path .Package }}">
+    <rect x="{{ .X }}" y="{{ .Y }}" width="{{ .Width }}" height="30" rx="4" fill="#fff" stroke="#007d9c"/>
+    <text x="{{ add .X (half .Width) }}" y="{{ add .Y 19 }}" text-anchor="middle">{{ .DisplayPath }}</text>
+  </a>
+  {{- end }}
+</svg>
+`))
+
+var importsTmpl = template.Must(template.New("imports").Funcs(template.FuncMap{
+	"displayPath": displayPath,
+}).Parse(`<!DOCTYPE html>
+<html lang="{{ .Site.Locale.HTMLLang }}">
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="viewport" content="width=device-width, initial-scale=1"/>
+  <link rel="stylesheet" href="/style.css"/>
+  <title>{{ .Site.Locale.T "%s imports" .Site.DisplayHost }}</title>
+</head>
+<body>
+  <h1>{{ .Site.Locale.T "%s imports" .Site.DisplayHost }}</h1>
+  <p><img src="/` + importsGraph + `" alt="{{ .Site.Locale.T "Import graph" }}"/></p>
+  <p><a href="/` + importsDOT + `">DOT</a></p>
+  <ul>
+  {{- range .Nodes }}
+    <li><a href="{{ $.Site.Path .Package }}">{{ .DisplayPath }}</a>{{ with .Imports }}: {{ range $i, $p := . }}{{ if $i }}, {{ end }}{{ displayPath $p }}{{ end }}{{ end }}</li>
+  {{- end }}
+  </ul>
+</body>
+</html>
+`))
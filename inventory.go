@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"path"
+	"time"
+)
+
+// inventories write the catalog of the site modules and their versions for
+// dependency tooling, by format name:
+//
+//   - csv: inventory.csv, a module per row with its latest version.
+//   - json: inventory.json, every module with its versions.
+//   - opml: inventory.opml, an outline of links to the module pages.
+//   - renovate: <module>/releases.json per module, in the format of the
+//     Renovate custom datasources, whose defaultRegistryUrlTemplate is
+//     https://{{packageName}}/releases.json.
+var inventories = map[string]func(s *Site, mods []inventoryModule) error{
+	"csv":      writeInventoryCSV,
+	"json":     writeInventoryJSON,
+	"opml":     writeInventoryOPML,
+	"renovate": writeRenovateReleases,
+}
+
+type inventoryModule struct {
+	Module      string             `json:"module"`
+	Version     string             `json:"version,omitempty"` // Latest.
+	VersionTime *time.Time         `json:"version_time,omitempty"`
+	Go          string             `json:"go,omitempty"`
+	Source      string             `json:"source"`
+	URL         string             `json:"url"`
+	Description string             `json:"description,omitempty"`
+	Versions    []inventoryVersion `json:"versions"` // Oldest first.
+}
+
+type inventoryVersion struct {
+	Version string    `json:"version"`
+	Time    time.Time `json:"time"`
+}
+
+// inventory returns the modules of the site for the inventories.
+func (g *Generator) inventory(s *Site) ([]inventoryModule, error) {
+	mods := []inventoryModule{}
+
+	for _, pkg := range s.Packages {
+		if !pkg.IsModule() {
+			continue
+		}
+
+		m := inventoryModule{
+			Module:      pkg.Module,
+			Source:      pkg.Source,
+			URL:         s.BaseURL + pkg.URL(),
+			Description: pkg.Description,
+			Versions:    []inventoryVersion{},
+		}
+
+		if info := pkg.Info; info != nil {
+			m.Version = info.Latest
+
+			if info.Latest != "" {
+				m.VersionTime = &info.LatestTime
+			}
+
+			if info.GoMod != nil {
+				m.Go = info.GoMod.Go
+			}
+		}
+
+		if mod := g.Module(pkg.Module); mod != nil {
+			versions, err := mod.Versions()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, v := range versions {
+				t, err := mod.VersionTime(v)
+				if err != nil {
+					return nil, err
+				}
+
+				m.Versions = append(m.Versions, inventoryVersion{v, t})
+			}
+		}
+
+		mods = append(mods, m)
+	}
+
+	return mods, nil
+}
+
+func (g *Generator) writeInventories(s *Site) error {
+	mods, err := g.inventory(s)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range g.opts.Inventory {
+		if err := inventories[name](s, mods); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeInventoryJSON(s *Site, mods []inventoryModule) error {
+	data, err := json.MarshalIndent(struct {
+		Modules []inventoryModule `json:"modules"`
+	}{mods}, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return s.writeFile("inventory.json", append(data, '\n'))
+}
+
+func writeInventoryCSV(s *Site, mods []inventoryModule) error {
+	var b bytes.Buffer
+
+	w := csv.NewWriter(&b)
+	w.Write([]string{"module", "version", "version_time", "go", "source", "url", "description"})
+
+	for _, m := range mods {
+		var t string
+		if m.VersionTime != nil {
+			t = m.VersionTime.UTC().Format(time.RFC3339)
+		}
+
+		w.Write([]string{m.Module, m.Version, t, m.Go, m.Source, m.URL, m.Description})
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return s.writeFile("inventory.csv", b.Bytes())
+}
+
+type opmlOutline struct {
+	Text        string `xml:"text,attr"`
+	Type        string `xml:"type,attr"`
+	URL         string `xml:"url,attr"`
+	Description string `xml:"description,attr,omitempty"`
+	Version     string `xml:"version,attr,omitempty"`
+}
+
+type opmlDocument struct {
+	XMLName  xml.Name      `xml:"opml"`
+	Version  string        `xml:"version,attr"`
+	Title    string        `xml:"head>title"`
+	Outlines []opmlOutline `xml:"body>outline"`
+}
+
+func writeInventoryOPML(s *Site, mods []inventoryModule) error {
+	doc := opmlDocument{Version: "2.0", Title: s.DisplayHost() + " modules"}
+
+	for _, m := range mods {
+		doc.Outlines = append(doc.Outlines, opmlOutline{
+			Text:        m.Module,
+			Type:        "link",
+			URL:         m.URL,
+			Description: m.Description,
+			Version:     m.Version,
+		})
+	}
+
+	return writeXML(s, "inventory.opml", doc)
+}
+
+// writeRenovateReleases writes the versions of every module as a Renovate
+// custom datasource, next to its page.
+func writeRenovateReleases(s *Site, mods []inventoryModule) error {
+	type release struct {
+		Version          string    `json:"version"`
+		ReleaseTimestamp time.Time `json:"releaseTimestamp"`
+	}
+
+	for _, m := range mods {
+		releases := []release{}
+		for _, v := range m.Versions {
+			releases = append(releases, release{v.Version, v.Time})
+		}
+
+		data, err := json.MarshalIndent(struct {
+			Releases  []release `json:"releases"`
+			SourceURL string    `json:"sourceUrl"`
+			Homepage  string    `json:"homepage"`
+		}{releases, m.Source, m.URL}, "", "  ")
+
+		if err != nil {
+			return err
+		}
+
+		if err := writeFile(s.out, path.Join(m.Module, "releases.json"), append(data, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
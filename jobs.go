@@ -0,0 +1,101 @@
+package main
+
+import "sync"
+
+// semaphore limits the concurrent holders of a resource, a nil semaphore
+// doesn't limit them.
+type semaphore chan struct{}
+
+func newSemaphore(n int) semaphore {
+	if n <= 0 {
+		return nil
+	}
+
+	return make(semaphore, n)
+}
+
+func (s semaphore) acquire() {
+	if s != nil {
+		s <- struct{}{}
+	}
+}
+
+func (s semaphore) release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// do calls fn holding the semaphore.
+func (s semaphore) do(fn func() error) error {
+	s.acquire()
+	defer s.release()
+
+	return fn()
+}
+
+// genRepos generates the repositories, up to Options.Jobs at a time. Git
+// commands (see Options.CloneJobs) and go list commands (see
+// Options.ListJobs) have their own limits inside writeRepo. No repository
+// starts after one fails, the summaries of the started ones are returned in
+// the order of repos with the first error.
+func (g *Generator) genRepos(st *genState, out Output, repos []*Repo) ([]*RepoSummary, error) {
+	sums := make([]*RepoSummary, len(repos))
+	errs := make([]error, len(repos))
+
+	jobs := make(semaphore, max(g.opts.Jobs, 1))
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+	)
+
+	for i, r := range repos {
+		jobs.acquire()
+
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+
+		if stop {
+			jobs.release()
+			break
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer jobs.release()
+
+			rs, err := g.genRepo(st, out, r)
+
+			mu.Lock()
+			sums[i], errs[i] = rs, err
+			failed = failed || err != nil
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	var (
+		started []*RepoSummary
+		err     error
+	)
+
+	for i, rs := range sums {
+		if rs == nil {
+			continue
+		}
+
+		started = append(started, rs)
+
+		if err == nil {
+			err = errs[i]
+		}
+	}
+
+	return started, err
+}
@@ -0,0 +1,52 @@
+package main
+
+import "time"
+
+// StructuredData returns the schema.org description of the package as a
+// software project, rendered as JSON-LD into the package pages for search
+// engines.
+func (pkg Package) StructuredData() map[string]any {
+	d := map[string]any{
+		"@context":       "https://schema.org",
+		"@type":          "SoftwareSourceCode",
+		"name":           pkg.ImportPath,
+		"codeRepository": pkg.Source,
+		"programmingLanguage": map[string]any{
+			"@type": "ComputerLanguage",
+			"name":  "Go",
+			"url":   "https://go.dev",
+		},
+	}
+
+	if pkg.Description != "" {
+		d["description"] = pkg.Description
+	}
+
+	if pkg.Site != nil {
+		d["url"] = pkg.Site.BaseURL + pkg.URL()
+	}
+
+	if info := pkg.Info; info != nil {
+		if info.License != "" {
+			d["license"] = "https://spdx.org/licenses/" + info.License + ".html"
+		}
+
+		if info.Latest != "" {
+			d["version"] = info.Latest
+			d["dateModified"] = info.LatestTime.UTC().Format(time.RFC3339)
+		}
+
+		if info.GoMod != nil && info.GoMod.Go != "" {
+			d["runtimePlatform"] = "Go " + info.GoMod.Go
+		}
+	}
+
+	if !pkg.IsModule() {
+		d["isPartOf"] = map[string]any{
+			"@type": "SoftwareSourceCode",
+			"name":  pkg.Module,
+		}
+	}
+
+	return d
+}
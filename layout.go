@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// layouts map the names of the generated files, which start with the import
+// path of their package (like go.example.com/pkg/index.html), to their names
+// in the output directory. Only the tree layout can hold multiple hosts.
+var layouts = map[string]func(name string) string{
+	// Import path tree, sites are the host directories.
+	"tree": func(name string) string { return name },
+
+	// Host-stripped tree, the output directory is the site.
+	"host": stripHost,
+
+	// Host-stripped files, package pages are written as pkg.html instead of
+	// pkg/index.html and there are no go-get pages, for hosts that resolve
+	// extension-less paths to HTML files.
+	"flat": func(name string) string {
+		name = stripHost(name)
+		if dir, base := path.Split(name); base == "index.html" && dir != "" {
+			return strings.TrimSuffix(dir, "/") + ".html"
+		}
+
+		return name
+	},
+}
+
+// stripHost removes the first element of the name, files at the output root
+// are kept as they are.
+func stripHost(name string) string {
+	if _, rest, ok := strings.Cut(name, "/"); ok {
+		return rest
+	}
+
+	return name
+}
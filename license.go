@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// licenseMarkers identify the SPDX license of a license file by phrases of
+// its text, checked in order since some licenses quote others.
+var licenseMarkers = []struct {
+	id     string
+	marker *regexp.Regexp
+}{
+	{"AGPL-3.0", regexp.MustCompile(`GNU AFFERO GENERAL PUBLIC LICENSE\s+Version 3`)},
+	{"LGPL-3.0", regexp.MustCompile(`GNU LESSER GENERAL PUBLIC LICENSE\s+Version 3`)},
+	{"LGPL-2.1", regexp.MustCompile(`GNU LESSER GENERAL PUBLIC LICENSE\s+Version 2\.1`)},
+	{"GPL-3.0", regexp.MustCompile(`GNU GENERAL PUBLIC LICENSE\s+Version 3`)},
+	{"GPL-2.0", regexp.MustCompile(`GNU GENERAL PUBLIC LICENSE\s+Version 2`)},
+	{"MPL-2.0", regexp.MustCompile(`Mozilla Public License,? [Vv]ersion 2\.0`)},
+	{"Apache-2.0", regexp.MustCompile(`Apache License,?\s+Version 2\.0`)},
+	{"BSD-3-Clause", regexp.MustCompile(`Neither the name of`)},
+	{"BSD-2-Clause", regexp.MustCompile(`Redistributions in binary form must reproduce`)},
+	{"MIT", regexp.MustCompile(`Permission is hereby granted, free of charge`)},
+	{"ISC", regexp.MustCompile(`Permission to use, copy, modify, and(/or)? distribute this software`)},
+	{"Unlicense", regexp.MustCompile(`This is free and unencumbered software released into the public domain`)},
+}
+
+// detectLicense returns the SPDX identifier of the license file of the
+// module, or of the repository, empty if there is none or it isn't known.
+func detectLicense(mod *Module) string {
+	data, ok := findLicense(mod)
+	if !ok {
+		return ""
+	}
+
+	data = bytes.Join(bytes.Fields(data), []byte{' '}) // Wrapped lines.
+
+	for _, l := range licenseMarkers {
+		if l.marker.Match(data) {
+			return l.id
+		}
+	}
+
+	return ""
+}
+
+// findLicense returns the content of the license file of the module, or of
+// the repository.
+func findLicense(mod *Module) ([]byte, bool) {
+	for _, dir := range []string{mod.Dir(), mod.RepoDir} {
+		for _, name := range []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "COPYING"} {
+			if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+				return data, true
+			}
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits protects HTTP servers from misbehaving clients.
+type Limits struct {
+	Rate  float64 // Requests per second per client IP, zero disables it.
+	Burst int
+
+	MaxBody      int64
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+func DefaultLimits() Limits {
+	return Limits{
+		Burst:        50,
+		MaxBody:      5 << 20,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 5 * time.Minute,
+		IdleTimeout:  2 * time.Minute,
+	}
+}
+
+func (l *Limits) AddFlags(fset *flag.FlagSet) {
+	fset.Float64Var(
+		&l.Rate, "rate-limit", l.Rate,
+		"Maximum requests per second per client IP, zero disables rate limiting.",
+	)
+
+	fset.IntVar(
+		&l.Burst, "rate-burst", l.Burst,
+		"Maximum requests per client IP allowed at once over -rate-limit.",
+	)
+
+	fset.Int64Var(
+		&l.MaxBody, "max-body", l.MaxBody,
+		"Maximum request body size in bytes.",
+	)
+
+	fset.DurationVar(
+		&l.ReadTimeout, "read-timeout", l.ReadTimeout,
+		"Maximum time to read a request, including its body.",
+	)
+
+	fset.DurationVar(
+		&l.WriteTimeout, "write-timeout", l.WriteTimeout,
+		"Maximum time to write a response.",
+	)
+
+	fset.DurationVar(
+		&l.IdleTimeout, "idle-timeout", l.IdleTimeout,
+		"Maximum time to wait for the next request on keep-alive connections.",
+	)
+}
+
+// Server returns an HTTP server with the limits timeouts.
+func (l Limits) Server(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: min(l.ReadTimeout, 10*time.Second),
+		ReadTimeout:       l.ReadTimeout,
+		WriteTimeout:      l.WriteTimeout,
+		IdleTimeout:       l.IdleTimeout,
+		MaxHeaderBytes:    64 << 10,
+	}
+}
+
+// Handler returns a handler that rejects requests over the rate limit and
+// bounds request bodies.
+func (l Limits) Handler(h http.Handler) http.Handler {
+	rl := &rateLimiter{rate: l.Rate, burst: float64(max(l.Burst, 1)), clients: make(map[string]*bucket)}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.Rate > 0 {
+			if wait := rl.allow(clientIP(r), time.Now()); wait > 0 {
+				secs := int(math.Ceil(wait.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(secs))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+
+				return
+			}
+		}
+
+		if l.MaxBody > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, l.MaxBody)
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// maxClients bounds the memory used for tracking client rates.
+const maxClients = 100000
+
+// rateLimiter is a token bucket rate limiter per client.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	clients map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the client bucket, or returns how long the
+// client has to wait for one.
+func (rl *rateLimiter) allow(client string, now time.Time) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b, ok := rl.clients[client]
+	if !ok {
+		if len(rl.clients) >= maxClients {
+			rl.prune(now)
+		}
+
+		b = &bucket{tokens: rl.burst, last: now}
+		rl.clients[client] = b
+	}
+
+	b.tokens = min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	}
+
+	b.tokens--
+
+	return 0
+}
+
+// prune forgets clients with full buckets, since they are the same as new
+// ones. If every client is active, all of them are forgotten.
+func (rl *rateLimiter) prune(now time.Time) {
+	for client, b := range rl.clients {
+		if b.tokens+now.Sub(b.last).Seconds()*rl.rate >= rl.burst {
+			delete(rl.clients, client)
+		}
+	}
+
+	if len(rl.clients) >= maxClients {
+		clear(rl.clients)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path"
+	"strconv"
+)
+
+// Pager is the position of a page in a package listing. Listings with more
+// packages than the page size are split into pages, the first one at the
+// listing path and the others at <listing>/-/page/<n>/.
+type Pager struct {
+	Number, Pages int
+	Prev, Next    string // URLs of the adjacent pages.
+}
+
+// writeListing calls write with the file name, packages and pager of every
+// page of the listing at the import path p (a host or a prefix). A size of
+// 0 writes a single page.
+func writeListing(p string, pkgs []Package, size int, flat bool, write func(name string, pkgs []Package, pager Pager) error) error {
+	pages := 1
+	if size > 0 && len(pkgs) > size {
+		pages = (len(pkgs) + size - 1) / size
+	}
+
+	for n := 1; n <= pages; n++ {
+		pager := Pager{Number: n, Pages: pages}
+		if n > 1 {
+			pager.Prev = pageURL(listingPath(p, n-1), flat)
+		}
+
+		if n < pages {
+			pager.Next = pageURL(listingPath(p, n+1), flat)
+		}
+
+		page := pkgs
+		if pages > 1 {
+			page = pkgs[(n-1)*size : min(n*size, len(pkgs))]
+		}
+
+		if err := write(path.Join(listingPath(p, n), "index.html"), page, pager); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func listingPath(p string, n int) string {
+	if n == 1 {
+		return p
+	}
+
+	return path.Join(p, "-", "page", strconv.Itoa(n))
+}
+
+// pagerTmpl is the navigation between the pages of a listing, for
+// templates with a Pager and a Locale.
+const pagerTmpl = `{{ define "pager" }}{{ with .Pager }}{{ if gt .Pages 1 }}
+  <nav class="pager">
+    {{- with .Prev }}
+    <a rel="prev" href="{{ . }}">{{ $.Locale.T "Previous" }}</a>
+    {{- end }}
+    <span>{{ $.Locale.T "Page %d of %d" .Number .Pages }}</span>
+    {{- with .Next }}
+    <a rel="next" href="{{ . }}">{{ $.Locale.T "Next" }}</a>
+    {{- end }}
+  </nav>
+{{- end }}{{ end }}{{ end }}`
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Locale translates the fixed text of the generated pages. Messages are
+// keyed by their English text, missing ones are shown in English.
+type Locale struct {
+	Lang     string
+	Messages map[string]string
+}
+
+// readLocale reads the messages file, a JSON object mapping English texts
+// to their translation. An empty file name means no translations.
+func readLocale(lang, file string) (*Locale, error) {
+	l := &Locale{Lang: lang}
+	if file == "" {
+		return l, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &l.Messages); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return l, nil
+}
+
+// T returns the translation of msg, formatted with args if any.
+func (l *Locale) T(msg string, args ...any) string {
+	if l != nil {
+		if t, ok := l.Messages[msg]; ok {
+			msg = t
+		}
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+
+	return msg
+}
+
+// HTMLLang returns the value of the html lang attribute.
+func (l *Locale) HTMLLang() string {
+	if l == nil || l.Lang == "" {
+		return "en"
+	}
+
+	return l.Lang
+}
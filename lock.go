@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockFile takes an exclusive lock on the file name, creating it if needed,
+// and waits until other processes release it. The lock is released by the
+// returned function or when the process exits, so crashed runs don't leave
+// stale locks.
+func lockFile(name string) (func(), error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFD(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock %s: %w", name, err)
+	}
+
+	return func() {
+		unlockFD(f)
+		f.Close()
+	}, nil
+}
+
+// lockSource locks the checkout at dir for the rest of the generation of
+// its repository, so concurrent runs sharing the source directory don't
+// update it while others read it.
+func lockSource(dir string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, err
+	}
+
+	return lockFile(dir + ".lock")
+}
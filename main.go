@@ -1,31 +1,512 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"cmp"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
+	"log"
+	"net/url"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func main() {
-	opts := DefaultOptions()
-	opts.ParseFlags(os.Args[1:])
+	if os.Getenv(askpassEnv) != "" {
+		askpass(os.Args[1:])
+		return
+	}
+
+	args := os.Args[1:]
+	cmd := genCmd
+
+	if len(args) > 0 {
+		if c, ok := commands[args[0]]; ok {
+			cmd, args = c, args[1:]
+		}
+	}
 
-	if err := genPackages(opts); err != nil {
+	if err := cmd(args); err != nil {
 		panic(err)
 	}
 }
 
+var commands = map[string]func(args []string) error{
+	"check-imports":   checkImportsCmd,
+	"check-signature": checkSignatureCmd,
+	"compare":         compareCmd,
+	"migrate":         migrateCmd,
+	"policy":          policyCmd,
+	"release":         releaseCmd,
+	"serve":           serveCmd,
+	"stats":           statsCmd,
+	"template":        templateCmd,
+	"verify":          verifyCmd,
+	"webhook":         webhookCmd,
+	"zips":            zipsCmd,
+}
+
+func genCmd(args []string) (err error) {
+	opts := DefaultOptions()
+	fset := opts.FlagSet("vanitic")
+
+	var prof Profiling
+	prof.AddFlags(fset)
+
+	timing := fset.Bool(
+		"timing", false,
+		"Log the time spent in every generation phase.",
+	)
+
+	var only, skip []string
+
+	fset.Func(
+		"only", "Comma separated list of repositories (URLs or names) to generate, the files of the other ones are left as they are.",
+		func(s string) error {
+			only = strings.Split(s, ",")
+			return nil
+		},
+	)
+
+	fset.Func(
+		"skip", "Comma separated list of repositories (URLs or names) to leave as they are.",
+		func(s string) error {
+			skip = strings.Split(s, ",")
+			return nil
+		},
+	)
+
+	var teams []string
+
+	fset.Func(
+		"team", "Comma separated list of teams whose repositories are generated, the files of the other ones are left as they are (see Team).",
+		func(s string) error {
+			teams = strings.Split(s, ",")
+			return nil
+		},
+	)
+
+	publicOut := fset.String(
+		"public-out", "",
+		"Also write the site with only the public=true repositories into the given directory, with the same options.",
+	)
+
+	watch := fset.Bool(
+		"watch", false,
+		"Regenerate the files when the configuration, templates or static files change, until interrupted.",
+	)
+
+	if err := opts.Parse(fset, args); err != nil {
+		return err
+	}
+
+	if *watch && (opts.GHPagesRepo != "" || opts.Publish != "" || opts.Archive != "" || opts.Upload != "") {
+		return errors.New("-watch can't be used with -gh-pages-repo, -publish, -archive or -upload")
+	}
+
+	stop, err := prof.Start()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if serr := stop(); err == nil {
+			err = serr
+		}
+	}()
+
+	g, err := NewGenerator(opts)
+	if err != nil {
+		return err
+	}
+
+	gens := []*Generator{g}
+
+	if *publicOut != "" {
+		popts := *opts
+		popts.Output = filepath.Clean(*publicOut)
+		popts.PublicOnly = true
+
+		pg, err := NewGenerator(&popts)
+		if err != nil {
+			return err
+		}
+
+		gens = append(gens, pg)
+	}
+
+	if (only != nil || skip != nil || teams != nil) && opts.Clean {
+		return errors.New("-clean can't be used with -only, -skip or -team")
+	}
+
+	var last *Summary // Of the main generator, for publishing.
+
+	generate := func() error {
+		var selected []*Repo
+
+		if only != nil || skip != nil || teams != nil {
+			var err error
+			if selected, err = selectRepos(g.Repos(), only, skip); err != nil {
+				return err
+			}
+		}
+
+		if teams != nil {
+			for _, name := range teams {
+				if !slices.ContainsFunc(g.Teams(), func(t *Team) bool { return t.Name == name }) {
+					return fmt.Errorf("unknown team %q", name)
+				}
+			}
+
+			if selected = teamRepos(selected, teams); selected == nil {
+				selected = []*Repo{} // Not every repository.
+			}
+		}
+
+		for i, g := range gens {
+			var (
+				sum *Summary
+				err error
+			)
+
+			if selected != nil {
+				var repos []*Repo
+				for _, r := range selected {
+					if r.Public || !g.opts.PublicOnly {
+						repos = append(repos, r)
+					}
+				}
+
+				sum, err = g.GenerateRepos(repos)
+			} else {
+				sum, err = g.Generate()
+			}
+
+			if *timing {
+				logPhases(sum)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if i == 0 {
+				last = sum
+			}
+		}
+
+		return nil
+	}
+
+	if err := generate(); err != nil {
+		return err
+	}
+
+	if *watch {
+		// Errors while watching are logged, so they can be fixed without
+		// restarting.
+		newWatcher(opts).Run(nil, time.Second, func() {
+			var err error
+
+			for _, g := range gens {
+				if err = g.Reload(); err != nil {
+					break
+				}
+			}
+
+			if err == nil {
+				err = generate()
+			}
+
+			if err != nil {
+				log.Printf("watch: %v", err)
+				return
+			}
+
+			log.Print("watch: regenerated")
+		})
+	}
+
+	if opts.GHPagesRepo != "" {
+		if err := g.PublishGHPages(opts.GHPagesRepo); err != nil {
+			return err
+		}
+	}
+
+	if opts.Publish != "" {
+		if err := g.PublishGit(opts.Publish, last); err != nil {
+			return err
+		}
+	}
+
+	if opts.Archive != "" {
+		if err := WriteArchive(opts.Archive, opts.Output); err != nil {
+			return err
+		}
+	}
+
+	if opts.Upload != "" {
+		return Upload(opts.Upload, opts.Output, g.cmd)
+	}
+
+	return nil
+}
+
 type Package struct {
-	Source      string
+	Source      string // Repository URL of the go-import tag.
+	Web         string // Web page of the repository, if known, see webURL.
+	Subdir      string // Module root in the repository, if not the root.
 	Module      string
 	ImportPath  string
 	Description string
+
+	// Sanitized HTML of configured HTML descriptions, see DescriptionHTML.
+	RichDescription template.HTML
+
+	Info  *ModuleInfo // Shared by the module packages.
+	Doc   *PackageDoc
+	Files []string // Go files with source pages.
+
+	Breadcrumbs []Crumb // Parent directories, from the module root.
+
+	Site   *SiteInfo
+	Locale *Locale
+}
+
+// SiteInfo describes the site of the pages.
+type SiteInfo struct {
+	Title   string // The host in Unicode form, if not set with -title.
+	BaseURL string // https:// and the host, if not set with -base-url.
+
+	// Package pages are HTML files named after the package, see layouts.
+	Flat bool
+
+	Generated time.Time // UTC.
+}
+
+// PackageRef is a package of a module, for listing them.
+type PackageRef struct {
+	ImportPath      string
+	Description     string
+	RichDescription template.HTML
+
+	flat bool // See SiteInfo.Flat.
+}
+
+// URL returns the URL path of the package page.
+func (p PackageRef) URL() string {
+	return pageURL(p.ImportPath, p.flat)
+}
+
+// DisplayPath returns the import path with its host in Unicode form.
+func (p PackageRef) DisplayPath() string {
+	return displayPath(p.ImportPath)
+}
+
+// DescriptionHTML returns the HTML of the package description, its rich
+// description if it has one or the escaped plain text otherwise.
+func (pkg Package) DescriptionHTML() template.HTML {
+	return descriptionHTML(pkg.Description, pkg.RichDescription)
+}
+
+// DescriptionHTML is like Package.DescriptionHTML.
+func (p PackageRef) DescriptionHTML() template.HTML {
+	return descriptionHTML(p.Description, p.RichDescription)
+}
+
+func descriptionHTML(text string, rich template.HTML) template.HTML {
+	if rich != "" {
+		return rich
+	}
+
+	return template.HTML(template.HTMLEscapeString(text))
+}
+
+// Siblings returns the other packages of the module.
+func (pkg Package) Siblings() []PackageRef {
+	if pkg.Info == nil {
+		return nil
+	}
+
+	var refs []PackageRef
+
+	for _, ref := range pkg.Info.Packages {
+		if ref.ImportPath != pkg.ImportPath {
+			refs = append(refs, ref)
+		}
+	}
+
+	return refs
+}
+
+// ModuleInfo is the module information shown in the module page.
+type ModuleInfo struct {
+	README template.HTML
+
+	// Latest release, from the repository tags. LatestTag has the tag prefix
+	// of modules in subdirectories.
+	Latest     string
+	LatestTag  string
+	LatestTime time.Time
+
+	Branch string // Repository branch the pages are generated from.
+
+	// Last clone or update of the repository. With Options.StaleAfter,
+	// failed updates use the last checkout, which is stale after it.
+	Refreshed time.Time
+	Stale     bool
+
+	// When the module was first generated, with a state database (see
+	// StateDB).
+	FirstSeen time.Time
+
+	License string // SPDX identifier, if the license file is a known one.
+
+	Packages []PackageRef // Sorted by import path.
+
+	GoMod *GoModFile // Nil if the module doesn't have a go.mod file.
+
+	Imports []string // Modules imported by the module packages, with -import-graph.
+
+	Changelog bool // The module has a changelog page.
+
+	SBOM string // URL path of the bill of materials, with -sbom.
+
+	Contributors []Contributor
+	Badges       []Badge
+
+	Meta map[string]string // Repository metadata from the configuration.
+
+	// Known vulnerabilities, if the module was checked.
+	VulnChecked bool
+	Vulns       []Vuln
+
+	// Tagged versions unknown to the public module proxy, if the module was
+	// checked, see unpublishedVersions.
+	PublishChecked bool
+	Unpublished    []string
+
+	// Source pages are generated, go-source points at them.
+	SourcePages bool
+}
+
+// ref returns the branch for source links, HEAD (the default branch of
+// forges) if unknown.
+func (info *ModuleInfo) ref() string {
+	if info == nil || info.Branch == "" || info.Branch == "HEAD" {
+		return "HEAD"
+	}
+
+	return info.Branch
+}
+
+// Crumb is a parent directory of a package, URL is empty if there is no
+// page for it.
+type Crumb struct {
+	Name string
+	URL  string
+}
+
+// breadcrumbs returns the parent directories of the import path up to the
+// module root, pages are only linked if they are listed.
+func breadcrumbs(modPath, importPath string, listed map[string]bool, flat bool) []Crumb {
+	rel, ok := strings.CutPrefix(importPath, modPath+"/")
+	if !ok {
+		return nil
+	}
+
+	crumbs := []Crumb{{Name: displayPath(modPath), URL: pageURL(modPath, flat)}}
+
+	p := modPath
+	elems := strings.Split(rel, "/")
+
+	for _, elem := range elems[:len(elems)-1] {
+		p += "/" + elem
+
+		c := Crumb{Name: elem}
+		if listed[p] {
+			c.URL = pageURL(p, flat)
+		}
+
+		crumbs = append(crumbs, c)
+	}
+
+	return crumbs
+}
+
+// URL returns the URL path of the package page.
+func (pkg Package) URL() string {
+	return pageURL(pkg.ImportPath, pkg.flat())
+}
+
+// SourceDir returns the URL path of the package source pages.
+func (pkg Package) SourceDir() string {
+	return path.Join(pkg.URL(), sourceDir)
+}
+
+// ChangelogURL returns the URL path of the module changelog page.
+func (pkg Package) ChangelogURL() string {
+	return path.Join(pageURL(pkg.Module, pkg.flat()), changelogPage)
+}
+
+func (pkg Package) flat() bool {
+	return pkg.Site != nil && pkg.Site.Flat
+}
+
+// DisplayPath returns the import path with its host in Unicode form.
+func (pkg Package) DisplayPath() string {
+	return displayPath(pkg.ImportPath)
+}
+
+// IsModule reports whether the package is the module root.
+func (pkg Package) IsModule() bool {
+	return pkg.ImportPath == pkg.Module
+}
+
+// GoImport returns the content of the go-import meta tag, modules in
+// subdirectories have the subdirectory field (Go 1.25+).
+func (pkg Package) GoImport() string {
+	if pkg.Subdir != "" {
+		return pkg.Module + " git " + pkg.Source + " " + pkg.Subdir
+	}
+
+	return pkg.Module + " git " + pkg.Source
+}
+
+// GoSource returns the content of the go-source meta tag.
+func (pkg Package) GoSource() string {
+	if pkg.Info != nil && pkg.Info.SourcePages {
+		// Source pages are under the package pages, which follow the import
+		// paths whatever the module directory in the repository is.
+		site := "https://" + pkg.Module
+		if pkg.Site != nil {
+			site = pkg.Site.BaseURL + strings.TrimSuffix(pageURL(pkg.Module, pkg.Site.Flat), "/")
+		}
+
+		return pkg.Module + " " + cmp.Or(pkg.Web, site) + " " + site + "{/dir} " + site + "{/dir}/" + sourceDir + "/{file}.html#L{line}"
+	}
+
+	if pkg.Web == "" {
+		return pkg.Module + " _ _ _"
+	}
+
+	root := pkg.Info.ref()
+	if pkg.Subdir != "" {
+		root += "/" + pkg.Subdir
+	}
+
+	return pkg.Module + " " + pkg.Web + " " + pkg.Web + "/tree/" + root + "{/dir} " + pkg.Web + "/blob/" + root + "{/dir}/{file}#L{line}"
 }
 
 type Options struct {
@@ -33,6 +514,119 @@ type Options struct {
 	Source string
 	Clean  bool
 	Output string
+	Netrc  string
+	Format string
+
+	// Static hosting targets, see targets.
+	Targets      []string
+	DocsRedirect bool
+
+	GHPages     bool
+	GHPagesRepo string
+
+	// Repository, branch and directory the output is committed to, see
+	// PublishGit.
+	Publish        string
+	PublishBranch  string
+	PublishDir     string
+	PublishMessage string
+
+	Upload  string
+	Archive string
+
+	// JSON file with the history of the modules, see StateDB.
+	State string
+
+	// Secret source of the key signing the manifest, see signManifest.
+	SigningKey string
+
+	// Repositories that fail to update are generated from their last
+	// checkout, and marked as stale when it is older than StaleAfter. Zero
+	// makes the failures errors.
+	StaleAfter time.Duration
+
+	// Discovery API requests, see apiCache. Responses are cached in the
+	// DiscoveryCache directory if set, and used without requests for
+	// DiscoveryTTL. Runs with more than DiscoveryBudget requests (if not
+	// zero) and rate limits resetting after DiscoveryMaxWait use the cached
+	// responses.
+	DiscoveryCache   string
+	DiscoveryTTL     time.Duration
+	DiscoveryBudget  int
+	DiscoveryMaxWait time.Duration
+
+	// Repositories generated at once, and limits of their git commands
+	// (network-bound), go list commands (CPU and disk-bound) and of every
+	// subprocess, for bounding the memory. Zero limits are disabled.
+	Jobs      int
+	CloneJobs int
+	ListJobs  int
+	MaxProcs  int
+
+	// URLs notified of the generations that change the site or fail, see
+	// notifiers.
+	Notify []string
+
+	README      bool
+	API         bool
+	Examples    bool
+	Symbols     bool
+	SourcePages bool
+	DepsGraph   bool
+	ImportGraph bool
+	Changelog   bool
+	SBOM        string // Bill of materials format, see sbomFormats.
+
+	Contributors      bool
+	ContributorEmails string
+
+	Vulncheck bool
+	Badges    bool
+	GoBadge   bool
+
+	// Module proxy asked for the tagged versions of public repositories.
+	PublicProxy string
+
+	Lang     string
+	Messages string
+	Accent   string // CSS color of the links.
+
+	Templates string // Directory with replacements of the page templates.
+	Static    string // Directory with files copied into every site.
+
+	PageVariants []string // See pageVariants.
+	PrefixPages  bool     // See writePrefixPages.
+	PageSize     int      // Packages per page of the listings, see writeListing.
+	Sitemap      bool
+	Inventory    []string // See inventories.
+	PackageData  []string // See pkgDataFiles.
+	Layout       string   // See layouts.
+	Host         string   // Host of every module, implies the host layout.
+
+	// Order for choosing the repository URL of the pages, overrides the
+	// mirrors directive of the configuration.
+	Mirrors []string
+
+	// Bare mirrors of the repositories, updated on every generation, and
+	// the URL they are served at.
+	GitMirrors   string
+	GitMirrorURL string
+
+	// Only generate the repositories with the public attribute.
+	PublicOnly bool
+
+	// Site information for the templates, see SiteInfo.
+	Title   string
+	BaseURL string
+
+	PathStyle string // See pathStyles.
+
+	// Check the meta tags of the written pages.
+	CheckPages bool
+
+	// Output permissions, see DirOutput.
+	DirMode  fs.FileMode
+	FileMode fs.FileMode
 }
 
 func DefaultOptions() *Options {
@@ -41,11 +635,40 @@ func DefaultOptions() *Options {
 		Source: filepath.Join(os.TempDir(), "vanitic"),
 		Clean:  false,
 		Output: "pkg",
+		Netrc:  defaultNetrc(),
+		Format: "html",
+		Lang:   "en",
+		Accent: "#007d9c",
+
+		DiscoveryMaxWait: time.Minute,
+
+		Jobs:      1,
+		CloneJobs: 4,
+		ListJobs:  runtime.NumCPU(),
+
+		PublishBranch:  "master",
+		PublishMessage: defaultPublishMessage,
+
+		PathStyle:    "native",
+		PageVariants: []string{"html"},
+		PrefixPages:  true,
+		PageSize:     500,
+		PackageData:  []string{"json"},
+		Layout:       "tree",
+		CheckPages:   true,
+
+		ContributorEmails: "mask",
 	}
 }
 
 func (opts *Options) ParseFlags(args []string) error {
-	fset := flag.NewFlagSet("vanitic", flag.ExitOnError)
+	return opts.Parse(opts.FlagSet("vanitic"), args)
+}
+
+// FlagSet returns a flag set with the generation flags, commands may add
+// their own flags to it.
+func (opts *Options) FlagSet(name string) *flag.FlagSet {
+	fset := flag.NewFlagSet(name, flag.ExitOnError)
 
 	fset.StringVar(
 		&opts.Config, "c", opts.Config,
@@ -59,7 +682,7 @@ func (opts *Options) ParseFlags(args []string) error {
 
 	fset.BoolVar(
 		&opts.Clean, "clean", opts.Clean,
-		"Remove output directory before generating files.",
+		"Remove the previously generated files (listed in "+manifestFile+") before generating files.",
 	)
 
 	fset.StringVar(
@@ -67,6 +690,352 @@ func (opts *Options) ParseFlags(args []string) error {
 		"Directory where Go packages HTML files will be written.",
 	)
 
+	fset.StringVar(
+		&opts.SigningKey, "sign-key", opts.SigningKey,
+		"Secret source (env:NAME or file:PATH) of an Ed25519 or ECDSA PKCS #8 PEM key, signing the SHA-256 sums of the written files ("+sumsFile+", "+sigFile+" and "+pubKeyFile+").",
+	)
+
+	fset.Func(
+		"mirror", "Comma separated list of mirror names (from mirror.<name>=URL repository attributes, "+primaryMirror+" being the repository URL) in order of preference for the go-import and go-source URLs, overrides the mirrors directive.",
+		func(s string) error {
+			opts.Mirrors = strings.Split(s, ",")
+			for _, name := range opts.Mirrors {
+				if !validMetaKey(name) {
+					return fmt.Errorf("invalid mirror name %q", name)
+				}
+			}
+
+			return nil
+		},
+	)
+
+	fset.StringVar(
+		&opts.Title, "title", opts.Title,
+		"Site title for the templates (default the host).",
+	)
+
+	fset.StringVar(
+		&opts.BaseURL, "base-url", opts.BaseURL,
+		"Site base URL for the templates (default https:// and the host).",
+	)
+
+	fset.StringVar(
+		&opts.GitMirrors, "git-mirrors", opts.GitMirrors,
+		"Directory where bare mirrors of the repositories are kept and fetched on every generation, for serving them with git-http-backend or a static host.",
+	)
+
+	fset.StringVar(
+		&opts.GitMirrorURL, "git-mirror-url", opts.GitMirrorURL,
+		"URL where the -git-mirrors directory is served (e.g. https://go.example.com/git), go-import points at it with the "+localMirror+" mirror (see -mirror).",
+	)
+
+	fset.BoolVar(
+		&opts.PublicOnly, "public", opts.PublicOnly,
+		"Only generate the repositories with the public=true attribute.",
+	)
+
+	fset.StringVar(
+		&opts.Host, "host", opts.Host,
+		"Host of the site (e.g. go.example.com), pages are written without the host directory and every module must be under it.",
+	)
+
+	fset.StringVar(
+		&opts.Layout, "layout", opts.Layout,
+		"Layout of the output directory ("+strings.Join(sortedKeys(layouts), ", ")+"): the import paths tree, the tree without the host directory, or pkg.html files without the host directory. Only tree can hold multiple hosts.",
+	)
+
+	fset.StringVar(
+		&opts.PathStyle, "path-style", opts.PathStyle,
+		"Style of the paths given to git and rsync ("+strings.Join(pathStyles, ", ")+"), slash is for Windows builds that don't take backslashes.",
+	)
+
+	fset.Func(
+		"dirmode", "Permissions of the output directories, in octal (default 0755 masked by the umask).",
+		modeFlag(&opts.DirMode),
+	)
+
+	fset.Func(
+		"filemode", "Permissions of the output files, in octal (default 0644 masked by the umask).",
+		modeFlag(&opts.FileMode),
+	)
+
+	fset.StringVar(
+		&opts.Netrc, "netrc", opts.Netrc,
+		"Netrc file with credentials for private hosts.",
+	)
+
+	fset.BoolVar(
+		&opts.README, "readme", opts.README,
+		"Render the repository README.md into module pages.",
+	)
+
+	fset.BoolVar(
+		&opts.API, "api", opts.API,
+		"Render the exported API documentation into package pages.",
+	)
+
+	fset.BoolVar(
+		&opts.Examples, "examples", opts.Examples,
+		"Render the package examples from test files into package pages.",
+	)
+
+	fset.BoolVar(
+		&opts.Symbols, "symbols", opts.Symbols,
+		"List the exported types and functions in package pages, linking to their pkg.go.dev documentation.",
+	)
+
+	fset.BoolVar(
+		&opts.SourcePages, "src-pages", opts.SourcePages,
+		"Write highlighted source pages of the Go files and point go-source at them.",
+	)
+
+	fset.BoolVar(
+		&opts.Changelog, "changelog", opts.Changelog,
+		"Write a changelog page per module, from CHANGELOG.md or the annotated tags.",
+	)
+
+	fset.StringVar(
+		&opts.SBOM, "sbom", opts.SBOM,
+		"Write a software bill of materials per module from its go.mod and go.sum files, in the given format ("+strings.Join(sortedKeys(sbomFormats), ", ")+").",
+	)
+
+	fset.BoolVar(
+		&opts.Contributors, "contributors", opts.Contributors,
+		"Render the commit authors into module pages.",
+	)
+
+	fset.StringVar(
+		&opts.ContributorEmails, "contributor-emails", opts.ContributorEmails,
+		"Contributors email addresses ("+strings.Join(emailModes, ", ")+").",
+	)
+
+	fset.StringVar(
+		&opts.Lang, "lang", opts.Lang,
+		"Language of the generated pages, set in their html lang attribute.",
+	)
+
+	fset.StringVar(
+		&opts.Messages, "messages", opts.Messages,
+		"JSON file with the translations of the pages text, keyed by the English text.",
+	)
+
+	fset.StringVar(
+		&opts.Accent, "accent", opts.Accent,
+		"Accent color of the pages stylesheet (#RGB, #RRGGBB or a CSS color name).",
+	)
+
+	fset.StringVar(
+		&opts.Templates, "templates", opts.Templates,
+		"Directory with templates replacing the built-in ones (index.html, "+goGetPage+"), they get the same data.",
+	)
+
+	fset.StringVar(
+		&opts.Static, "static", opts.Static,
+		"Directory with files copied into the root of every site, like a favicon or a "+styleSheet+" replacement.",
+	)
+
+	fset.Func(
+		"page-variants", "Comma separated list of extra pages written next to every package directory, so go-get requests for paths without a trailing slash never 404 ("+strings.Join(sortedKeys(pageVariants), ", ")+"), empty disables them (default html).",
+		func(s string) error {
+			opts.PageVariants = nil
+			if s != "" {
+				opts.PageVariants = strings.Split(s, ",")
+			}
+
+			return nil
+		},
+	)
+
+	fset.Func(
+		"inventory", "Comma separated list of catalogs of the site modules and their versions for dependency tooling ("+strings.Join(sortedKeys(inventories), ", ")+").",
+		func(s string) error {
+			opts.Inventory = strings.Split(s, ",")
+			return nil
+		},
+	)
+
+	fset.Func(
+		"pkg-data", "Comma separated list of metadata files written next to every package page as index.<name> ("+strings.Join(sortedKeys(pkgDataFiles), ", ")+"), empty disables them (default json).",
+		func(s string) error {
+			opts.PackageData = nil
+			if s != "" {
+				opts.PackageData = strings.Split(s, ",")
+			}
+
+			return nil
+		},
+	)
+
+	fset.BoolVar(
+		&opts.PrefixPages, "prefix-pages", opts.PrefixPages,
+		"Write a page listing the packages under every path between the host and the modules (like go.example.com/x for go.example.com/x/mod).",
+	)
+
+	fset.IntVar(
+		&opts.PageSize, "page-size", opts.PageSize,
+		"Packages per page of the listing pages, 0 disables the pagination.",
+	)
+
+	fset.BoolVar(
+		&opts.Sitemap, "sitemap", opts.Sitemap,
+		"Write a sitemap.xml with the package pages of every site, split into a sitemap index for large sites.",
+	)
+
+	fset.IntVar(
+		&opts.Jobs, "jobs", opts.Jobs,
+		"Repositories generated at once.",
+	)
+
+	fset.IntVar(
+		&opts.CloneJobs, "clone-jobs", opts.CloneJobs,
+		"Repositories cloned or fetched at once (git commands), 0 disables the limit.",
+	)
+
+	fset.IntVar(
+		&opts.ListJobs, "list-jobs", opts.ListJobs,
+		"Modules loaded at once (go list commands), 0 disables the limit.",
+	)
+
+	fset.IntVar(
+		&opts.MaxProcs, "max-procs", opts.MaxProcs,
+		"Subprocesses running at once across every kind of job, for bounding the memory of small hosts, 0 disables the limit.",
+	)
+
+	fset.BoolVar(
+		&opts.Badges, "badges", opts.Badges,
+		"Derive CI badges of known forges for repositories without configured badges.",
+	)
+
+	fset.BoolVar(
+		&opts.GoBadge, "go-badge", opts.GoBadge,
+		"Write a badge of the Go version required by every module, as <module>/badge/go.json (shields.io endpoint) and go.svg.",
+	)
+
+	fset.BoolVar(
+		&opts.Vulncheck, "vulncheck", opts.Vulncheck,
+		"Run govulncheck on every module and render its findings into module pages.",
+	)
+
+	fset.StringVar(
+		&opts.PublicProxy, "public-proxy", opts.PublicProxy,
+		"Module proxy (like https://proxy.golang.org) asked for the tagged versions of public repositories, without fetching them, module pages flag the unknown ones.",
+	)
+
+	fset.BoolVar(
+		&opts.DepsGraph, "deps-graph", opts.DepsGraph,
+		"Write a dependency graph page of the site modules ("+depsPage+").",
+	)
+
+	fset.BoolVar(
+		&opts.ImportGraph, "import-graph", opts.ImportGraph,
+		"Write the graph of the imports between the site modules, from go list -deps ("+importsPage+", "+importsDOT+" and "+importsGraph+").",
+	)
+
+	fset.BoolVar(
+		&opts.CheckPages, "validate", opts.CheckPages,
+		"Check the go-import and go-source meta tags of the written pages, and fail on malformed ones.",
+	)
+
+	fset.StringVar(
+		&opts.Format, "format", opts.Format,
+		"Output format ("+strings.Join(sortedKeys(formats), ", ")+").",
+	)
+
+	fset.Func(
+		"targets", "Comma separated list of static hosting targets to write configuration files for ("+strings.Join(sortedKeys(targets), ", ")+").",
+		func(s string) error {
+			opts.Targets = strings.Split(s, ",")
+			return nil
+		},
+	)
+
+	fset.BoolVar(
+		&opts.DocsRedirect, "docs-redirect", opts.DocsRedirect,
+		"Redirect browsers to pkg.go.dev in the static hosting targets configuration.",
+	)
+
+	fset.StringVar(
+		&opts.Upload, "upload", opts.Upload,
+		"Sync the output directory to the given storage URL ("+strings.Join(sortedKeys(uploaders), "://, ")+"://).",
+	)
+
+	fset.DurationVar(
+		&opts.StaleAfter, "stale-after", opts.StaleAfter,
+		"Generate the repositories that fail to update from their last checkout, marking their pages as stale when it is older than the given duration.",
+	)
+
+	fset.StringVar(
+		&opts.DiscoveryCache, "discovery-cache", opts.DiscoveryCache,
+		"Directory where the responses of the discovery APIs are cached, for conditional requests across runs.",
+	)
+
+	fset.DurationVar(
+		&opts.DiscoveryTTL, "discovery-ttl", opts.DiscoveryTTL,
+		"Use the cached discovery API responses younger than the given duration without requests.",
+	)
+
+	fset.IntVar(
+		&opts.DiscoveryBudget, "discovery-budget", opts.DiscoveryBudget,
+		"Maximum number of discovery API requests per run, the cached responses are used for the rest (0 for no limit).",
+	)
+
+	fset.DurationVar(
+		&opts.DiscoveryMaxWait, "discovery-max-wait", opts.DiscoveryMaxWait,
+		"Longest wait for the reset of a discovery API rate limit, the cached responses are used for longer ones.",
+	)
+
+	fset.StringVar(
+		&opts.State, "state", opts.State,
+		"JSON file recording the history of the modules across runs: when they were first generated, their releases and last results.",
+	)
+
+	fset.Func(
+		"notify", "Comma separated list of URLs notified of the generations that change the site or fail ("+strings.Join(sortedKeys(notifiers), "://, ")+"://).",
+		func(s string) error {
+			opts.Notify = strings.Split(s, ",")
+			return nil
+		},
+	)
+
+	fset.StringVar(
+		&opts.Archive, "archive", opts.Archive,
+		"Write the output directory into the given .tar.gz or .zip archive.",
+	)
+
+	fset.BoolVar(
+		&opts.GHPages, "gh-pages", opts.GHPages,
+		"Write the files for publishing with GitHub Pages, same as the gh-pages target.",
+	)
+
+	fset.StringVar(
+		&opts.GHPagesRepo, "gh-pages-repo", opts.GHPagesRepo,
+		"Push the generated site to the gh-pages branch of the given repository, implies -gh-pages.",
+	)
+
+	fset.StringVar(
+		&opts.Publish, "publish", opts.Publish,
+		"Commit the output directory into the given repository and push it, keeping the branch history.",
+	)
+
+	fset.StringVar(
+		&opts.PublishBranch, "publish-branch", opts.PublishBranch,
+		"Branch of the -publish repository, created if it doesn't exist.",
+	)
+
+	fset.StringVar(
+		&opts.PublishDir, "publish-dir", opts.PublishDir,
+		"Directory of the -publish repository the output is written to, its other files are kept (default the repository root).",
+	)
+
+	fset.StringVar(
+		&opts.PublishMessage, "publish-message", opts.PublishMessage,
+		"Go template of the -publish commit message, executed with the generation summary.",
+	)
+
+	return fset
+}
+
+func (opts *Options) Parse(fset *flag.FlagSet, args []string) error {
 	if err := fset.Parse(args); err != nil {
 		return err
 	}
@@ -74,163 +1043,441 @@ func (opts *Options) ParseFlags(args []string) error {
 	return opts.Validate()
 }
 
+// selectRepos returns the repositories in only (all of them if empty)
+// that aren't in skip. Repositories are given by URL or name.
+func selectRepos(repos []*Repo, only, skip []string) ([]*Repo, error) {
+	matches := func(r *Repo, list []string) bool {
+		for _, s := range list {
+			if repoKey(s) == repoKey(r.URL) || s == repoName(r.URL) || s+".git" == repoName(r.URL) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, s := range append(only, skip...) {
+		if !slices.ContainsFunc(repos, func(r *Repo) bool { return matches(r, []string{s}) }) {
+			return nil, fmt.Errorf("unknown repository %q", s)
+		}
+	}
+
+	var selected []*Repo
+
+	for _, r := range repos {
+		if (len(only) == 0 || matches(r, only)) && !matches(r, skip) {
+			selected = append(selected, r)
+		}
+	}
+
+	return selected, nil
+}
+
+func modeFlag(mode *fs.FileMode) func(string) error {
+	return func(s string) error {
+		m, err := strconv.ParseUint(s, 8, 32)
+		if err != nil || m == 0 || m > 0777 {
+			return fmt.Errorf("invalid permissions %q", s)
+		}
+
+		*mode = fs.FileMode(m)
+
+		return nil
+	}
+}
+
 func (opts *Options) Validate() error {
 	opts.Config = filepath.Clean(opts.Config)
 	opts.Source = filepath.Clean(opts.Source)
 	opts.Output = filepath.Clean(opts.Output)
 
-	return nil
-}
+	if opts.Netrc != "" {
+		opts.Netrc = filepath.Clean(opts.Netrc)
+	}
 
-func cloneRepo(dst, src string) error {
-	if dst == "" {
-		dst = path.Base(src)
+	if opts.Upload != "" {
+		u, err := url.Parse(opts.Upload)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := uploaders[u.Scheme]; !ok {
+			return fmt.Errorf("unsupported upload URL %q", opts.Upload)
+		}
 	}
 
-	if _, err := os.Stat(dst); err == nil {
-		return runCmd(dst, "git", "pull", "origin", "master")
+	for _, raw := range opts.Notify {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid notification URL %q", raw)
+		}
+
+		if _, ok := notifiers[u.Scheme]; !ok {
+			return fmt.Errorf("unsupported notification URL %q", raw)
+		}
 	}
 
-	if err := runCmd(".", "git", "clone", src, dst); err != nil {
-		if err := os.RemoveAll(dst); err != nil {
-			return err
+	if opts.Publish != "" {
+		if _, err := template.New("message").Parse(opts.PublishMessage); err != nil {
+			return fmt.Errorf("invalid publish message: %w", err)
 		}
 
-		return err
+		dir := path.Clean(strings.Trim(filepath.ToSlash(opts.PublishDir), "/"))
+		if dir == ".." || strings.HasPrefix(dir, "../") || dir == ".git" || strings.HasPrefix(dir, ".git/") {
+			return fmt.Errorf("invalid publish directory %q", opts.PublishDir)
+		}
+
+		if opts.PublishDir = dir; dir == "." {
+			opts.PublishDir = ""
+		}
+
+		if !validBranch(opts.PublishBranch) {
+			return fmt.Errorf("invalid publish branch %q", opts.PublishBranch)
+		}
 	}
 
-	return nil
-}
+	if a := opts.Archive; a != "" && !strings.HasSuffix(a, ".tar.gz") && !strings.HasSuffix(a, ".tgz") && !strings.HasSuffix(a, ".zip") {
+		return fmt.Errorf("unsupported archive format %q", a)
+	}
 
-func getReposURL(configFile string) ([]string, error) {
-	f, err := os.Open(configFile)
-	if err != nil {
-		return nil, err
+	if _, ok := formats[opts.Format]; !ok {
+		return fmt.Errorf("unknown format %q", opts.Format)
 	}
 
-	defer f.Close()
-	reposURL := []string{}
-	s := bufio.NewScanner(f)
+	if (opts.Templates != "" || opts.Static != "") && opts.Format != "html" {
+		return fmt.Errorf("-templates and -static need the html format")
+	}
 
-	for s.Scan() {
-		reposURL = append(reposURL, s.Text())
+	if opts.GitMirrors != "" {
+		opts.GitMirrors = filepath.Clean(opts.GitMirrors)
 	}
 
-	if err := s.Err(); err != nil {
-		return nil, err
+	if opts.GitMirrorURL != "" {
+		u, err := url.Parse(opts.GitMirrorURL)
+		if err != nil || u.Scheme == "" {
+			return fmt.Errorf("invalid git mirror URL %q", opts.GitMirrorURL)
+		}
+
+		opts.GitMirrorURL = strings.TrimSuffix(opts.GitMirrorURL, "/")
 	}
 
-	return reposURL, nil
-}
+	if opts.BaseURL != "" {
+		u, err := url.Parse(opts.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid base URL %q", opts.BaseURL)
+		}
 
-func genPackages(opts *Options) error {
-	if opts.Clean {
-		if err := os.RemoveAll(opts.Output); err != nil {
-			return err
+		opts.BaseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	}
+
+	if _, ok := layouts[opts.Layout]; !ok {
+		return fmt.Errorf("unknown layout %q", opts.Layout)
+	}
+
+	if opts.Host != "" {
+		host, err := hostToASCII(opts.Host)
+		if err != nil {
+			return fmt.Errorf("invalid host %q: %w", opts.Host, err)
+		}
+
+		opts.Host = host
+
+		if opts.Layout == "tree" {
+			opts.Layout = "host"
 		}
 	}
 
-	if err := os.Mkdir(opts.Output, 0755); err != nil && !os.IsExist(err) {
-		return err
+	if opts.Layout != "tree" && opts.Format != "html" {
+		return fmt.Errorf("the %s layout needs the html format", opts.Layout)
 	}
 
-	reposURL, err := getReposURL(opts.Config)
-	if err != nil {
-		return err
+	if _, ok := sbomFormats[opts.SBOM]; opts.SBOM != "" && !ok {
+		return fmt.Errorf("unknown SBOM format %q", opts.SBOM)
 	}
 
-	for _, repoURL := range reposURL {
-		name := path.Base(repoURL)
-		repo := filepath.Join(opts.Source, name)
+	if opts.DiscoveryBudget < 0 {
+		return fmt.Errorf("invalid discovery budget %d", opts.DiscoveryBudget)
+	}
 
-		if err := cloneRepo(repo, repoURL); err != nil {
-			return err
+	if opts.Jobs < 1 {
+		return fmt.Errorf("invalid number of jobs %d", opts.Jobs)
+	}
+
+	if opts.CloneJobs < 0 || opts.ListJobs < 0 || opts.MaxProcs < 0 {
+		return errors.New("negative job limits")
+	}
+
+	if opts.PageSize < 0 {
+		return fmt.Errorf("invalid page size %d", opts.PageSize)
+	}
+
+	for _, name := range opts.PackageData {
+		if _, ok := pkgDataFiles[name]; !ok {
+			return fmt.Errorf("unknown package data file %q", name)
 		}
+	}
 
-		output, err := runCmdOutput(repo, "go", "list", "-m")
-		if err != nil {
-			return err
+	for _, v := range opts.PageVariants {
+		if _, ok := pageVariants[v]; !ok {
+			return fmt.Errorf("unknown page variant %q", v)
 		}
+	}
 
-		pkg := Package{}
-		pkg.Source = repoURL
-		pkg.Module = string(bytes.TrimSpace(output))
-		pkg.ImportPath = pkg.Module
-		dst := filepath.Join(opts.Output, pkg.ImportPath, "index.html")
+	if !slices.Contains(pathStyles, opts.PathStyle) {
+		return fmt.Errorf("unknown path style %q", opts.PathStyle)
+	}
 
-		if err := writePackage(dst, pkg); err != nil {
-			return err
-		}
+	if !accentRe.MatchString(opts.Accent) {
+		return fmt.Errorf("invalid accent color %q", opts.Accent)
+	}
 
-		output, err = runCmdOutput(repo, "go", "list",
-			"-f", "{{ .ImportPath }} {{ .Doc }}",
-			"./...",
-		)
+	if !slices.Contains(emailModes, opts.ContributorEmails) {
+		return fmt.Errorf("unknown contributor emails mode %q", opts.ContributorEmails)
+	}
 
-		if err != nil {
-			return err
-		}
+	if (opts.GHPages || opts.GHPagesRepo != "") && !slices.Contains(opts.Targets, "gh-pages") {
+		opts.Targets = append(opts.Targets, "gh-pages")
+	}
 
-		for _, entry := range bytes.Split(bytes.TrimSpace(output), []byte{'\n'}) {
-			x := bytes.SplitN(entry, []byte{' '}, 2)
-			pkg.ImportPath, pkg.Description = string(x[0]), string(x[1])
-			dst := filepath.Join(opts.Output, pkg.ImportPath, "index.html")
+	for _, t := range opts.Targets {
+		if _, ok := targets[t]; !ok {
+			return fmt.Errorf("unknown target %q", t)
+		}
+	}
 
-			if err := writePackage(dst, pkg); err != nil {
-				return err
-			}
+	for _, name := range opts.Inventory {
+		if _, ok := inventories[name]; !ok {
+			return fmt.Errorf("unknown inventory %q", name)
 		}
 	}
 
 	return nil
 }
 
-func runCmd(dir string, args ...string) error {
-	return runCmdWrite(os.Stdout, dir, args...)
+// cmdRunner runs the external commands of a generator.
+type cmdRunner struct {
+	// Bounds the subprocesses running at once (git, go list, govulncheck…)
+	// across every kind of job, see Options.MaxProcs.
+	procs semaphore
+
+	// Set by the slash path style, see cmdPath.
+	slashPaths bool
 }
 
-func runCmdOutput(dir string, args ...string) ([]byte, error) {
-	buf := bytes.NewBuffer(nil)
-	err := runCmdWrite(buf, dir, args...)
+func newCmdRunner(opts *Options) cmdRunner {
+	return cmdRunner{
+		procs:      newSemaphore(opts.MaxProcs),
+		slashPaths: opts.PathStyle == "slash",
+	}
+}
 
-	return buf.Bytes(), err
+func (c cmdRunner) runCmd(dir string, env []string, args ...string) error {
+	return c.runCmdWrite(os.Stdout, dir, env, args...)
 }
 
-func runCmdWrite(w io.Writer, dir string, args ...string) error {
-	c := exec.Command(args[0], args[1:]...)
-	c.Stdout = w
-	c.Stderr = os.Stderr
-	c.Dir = dir
+func (c cmdRunner) runCmdOutput(dir string, env []string, args ...string) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	err := c.runCmdWrite(buf, dir, env, args...)
 
-	return c.Run()
+	return buf.Bytes(), err
 }
 
-func writePackage(dst string, pkg Package) error {
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
-		return err
-	}
+func (c cmdRunner) runCmdWrite(w io.Writer, dir string, env []string, args ...string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	cmd.Dir = dir
+	cmd.Env = env
 
-	f, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
+	c.procs.acquire()
+	defer c.procs.release()
 
-	defer f.Close()
+	return cmd.Run()
+}
 
-	return goPkgTmpl.Execute(f, pkg)
+// goGetPage is the name of the minimal page for the Go toolchain, written
+// next to the human page of every package.
+const goGetPage = "go-get.html"
+
+// writePackage writes the HTML pages of the package with the built-in
+// templates, see pageTemplates.
+func writePackage(out Output, pkg Package) error {
+	return defaultPages.write(out, pkg)
 }
 
 var goPkgTmpl = template.Must(template.New("package").Parse(`<!DOCTYPE html>
-<html>
+<html lang="{{ .Locale.HTMLLang }}">
 <head>
   <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
-  <meta name="go-import" content="{{ .Module }} git {{ .Source }}"/>
-  <meta name="go-source" content="{{ .Module }} {{ .Source }} {{ .Source }}/tree/master{/dir} {{ .Source }}/blob/master{/dir}/{file}#L{line}"/>
+  <meta name="viewport" content="width=device-width, initial-scale=1"/>
+  <link rel="stylesheet" href="/style.css"/>
+  <meta name="go-import" content="{{ .GoImport }}"/>
+  <meta name="go-source" content="{{ .GoSource }}"/>
+  <script type="application/ld+json">{{ .StructuredData }}</script>
 </head>
 <body>
-  <h1>{{ .ImportPath }}</h1>
-  <p>{{ .Description }}</p>
-  <p><a href="https://pkg.go.dev/{{ .ImportPath }}/">See the package documentation.</a></p>
+  {{- with .Breadcrumbs }}
+  <nav class="breadcrumbs">
+  {{- range . }}
+    {{ if .URL }}<a href="{{ .URL }}">{{ .Name }}</a>{{ else }}{{ .Name }}{{ end }} /
+  {{- end }}
+  </nav>
+  {{- end }}
+  <h1>{{ .DisplayPath }}</h1>
+  {{- if and .IsModule .Info }}{{ with .Info.Badges }}
+  <p class="badges">
+  {{- range . }}
+    {{ if .Link }}<a href="{{ .Link }}"><img src="{{ .Image }}" alt="{{ .Name }}"></a>{{ else }}<img src="{{ .Image }}" alt="{{ .Name }}">{{ end }}
+  {{- end }}
+  </p>
+  {{- end }}{{ end }}
+  <p>{{ .DescriptionHTML }}</p>
+  {{- with .Info }}{{ with .Latest }}
+  <p class="version">{{ $.Locale.T "Latest version: %s" . }}{{ with $.Info.LatestTime }} ({{ .Format "2006-01-02" }}){{ end }}</p>
+  {{- end }}{{ end }}
+  {{- if .IsModule }}{{ with .Info }}{{ if .Stale }}
+  <p class="stale">{{ $.Locale.T "This module couldn't be updated since %s, its information may be outdated." (.Refreshed.Format "2006-01-02") }}</p>
+  {{- end }}{{ with .Refreshed }}{{ if not .IsZero }}
+  <p class="refreshed">{{ $.Locale.T "Last updated %s" ($.Ago .) }}</p>
+  {{- end }}{{ end }}{{ end }}{{ end }}
+  {{- if .IsModule }}{{ with .Info }}{{ with .GoMod }}{{ if .Go }}
+  <p class="go-version">{{ $.Locale.T "Requires Go %s" .Go }}{{ with .Toolchain }} ({{ $.Locale.T "toolchain %s" . }}){{ end }}</p>
+  {{- end }}{{ end }}{{ end }}{{ end }}
+  {{- if .IsModule }}{{ with .Info }}{{ with .GoMod }}{{ with .Direct }}
+  <h2 id="pkg-dependencies">{{ $.Locale.T "Dependencies" }}</h2>
+  <ul>
+  {{- range . }}
+    <li><a href="{{ .URL }}">{{ .DisplayPath }}</a> {{ .Version }}</li>
+  {{- end }}
+  </ul>
+  {{- end }}{{ end }}{{ end }}{{ end }}
+  <p><a href="https://pkg.go.dev/{{ .ImportPath }}/">{{ .Locale.T "See the package documentation." }}</a></p>
+  {{- with .Doc }}{{ with .Symbols }}
+  <h2 id="pkg-index">{{ $.Locale.T "Index" }}</h2>
+  <ul class="symbols">
+  {{- range . }}
+    <li><a href="https://pkg.go.dev/{{ $.ImportPath }}#{{ .Anchor }}">{{ .Kind }} {{ .Name }}</a></li>
+  {{- end }}
+  </ul>
+  {{- end }}{{ end }}
+  {{- if and .IsModule .Info }}{{ if .Info.Changelog }}
+  <p><a href="{{ .ChangelogURL }}">{{ .Locale.T "See the changelog." }}</a></p>
+  {{- end }}{{ end }}
+  {{- if and .IsModule .Info }}{{ with .Info.SBOM }}
+  <p class="sbom"><a href="{{ . }}">{{ $.Locale.T "Software bill of materials" }}</a></p>
+  {{- end }}{{ end }}
+  {{- if and .IsModule .Info }}{{ if .Info.VulnChecked }}
+  <h2 id="pkg-vulnerabilities">{{ .Locale.T "Vulnerabilities" }} {{ with .Info.Vulns }}<span class="badge badge-vulns">{{ $.Locale.T "%d found" (len .) }}</span>{{ else }}<span class="badge badge-ok">{{ $.Locale.T "none found" }}</span>{{ end }}</h2>
+  {{- with .Info.Vulns }}
+  <ul class="vulns">
+  {{- range . }}
+    <li><a href="{{ .URL }}">{{ .ID }}</a>{{ with .Summary }} - {{ . }}{{ end }}{{ if not .Called }} ({{ $.Locale.T "not called" }}){{ end }}</li>
+  {{- end }}
+  </ul>
+  {{- end }}
+  {{- end }}{{ end }}
+  {{- if and .IsModule .Info }}{{ if .Info.PublishChecked }}
+  <h2 id="pkg-published">{{ .Locale.T "Public proxy" }} {{ with .Info.Unpublished }}<span class="badge badge-vulns">{{ $.Locale.T "%d unpublished" (len .) }}</span>{{ else }}<span class="badge badge-ok">{{ $.Locale.T "all published" }}</span>{{ end }}</h2>
+  {{- with .Info.Unpublished }}
+  <p>{{ $.Locale.T "Tagged versions unknown to the public module proxy:" }}</p>
+  <ul class="unpublished">
+  {{- range . }}
+    <li>{{ . }}</li>
+  {{- end }}
+  </ul>
+  {{- end }}
+  {{- end }}{{ end }}
+  {{- if .IsModule }}{{ with .Info }}{{ with .Meta }}
+  <dl class="meta">
+  {{- range $k, $v := . }}
+    <dt>{{ $k }}</dt><dd>{{ $v }}</dd>
+  {{- end }}
+  </dl>
+  {{- end }}{{ end }}{{ end }}
+  {{- if .IsModule }}{{ with .Info }}{{ with .Contributors }}
+  <h2 id="pkg-contributors">{{ $.Locale.T "Contributors" }}</h2>
+  <ul class="contributors">
+  {{- range . }}
+    <li>{{ .Name }}{{ with .Email }} &lt;{{ . }}&gt;{{ end }} ({{ .Commits }})</li>
+  {{- end }}
+  </ul>
+  {{- end }}{{ end }}{{ end }}
+  {{- if .IsModule }}{{ with .Info }}{{ with .README }}
+  <div class="readme">
+  {{ . }}
+  </div>
+  {{- end }}{{ end }}{{ end }}
+  {{- with .Files }}
+  <h2 id="pkg-files">{{ $.Locale.T "Files" }}</h2>
+  <ul>
+  {{- range . }}
+    <li><a href="{{ $.SourceDir }}/{{ . }}.html">{{ . }}</a></li>
+  {{- end }}
+  </ul>
+  {{- end }}
+  {{- with .Doc }}{{ if or .Doc .Consts .Vars .Funcs .Types .Examples }}
+  <div class="api">
+  {{- with .Doc }}
+  <h2 id="pkg-overview">{{ $.Locale.T "Overview" }}</h2>
+  {{ . }}
+  {{- end }}
+  {{- with .Consts }}
+  <h2 id="pkg-constants">{{ $.Locale.T "Constants" }}</h2>
+  {{- range . }}{{ template "decl" . }}{{ end }}
+  {{- end }}
+  {{- with .Vars }}
+  <h2 id="pkg-variables">{{ $.Locale.T "Variables" }}</h2>
+  {{- range . }}{{ template "decl" . }}{{ end }}
+  {{- end }}
+  {{- with .Funcs }}
+  <h2 id="pkg-functions">{{ $.Locale.T "Functions" }}</h2>
+  {{- range . }}
+  <h3 id="{{ .Name }}">func {{ .Name }}</h3>
+  {{- template "decl" . }}
+  {{- end }}
+  {{- end }}
+  {{- with .Types }}
+  <h2 id="pkg-types">{{ $.Locale.T "Types" }}</h2>
+  {{- range . }}
+  <h3 id="{{ .Name }}">type {{ .Name }}</h3>
+  {{- template "decl" .DocDecl }}
+  {{- range .Consts }}{{ template "decl" . }}{{ end }}
+  {{- range .Vars }}{{ template "decl" . }}{{ end }}
+  {{- range .Funcs }}
+  <h4 id="{{ .Name }}">func {{ .Name }}</h4>
+  {{- template "decl" . }}
+  {{- end }}
+  {{- range .Methods }}
+  <h4 id="{{ .Name }}">func {{ .Name }}</h4>
+  {{- template "decl" . }}
+  {{- end }}
+  {{- end }}
+  {{- end }}
+  {{- with .Examples }}
+  <h2 id="pkg-examples">{{ $.Locale.T "Examples" }}</h2>
+  {{- range . }}
+  <h3 id="{{ .ID }}">{{ $.Locale.T "Example" }} {{ .Name }}</h3>
+  {{ .Doc }}
+  <pre class="example">{{ .Code }}</pre>
+  {{- with .Output }}
+  <p>{{ $.Locale.T "Output:" }}</p>
+  <pre class="output">{{ . }}</pre>
+  {{- end }}
+  {{- end }}
+  {{- end }}
+  </div>
+  {{- end }}{{ end }}
 </body>
 </html>
+{{- define "decl" }}
+  <pre>{{ .Decl }}</pre>
+  {{ .Doc }}
+{{- end }}
+`))
+
+// goGetTmpl is the page for go-get=1 requests, it only has what the Go
+// toolchain reads.
+var goGetTmpl = template.Must(template.New("go-get").Parse(`<!DOCTYPE html>
+<meta name="go-import" content="{{ .GoImport }}">
+<meta name="go-source" content="{{ .GoSource }}">
 `))
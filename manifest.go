@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// manifestFile lists the files written into the output directory, so
+// cleaning and pruning never touch files placed there by hand.
+const manifestFile = ".vanitic-manifest"
+
+// manifest records the names of the written files.
+type manifest struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func newManifest() *manifest {
+	return &manifest{names: make(map[string]bool)}
+}
+
+func (m *manifest) add(name string) {
+	m.mu.Lock()
+	m.names[path.Clean(name)] = true
+	m.mu.Unlock()
+}
+
+// readManifest returns the files listed in the manifest of the directory,
+// which may not have one.
+func readManifest(dir string) (map[string]bool, error) {
+	names := make(map[string]bool)
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return names, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' || !fs.ValidPath(line) {
+			continue
+		}
+
+		names[line] = true
+	}
+
+	return names, s.Err()
+}
+
+func writeManifest(out DirOutput, names map[string]bool) error {
+	var b bytes.Buffer
+
+	b.WriteString("# Files written by vanitic, removed by -clean and pruned when stale.\n")
+
+	for _, name := range sortedKeys(names) {
+		b.WriteString(name + "\n")
+	}
+
+	// Not recorded in itself.
+	out.manifest = nil
+
+	return out.WriteFile(manifestFile, b.Bytes())
+}
+
+// removeManaged removes the listed files of the directory that aren't
+// kept, and the directories left empty by them.
+func removeManaged(dir string, names, keep map[string]bool) error {
+	var dirs []string
+
+	for name := range names {
+		if keep[name] {
+			continue
+		}
+
+		err := os.Remove(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		for d := path.Dir(name); d != "."; d = path.Dir(d) {
+			dirs = append(dirs, d)
+		}
+	}
+
+	// Deepest first, non-empty directories fail and are left alone.
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+
+	for _, d := range dirs {
+		os.Remove(filepath.Join(dir, filepath.FromSlash(d)))
+	}
+
+	return nil
+}
+
+// copyUnmanaged copies the files of src that aren't listed in its manifest
+// into dst, unless dst already has them.
+func copyUnmanaged(src string, dst DirOutput) error {
+	names, err := readManifest(src)
+	if err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !d.Type().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(rel)
+		if names[name] || name == manifestFile {
+			return nil
+		}
+
+		if _, err := os.Lstat(filepath.Join(dst.Dir, rel)); err == nil {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		// Names are already output paths, they aren't mapped again.
+		dst.Layout, dst.manifest = nil, nil
+
+		return dst.WriteFile(name, data)
+	})
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
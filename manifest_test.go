@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyUnmanagedFlatLayout(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+
+	files := map[string]string{
+		manifestFile:       "pkg.html\n",
+		"pkg.html":         "managed",
+		"index.html":       "root",
+		"about/index.html": "about",
+		"static/logo.svg":  "logo",
+	}
+
+	for name, data := range files {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := DirOutput{Dir: dst, Layout: layouts["flat"], manifest: newManifest()}
+	if err := copyUnmanaged(src, out); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"index.html":       "root",
+		"about/index.html": "about",
+		"static/logo.svg":  "logo",
+	} {
+		data, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+
+	for _, name := range []string{"pkg.html", "about.html", "logo.svg", manifestFile} {
+		if _, err := os.Stat(filepath.Join(dst, name)); err == nil {
+			t.Errorf("%s was copied", name)
+		}
+	}
+
+	if len(out.manifest.names) != 0 {
+		t.Errorf("unmanaged files recorded in the manifest: %v", out.manifest.names)
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// renderMarkdown renders a CommonMark subset (headings, paragraphs, lists,
+// quotes, code blocks, rules, emphasis, code spans, links and images) as
+// HTML. Raw HTML is dropped and any other text is escaped, so the result is
+// safe to embed. Relative links are resolved against base, at the given
+// branch.
+func renderMarkdown(src []byte, base, branch string) template.HTML {
+	md := &mdRenderer{base: base, branch: branch}
+	lines := strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); {
+		i = md.block(lines, i)
+	}
+
+	md.closeList()
+
+	return template.HTML(md.b.String())
+}
+
+// markdownSummary returns the plain text of the first paragraph of a
+// Markdown document, skipping headings, HTML and image-only lines (like
+// badges).
+func markdownSummary(src []byte) string {
+	var para []string
+
+	for _, line := range strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n") {
+		t := strings.TrimSpace(line)
+
+		switch {
+		case t == "":
+			if len(para) > 0 {
+				return mdPlain(strings.Join(para, " "))
+			}
+
+			continue
+		case len(para) == 0 && (mdHeadingRe.MatchString(t) || mdHTMLRe.MatchString(t) ||
+			mdFenceRe.MatchString(t) || mdRuleRe.MatchString(t) || strings.TrimSpace(mdPlain(t)) == ""):
+			continue
+		}
+
+		para = append(para, t)
+	}
+
+	return mdPlain(strings.Join(para, " "))
+}
+
+// mdPlain removes the inline Markdown syntax from the text.
+func mdPlain(text string) string {
+	text = mdImageRe.ReplaceAllString(text, "")
+	text = mdEmptyLinkRe.ReplaceAllString(text, "")
+	text = mdLinkRe.ReplaceAllString(text, "$1")
+	text = mdAutolinkRe.ReplaceAllString(text, "$1")
+	text = mdTagRe.ReplaceAllString(text, "")
+	text = mdCodeRe.ReplaceAllString(text, "$2")
+	text = mdStrongRe.ReplaceAllString(text, "$1$2")
+	text = mdEmRe.ReplaceAllString(text, "$1$2")
+
+	return strings.TrimSpace(text)
+}
+
+type mdRenderer struct {
+	b      strings.Builder
+	base   string
+	branch string
+	list   string // Open list tag, if any.
+
+	spans int // Inline placeholders counter.
+}
+
+var (
+	mdHeadingRe = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdFenceRe   = regexp.MustCompile("^(```|~~~)\\s*([\\w+-]*)")
+	mdRuleRe    = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	mdULRe      = regexp.MustCompile(`^\s{0,3}[-*+]\s+(.*)$`)
+	mdOLRe      = regexp.MustCompile(`^\s{0,3}\d{1,9}[.)]\s+(.*)$`)
+	mdHTMLRe    = regexp.MustCompile(`^\s{0,3}</?[A-Za-z!][^>]*>?`)
+)
+
+// block renders the block starting at lines[i] and returns the index of the
+// next one.
+func (md *mdRenderer) block(lines []string, i int) int {
+	line := lines[i]
+	trimmed := strings.TrimSpace(line)
+
+	switch {
+	case trimmed == "":
+		md.closeList()
+		return i + 1
+	case mdFenceRe.MatchString(trimmed):
+		md.closeList()
+		return md.fence(lines, i)
+	case mdRuleRe.MatchString(line):
+		md.closeList()
+		md.b.WriteString("<hr>\n")
+
+		return i + 1
+	case mdHeadingRe.MatchString(trimmed):
+		md.closeList()
+
+		m := mdHeadingRe.FindStringSubmatch(trimmed)
+		level := min(len(m[1])+1, 6) // The page title is the only h1.
+		tag := "h" + string(rune('0'+level))
+		md.b.WriteString("<" + tag + ">" + md.inline(m[2]) + "</" + tag + ">\n")
+
+		return i + 1
+	case mdHTMLRe.MatchString(line) && !mdAutolinkRe.MatchString(trimmed):
+		// Raw HTML blocks are dropped, up to the next blank line.
+		for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+			i++
+		}
+
+		return i
+	case strings.HasPrefix(trimmed, ">"):
+		md.closeList()
+
+		var quote []string
+		for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+			l := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+			quote = append(quote, strings.TrimPrefix(l, " "))
+		}
+
+		inner := &mdRenderer{base: md.base, branch: md.branch, spans: md.spans}
+		for j := 0; j < len(quote); {
+			j = inner.block(quote, j)
+		}
+
+		inner.closeList()
+		md.spans = inner.spans
+		md.b.WriteString("<blockquote>\n" + inner.b.String() + "</blockquote>\n")
+
+		return i
+	case mdULRe.MatchString(line):
+		return md.item(lines, i, "ul", mdULRe)
+	case mdOLRe.MatchString(line):
+		return md.item(lines, i, "ol", mdOLRe)
+	case strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t"):
+		md.closeList()
+
+		var code []string
+		for ; i < len(lines); i++ {
+			l := lines[i]
+			if strings.TrimSpace(l) != "" && !strings.HasPrefix(l, "    ") && !strings.HasPrefix(l, "\t") {
+				break
+			}
+
+			l = strings.TrimPrefix(l, "\t")
+			code = append(code, strings.TrimPrefix(l, "    "))
+		}
+
+		for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+			code = code[:len(code)-1]
+		}
+
+		md.b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+
+		return i
+	}
+
+	md.closeList()
+
+	// Paragraphs continue until a blank line or another block.
+	var para []string
+
+	for ; i < len(lines); i++ {
+		l := lines[i]
+		t := strings.TrimSpace(l)
+
+		if len(para) > 0 && (t == "" || mdFenceRe.MatchString(t) || mdHeadingRe.MatchString(t) ||
+			strings.HasPrefix(t, ">") || mdULRe.MatchString(l) || mdOLRe.MatchString(l) || mdRuleRe.MatchString(l)) {
+			break
+		}
+
+		para = append(para, t)
+	}
+
+	md.b.WriteString("<p>" + md.inline(strings.Join(para, "\n")) + "</p>\n")
+
+	return i
+}
+
+func (md *mdRenderer) fence(lines []string, i int) int {
+	m := mdFenceRe.FindStringSubmatch(strings.TrimSpace(lines[i]))
+	marker, lang := m[1], m[2]
+
+	var code []string
+	for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), marker); i++ {
+		code = append(code, lines[i])
+	}
+
+	md.b.WriteString("<pre><code")
+
+	if lang != "" {
+		md.b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+	}
+
+	md.b.WriteString(">" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+
+	return i + 1
+}
+
+// item renders a list item, continuation lines are indented.
+func (md *mdRenderer) item(lines []string, i int, tag string, re *regexp.Regexp) int {
+	if md.list != tag {
+		md.closeList()
+		md.b.WriteString("<" + tag + ">\n")
+		md.list = tag
+	}
+
+	text := []string{re.FindStringSubmatch(lines[i])[1]}
+
+	for i++; i < len(lines); i++ {
+		l := lines[i]
+		if strings.TrimSpace(l) == "" || !strings.HasPrefix(l, "  ") || mdULRe.MatchString(l) || mdOLRe.MatchString(l) {
+			break
+		}
+
+		text = append(text, strings.TrimSpace(l))
+	}
+
+	md.b.WriteString("<li>" + md.inline(strings.Join(text, "\n")) + "</li>\n")
+
+	return i
+}
+
+func (md *mdRenderer) closeList() {
+	if md.list != "" {
+		md.b.WriteString("</" + md.list + ">\n")
+		md.list = ""
+	}
+}
+
+var (
+	mdCodeRe     = regexp.MustCompile("(`+)(.+?)(`+)")
+	mdImageRe    = regexp.MustCompile(`!\[([^\]]*)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	mdLinkRe     = regexp.MustCompile(`\[([^\]]+)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	mdAutolinkRe = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	mdStrongRe   = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	mdEmRe       = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*|\b_(\S(?:.*?\S)?)_\b`)
+	mdTagRe      = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+
+	mdEmptyLinkRe = regexp.MustCompile(`\[\s*\]\([^)]*\)`)
+)
+
+// inline renders the inline elements of the text. Code spans, links and
+// images are replaced by placeholders before escaping, so their content
+// isn't interpreted.
+func (md *mdRenderer) inline(text string) string {
+	type span struct{ key, html string }
+
+	var spans []span
+
+	// Keys are unique in the document, since link labels are rendered
+	// recursively and may contain the placeholders of their caller.
+	hold := func(s string) string {
+		md.spans++
+		key := "\x00" + strconv.Itoa(md.spans) + "\x00"
+		spans = append(spans, span{key, s})
+
+		return key
+	}
+
+	text = mdCodeRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := mdCodeRe.FindStringSubmatch(s)
+		if m[1] != m[3] {
+			return s
+		}
+
+		return hold("<code>" + html.EscapeString(strings.TrimSpace(m[2])) + "</code>")
+	})
+
+	text = mdImageRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := mdImageRe.FindStringSubmatch(s)
+
+		u, ok := md.url(m[2], true)
+		if !ok {
+			return hold(html.EscapeString(m[1]))
+		}
+
+		return hold(`<img src="` + html.EscapeString(u) + `" alt="` + html.EscapeString(m[1]) + `">`)
+	})
+
+	text = mdLinkRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := mdLinkRe.FindStringSubmatch(s)
+		label := md.inline(m[1])
+
+		u, ok := md.url(m[2], false)
+		if !ok {
+			return hold(label)
+		}
+
+		return hold(`<a href="` + html.EscapeString(u) + `">` + label + `</a>`)
+	})
+
+	text = mdAutolinkRe.ReplaceAllStringFunc(text, func(s string) string {
+		u := mdAutolinkRe.FindStringSubmatch(s)[1]
+		return hold(`<a href="` + html.EscapeString(u) + `">` + html.EscapeString(u) + `</a>`)
+	})
+
+	text = mdTagRe.ReplaceAllString(text, "")
+	text = html.EscapeString(text)
+
+	text = mdStrongRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := mdStrongRe.FindStringSubmatch(s)
+		return "<strong>" + m[1] + m[2] + "</strong>"
+	})
+
+	text = mdEmRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := mdEmRe.FindStringSubmatch(s)
+		return "<em>" + m[1] + m[2] + "</em>"
+	})
+
+	text = strings.ReplaceAll(text, "\n", " ")
+
+	// Held spans may contain previous placeholders.
+	for i := len(spans) - 1; i >= 0; i-- {
+		text = strings.Replace(text, spans[i].key, spans[i].html, 1)
+	}
+
+	return text
+}
+
+// url returns the absolute URL for a link or image, relative URLs are
+// resolved against the base, which points at the repository files. Only
+// http, https and mailto (for links) schemes are allowed.
+func (md *mdRenderer) url(raw string, image bool) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return u.String(), true
+	case "mailto":
+		return u.String(), !image
+	case "":
+	default:
+		return "", false
+	}
+
+	if u.Host != "" || md.base == "" {
+		return "", false
+	}
+
+	if u.Path == "" {
+		return u.String(), !image // Fragment.
+	}
+
+	kind := "blob"
+	if image {
+		kind = "raw"
+	}
+
+	p := strings.TrimPrefix(path.Clean("/"+u.Path), "/")
+	u.Path = ""
+
+	return md.base + "/" + kind + "/" + md.branch + "/" + p + u.String(), true
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Metrics collects serve mode metrics and exposes them in the Prometheus
+// text format.
+type Metrics struct {
+	mu sync.Mutex
+
+	requests map[requestKey]uint64
+
+	genBuckets  []float64
+	genCounts   map[string][]uint64 // Per kind, one counter per bucket.
+	genSum      map[string]float64
+	genTotal    map[string]uint64
+	genErrors   map[string]uint64
+	lastSuccess time.Time
+}
+
+type requestKey struct {
+	path   string
+	client string
+	status int
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:   make(map[requestKey]uint64),
+		genBuckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+		genCounts:  make(map[string][]uint64),
+		genSum:     make(map[string]float64),
+		genTotal:   make(map[string]uint64),
+		genErrors:  make(map[string]uint64),
+	}
+}
+
+func (m *Metrics) observeRequest(path, client string, status int) {
+	m.mu.Lock()
+	m.requests[requestKey{path, client, status}]++
+	m.mu.Unlock()
+}
+
+// observeGeneration records a generation of the given kind, "full" or
+// "repo".
+func (m *Metrics) observeGeneration(kind string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		m.genErrors[kind]++
+		return
+	}
+
+	counts, ok := m.genCounts[kind]
+	if !ok {
+		counts = make([]uint64, len(m.genBuckets))
+		m.genCounts[kind] = counts
+	}
+
+	secs := d.Seconds()
+
+	for i, le := range m.genBuckets {
+		if secs <= le {
+			counts[i]++
+		}
+	}
+
+	m.genSum[kind] += secs
+	m.genTotal[kind]++
+	m.lastSuccess = time.Now()
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cw := &countWriter{w: w}
+
+	fmt.Fprintln(cw, "# HELP vanitic_http_requests_total HTTP requests by path, client and status.")
+	fmt.Fprintln(cw, "# TYPE vanitic_http_requests_total counter")
+
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.path != b.path {
+			return a.path < b.path
+		}
+
+		if a.client != b.client {
+			return a.client < b.client
+		}
+
+		return a.status < b.status
+	})
+
+	for _, k := range keys {
+		fmt.Fprintf(cw, "vanitic_http_requests_total{path=%q,client=%q,status=\"%d\"} %d\n",
+			k.path, k.client, k.status, m.requests[k])
+	}
+
+	fmt.Fprintln(cw, "# HELP vanitic_generation_duration_seconds Duration of successful generations.")
+	fmt.Fprintln(cw, "# TYPE vanitic_generation_duration_seconds histogram")
+
+	for _, kind := range sortedKeys(m.genTotal) {
+		for i, le := range m.genBuckets {
+			fmt.Fprintf(cw, "vanitic_generation_duration_seconds_bucket{kind=%q,le=\"%s\"} %d\n",
+				kind, strconv.FormatFloat(le, 'g', -1, 64), m.genCounts[kind][i])
+		}
+
+		fmt.Fprintf(cw, "vanitic_generation_duration_seconds_bucket{kind=%q,le=\"+Inf\"} %d\n", kind, m.genTotal[kind])
+		fmt.Fprintf(cw, "vanitic_generation_duration_seconds_sum{kind=%q} %g\n", kind, m.genSum[kind])
+		fmt.Fprintf(cw, "vanitic_generation_duration_seconds_count{kind=%q} %d\n", kind, m.genTotal[kind])
+	}
+
+	fmt.Fprintln(cw, "# HELP vanitic_generation_errors_total Failed generations.")
+	fmt.Fprintln(cw, "# TYPE vanitic_generation_errors_total counter")
+
+	for _, kind := range sortedKeys(m.genErrors) {
+		fmt.Fprintf(cw, "vanitic_generation_errors_total{kind=%q} %d\n", kind, m.genErrors[kind])
+	}
+
+	fmt.Fprintln(cw, "# HELP vanitic_last_success_timestamp_seconds Time of the last successful generation.")
+	fmt.Fprintln(cw, "# TYPE vanitic_last_success_timestamp_seconds gauge")
+
+	last := 0.0
+	if !m.lastSuccess.IsZero() {
+		last = float64(m.lastSuccess.UnixNano()) / 1e9
+	}
+
+	fmt.Fprintf(cw, "vanitic_last_success_timestamp_seconds %g\n", last)
+
+	return cw.n, cw.err
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+
+	return n, err
+}
+
+// statusWriter records the response status and size, and the metrics label
+// of the served path.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+	path   string
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+
+	n, err := sw.ResponseWriter.Write(p)
+	sw.bytes += int64(n)
+
+	return n, err
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// setPathLabel sets the metrics label for the request path, if w records
+// it.
+func setPathLabel(w http.ResponseWriter, path string) {
+	if sw, ok := w.(*statusWriter); ok {
+		sw.path = path
+	}
+}
+
+func requestClient(r *http.Request) string {
+	if r.URL.Query().Get("go-get") == "1" {
+		return "go-get"
+	}
+
+	return "browser"
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	gofmt "go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// migrateCmd rewrites the module path of a repository checkout from its
+// forge path to its vanity path: go.mod module and require lines, import
+// statements and import comments. Go files are formatted with gofmt.
+func migrateCmd(args []string) error {
+	fset := flag.NewFlagSet("vanitic migrate", flag.ExitOnError)
+
+	from := fset.String(
+		"from", "",
+		"Current module path (default the module path of the go.mod file in the directory).",
+	)
+
+	to := fset.String(
+		"to", "",
+		"Vanity module path (e.g. go.example.com/x).",
+	)
+
+	dryRun := fset.Bool(
+		"n", false,
+		"Only print the files that would be rewritten.",
+	)
+
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	dir := "."
+	if fset.NArg() > 1 {
+		return errors.New("migrate: expected a single directory")
+	} else if fset.NArg() == 1 {
+		dir = fset.Arg(0)
+	}
+
+	if *to == "" {
+		return errors.New("migrate: missing -to")
+	}
+
+	if *from == "" {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return err
+		}
+
+		if *from = parseGoMod(data).Module; *from == "" {
+			return errors.New("migrate: go.mod without module path")
+		}
+	}
+
+	if *from == *to {
+		return fmt.Errorf("migrate: the module path is already %s", *to)
+	}
+
+	m := &migration{from: *from, to: *to}
+	n := 0
+
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		base := d.Name()
+
+		if d.IsDir() {
+			if name != dir && (base == "vendor" || base == "testdata" || base[0] == '.' || base[0] == '_') {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		var rewrite func([]byte) ([]byte, error)
+
+		switch {
+		case base == "go.mod":
+			rewrite = m.goMod
+		case strings.HasSuffix(base, ".go"):
+			rewrite = m.goFile
+		default:
+			return nil
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		out, err := rewrite(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		if bytes.Equal(data, out) {
+			return nil
+		}
+
+		n++
+
+		if *dryRun {
+			fmt.Println(name)
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(name, out, fi.Mode().Perm())
+	})
+
+	if err != nil {
+		return err
+	}
+
+	log.Printf("migrate: %d files from %s to %s, run go mod tidy to update go.sum", n, *from, *to)
+
+	return nil
+}
+
+type migration struct {
+	from, to string
+}
+
+// path returns the new import path of p, if it is under the module path.
+func (m *migration) path(p string) (string, bool) {
+	rest, ok := strings.CutPrefix(p, m.from)
+	if !ok || rest != "" && rest[0] != '/' {
+		return "", false
+	}
+
+	return m.to + rest, true
+}
+
+// goMod rewrites the module paths of go.mod lines, comments aren't touched.
+func (m *migration) goMod(data []byte) ([]byte, error) {
+	re := regexp.MustCompile(`(^|[\s"])` + regexp.QuoteMeta(m.from) + `(/[^\s"]*)?([\s"]|$)`)
+	lines := strings.SplitAfter(string(data), "\n")
+
+	for i, line := range lines {
+		code, comment, ok := strings.Cut(line, "//")
+		code = re.ReplaceAllString(code, "${1}"+m.to+"${2}${3}")
+
+		if ok {
+			code += "//" + comment
+		}
+
+		lines[i] = code
+	}
+
+	return []byte(strings.Join(lines, "")), nil
+}
+
+var importCommentRe = regexp.MustCompile(`^//\s*import\s+("[^"]*")\s*$`)
+
+// goFile rewrites the import statements and the import comment of a Go
+// file. Files without changes are returned as they are.
+func (m *migration) goFile(data []byte) ([]byte, error) {
+	files := token.NewFileSet()
+
+	f, err := parser.ParseFile(files, "", data, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := false
+
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		if np, ok := m.path(p); ok {
+			imp.Path.Value = strconv.Quote(np)
+			changed = true
+		}
+	}
+
+	pkgLine := files.Position(f.Name.End()).Line
+
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if files.Position(c.Pos()).Line != pkgLine {
+				continue
+			}
+
+			sub := importCommentRe.FindStringSubmatch(c.Text)
+			if sub == nil {
+				continue
+			}
+
+			p, _ := strconv.Unquote(sub[1])
+			if np, ok := m.path(p); ok {
+				c.Text = "// import " + strconv.Quote(np)
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return data, nil
+	}
+
+	ast.SortImports(files, f)
+
+	var buf bytes.Buffer
+	if err := gofmt.Node(&buf, files, f); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
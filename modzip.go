@@ -0,0 +1,254 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var majorRe = regexp.MustCompile(`^v([2-9]|[1-9][0-9]+)$`)
+
+// pathMajor returns the major version suffix of a module path (like "v2"),
+// or an empty string if it doesn't have one.
+func pathMajor(modPath string) string {
+	if elem := path.Base(modPath); majorRe.MatchString(elem) {
+		return elem
+	}
+
+	return ""
+}
+
+// TagPrefix returns the prefix of the repository tags for this module. Tags
+// of modules in subdirectories are prefixed by the subdirectory, without the
+// major version suffix of major version subdirectories.
+func (m *Module) TagPrefix() string {
+	dir := m.Subdir
+	if major := pathMajor(m.Path); major != "" && path.Base(dir) == major {
+		dir = path.Dir(dir)
+	}
+
+	if dir == "" || dir == "." {
+		return ""
+	}
+
+	return dir + "/"
+}
+
+// Versions returns the module versions from the repository tags, sorted
+// in ascending order. Tags with a major version that doesn't match the
+// module path are ignored.
+func (m *Module) Versions() ([]string, error) {
+	tags, err := m.cmd.gitTags(m.RepoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := m.TagPrefix()
+	major := pathMajor(m.Path)
+
+	var versions []string
+
+	for _, tag := range tags {
+		v, ok := strings.CutPrefix(tag, prefix)
+		if !ok {
+			continue
+		}
+
+		sv, ok := parseSemver(v)
+		if !ok || sv.build != "" {
+			continue
+		}
+
+		if major == "" && sv.major > 1 || major != "" && "v"+strconv.Itoa(sv.major) != major {
+			continue
+		}
+
+		versions = append(versions, v)
+	}
+
+	sortSemver(versions)
+
+	return versions, nil
+}
+
+// VersionTime returns the time of the commit tagged with the given version.
+func (m *Module) VersionTime(version string) (time.Time, error) {
+	return m.cmd.gitCommitTime(m.RepoDir, m.TagPrefix()+version)
+}
+
+// GoMod returns the go.mod file of the given version.
+func (m *Module) GoMod(version string) ([]byte, error) {
+	return m.cmd.gitShow(m.RepoDir, m.TagPrefix()+version, path.Join(m.Subdir, "go.mod"))
+}
+
+var errNoModule = errors.New("unknown module version")
+
+// WriteZip writes the module zip file of the given version, following the
+// same rules as the Go toolchain: files are prefixed by module@version/,
+// nested modules, vendored packages and non-regular files are excluded, and
+// the repository LICENSE is added to modules in subdirectories without one.
+func (m *Module) WriteZip(w io.Writer, version string) error {
+	rev := m.TagPrefix() + version
+
+	data, err := m.cmd.gitArchive(m.RepoDir, rev, m.Subdir)
+	if err != nil {
+		return errNoModule
+	}
+
+	type file struct {
+		name string
+		data []byte
+	}
+
+	var (
+		files  []file
+		nested []string // Directories with their own go.mod.
+	)
+
+	tr := tar.NewReader(bytes.NewReader(data))
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := hdr.Name
+		if m.Subdir != "" {
+			var ok bool
+			if name, ok = strings.CutPrefix(name, m.Subdir+"/"); !ok {
+				continue
+			}
+		}
+
+		if dir, base := path.Split(name); base == "go.mod" && dir != "" {
+			nested = append(nested, dir)
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, file{name: name, data: content})
+	}
+
+	hasLicense := false
+	prefix := m.Path + "@" + version + "/"
+	zw := zip.NewWriter(w)
+
+files:
+	for _, f := range files {
+		for _, dir := range nested {
+			if strings.HasPrefix(f.name, dir) {
+				continue files
+			}
+		}
+
+		if isVendoredPackage(f.name) {
+			continue
+		}
+
+		if f.name == "LICENSE" {
+			hasLicense = true
+		}
+
+		fw, err := zw.Create(prefix + f.name)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fw.Write(f.data); err != nil {
+			return err
+		}
+	}
+
+	if m.Subdir != "" && !hasLicense {
+		if license, err := m.cmd.gitShow(m.RepoDir, rev, "LICENSE"); err == nil {
+			fw, err := zw.Create(prefix + "LICENSE")
+			if err != nil {
+				return err
+			}
+
+			if _, err := fw.Write(license); err != nil {
+				return err
+			}
+		}
+	}
+
+	return zw.Close()
+}
+
+// isVendoredPackage reports whether the file belongs to a vendored package.
+// It replicates the Go toolchain check, including its offset quirk, since
+// changing it would change module checksums.
+func isVendoredPackage(name string) bool {
+	var i int
+
+	if strings.HasPrefix(name, "vendor/") {
+		i += len("vendor/")
+	} else if j := strings.Index(name, "/vendor/"); j >= 0 {
+		i += len("/vendor/")
+	} else {
+		return false
+	}
+
+	return strings.Contains(name[i:], "/")
+}
+
+// escapeModulePath escapes upper case letters as an exclamation mark
+// followed by the lower case letter, as the module proxy protocol requires.
+func escapeModulePath(p string) string {
+	var b strings.Builder
+
+	for _, r := range p {
+		if 'A' <= r && r <= 'Z' {
+			b.WriteByte('!')
+			r += 'a' - 'A'
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func unescapeModulePath(p string) (string, bool) {
+	var (
+		b    strings.Builder
+		bang bool
+	)
+
+	for _, r := range p {
+		switch {
+		case bang:
+			if r < 'a' || r > 'z' {
+				return "", false
+			}
+
+			b.WriteRune(r - ('a' - 'A'))
+			bang = false
+		case r == '!':
+			bang = true
+		case 'A' <= r && r <= 'Z':
+			return "", false
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String(), !bang
+}
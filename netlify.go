@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+)
+
+// writeNetlify writes the Netlify _redirects and _headers files. go-get
+// requests are rewritten to the minimal pages, rules are forced since the
+// requested paths exist.
+func writeNetlify(s *Site) error {
+	var redirects bytes.Buffer
+
+	for _, pkg := range s.Packages {
+		p := s.Path(pkg)
+		if !s.Flat {
+			fmt.Fprintf(&redirects, "%s go-get=1 %s 200!\n", p, path.Join(p, goGetPage))
+		}
+
+		if s.DocsRedirect {
+			fmt.Fprintf(&redirects, "%s https://pkg.go.dev/%s 302!\n", p, pkg.ImportPath)
+		}
+	}
+
+	if err := s.writeFile("_redirects", redirects.Bytes()); err != nil {
+		return err
+	}
+
+	var headers bytes.Buffer
+
+	fmt.Fprintln(&headers, "/*")
+
+	for _, h := range securityHeaders {
+		fmt.Fprintf(&headers, "  %s: %s\n", h[0], h[1])
+	}
+
+	fmt.Fprintf(&headers, "  Cache-Control: %s\n", pagesCacheControl)
+
+	return s.writeFile("_headers", headers.Bytes())
+}
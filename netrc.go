@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+type netrcLine struct {
+	machine  string
+	login    string
+	password string
+}
+
+type netrc []netrcLine
+
+// defaultNetrc returns the netrc file path the Go toolchain would use.
+func defaultNetrc() string {
+	if env := os.Getenv("NETRC"); env != "" {
+		return env
+	}
+
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	base := ".netrc"
+	if runtime.GOOS == "windows" {
+		base = "_netrc"
+	}
+
+	return filepath.Join(dir, base)
+}
+
+func readNetrc(path string) (netrc, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return parseNetrc(string(data)), nil
+}
+
+// parseNetrc follows the same rules as the Go toolchain, the default entry
+// and macros are ignored.
+func parseNetrc(data string) netrc {
+	var (
+		nrc     netrc
+		l       netrcLine
+		inMacro bool
+	)
+
+lines:
+	for _, line := range strings.Split(data, "\n") {
+		if inMacro {
+			if line == "" {
+				inMacro = false
+			}
+
+			continue
+		}
+
+		f := strings.Fields(line)
+		i := 0
+
+		for ; i < len(f)-1; i += 2 {
+			switch f[i] {
+			case "machine":
+				l = netrcLine{machine: f[i+1]}
+			case "default":
+				break lines
+			case "login":
+				l.login = f[i+1]
+			case "password":
+				l.password = f[i+1]
+			case "macdef":
+				inMacro = true
+			}
+
+			if l.machine != "" && l.login != "" && l.password != "" {
+				nrc = append(nrc, l)
+				l = netrcLine{}
+			}
+		}
+
+		if i < len(f) && f[i] == "default" {
+			break
+		}
+	}
+
+	return nrc
+}
+
+func (nrc netrc) lookup(host string) (login, password string, ok bool) {
+	for _, l := range nrc {
+		if l.machine == host {
+			return l.login, l.password, true
+		}
+	}
+
+	return "", "", false
+}
+
+func (nrc netrc) setAuth(req *http.Request) {
+	if req.Header.Get("Authorization") != "" {
+		return
+	}
+
+	if login, password, ok := nrc.lookup(req.URL.Hostname()); ok {
+		req.SetBasicAuth(login, password)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// notifiers send the notifications of the generations that change the site
+// or fail, by URL scheme:
+//
+//	https://example.com/hook                      JSON POST of the Notification.
+//	slack://hooks.slack.com/services/T/B/X        Slack incoming webhook.
+//	smtp://user@mail.example.com:587?from=vanitic@example.com&to=team@example.com&password=env:SMTP_PASSWORD
+//
+// SMTP passwords are secret sources (see readSecret).
+var notifiers = map[string]func(u *url.URL, n *Notification) error{
+	"http":  notifyWebhook,
+	"https": notifyWebhook,
+	"slack": notifySlack,
+	"smtp":  notifySMTP,
+}
+
+// Notification summarizes the changes of a generation.
+type Notification struct {
+	Time     time.Time `json:"time"`
+	Changes  []string  `json:"changes,omitempty"` // See compareSnapshots.
+	Failures []string  `json:"failures,omitempty"`
+}
+
+// Text returns the notification as plain text.
+func (n *Notification) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "vanitic: %d changes, %d failures\n", len(n.Changes), len(n.Failures))
+
+	for _, c := range n.Changes {
+		b.WriteString("\n" + c)
+	}
+
+	for _, f := range n.Failures {
+		b.WriteString("\nFAIL " + f)
+	}
+
+	return b.String() + "\n"
+}
+
+// notifyChanges takes a snapshot of the output directory and returns a
+// function sending the notification of the changes since then and the
+// failures of the generation, if there are any. Notification errors are
+// logged, they don't fail the generation.
+func (g *Generator) notifyChanges(sum *Summary, err *error) func() {
+	if len(g.opts.Notify) == 0 {
+		return func() {}
+	}
+
+	before := snapshotOrEmpty(g.opts.Output)
+
+	return func() {
+		n := &Notification{
+			Time:    time.Now().UTC(),
+			Changes: compareSnapshots(before, snapshotOrEmpty(g.opts.Output)),
+		}
+
+		for _, rs := range sum.Repos {
+			if rs.Error != "" {
+				n.Failures = append(n.Failures, rs.URL+": "+rs.Error)
+			}
+		}
+
+		if *err != nil && len(n.Failures) == 0 {
+			n.Failures = append(n.Failures, (*err).Error())
+		}
+
+		if len(n.Changes) == 0 && len(n.Failures) == 0 {
+			return
+		}
+
+		for _, raw := range g.opts.Notify {
+			u, _ := url.Parse(raw) // Validated by Options.Parse.
+
+			if err := notifiers[u.Scheme](u, n); err != nil {
+				log.Printf("notify %s://%s: %v", u.Scheme, u.Host, err)
+			}
+		}
+	}
+}
+
+func snapshotOrEmpty(dir string) *SiteSnapshot {
+	snap, err := takeSnapshot(dir)
+	if err != nil {
+		return &SiteSnapshot{Versions: true}
+	}
+
+	return snap
+}
+
+func notifyWebhook(u *url.URL, n *Notification) error {
+	return postJSON(u.String(), n)
+}
+
+func notifySlack(u *url.URL, n *Notification) error {
+	hook := *u
+	hook.Scheme = "https"
+
+	return postJSON(hook.String(), map[string]string{"text": n.Text()})
+}
+
+func postJSON(u string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	resp, err := client.Post(u, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+func notifySMTP(u *url.URL, n *Notification) error {
+	q := u.Query()
+
+	from, to := q.Get("from"), strings.Split(q.Get("to"), ",")
+
+	var auth smtp.Auth
+
+	if user := u.User.Username(); user != "" {
+		password, err := readSecret(q.Get("password"))
+		if err != nil {
+			return err
+		}
+
+		auth = smtp.PlainAuth("", user, password, u.Hostname())
+	}
+
+	host, _ := os.Hostname()
+
+	var msg bytes.Buffer
+
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: vanitic: %d changes, %d failures\r\n", len(n.Changes), len(n.Failures))
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%d.vanitic@%s>\r\n", n.Time.UnixNano(), host)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(n.Text(), "\n", "\r\n"))
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr += ":587"
+	}
+
+	return smtp.SendMail(addr, auth, from, to, msg.Bytes())
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing/fstest"
+	"time"
+)
+
+// Output is where generated files are written. Names are slash separated
+// paths relative to the output root.
+type Output interface {
+	WriteFile(name string, data []byte) error
+}
+
+// DirOutput writes files into a directory of the OS filesystem.
+type DirOutput struct {
+	Dir string
+
+	// Permissions of the created directories and files. If zero, 0755 and
+	// 0644 are used and masked by the umask, other modes are set as they
+	// are.
+	DirMode  fs.FileMode
+	FileMode fs.FileMode
+
+	// Maps the names of the files, see layouts. If nil, names are kept as
+	// they are.
+	Layout func(name string) string
+
+	// Paths are given to external commands with forward slashes, see
+	// cmdRunner.cmdPath.
+	SlashPaths bool
+
+	manifest *manifest // Records the written files, if not nil.
+}
+
+func (d DirOutput) WriteFile(name string, data []byte) error {
+	if d.Layout != nil {
+		name = d.Layout(name)
+	}
+
+	dst := filepath.Join(d.Dir, filepath.FromSlash(name))
+
+	// The os package only handles long paths on Windows if they are
+	// absolute.
+	if runtime.GOOS == "windows" && !d.SlashPaths {
+		abs, err := filepath.Abs(dst)
+		if err != nil {
+			return err
+		}
+
+		dst = abs
+	}
+
+	if err := d.mkdirAll(filepath.Dir(dst)); err != nil {
+		return err
+	}
+
+	if d.manifest != nil {
+		d.manifest.add(name)
+	}
+
+	if err := os.WriteFile(dst, data, cmp.Or(d.FileMode, 0644)); err != nil {
+		return err
+	}
+
+	if d.FileMode != 0 {
+		return os.Chmod(dst, d.FileMode)
+	}
+
+	return nil
+}
+
+func (d DirOutput) ReadFile(name string) ([]byte, error) {
+	if d.Layout != nil {
+		name = d.Layout(name)
+	}
+
+	return os.ReadFile(filepath.Join(d.Dir, filepath.FromSlash(name)))
+}
+
+// mkdirAll creates the directory and its missing parents.
+func (d DirOutput) mkdirAll(dir string) error {
+	if d.DirMode == 0 {
+		return os.MkdirAll(dir, 0755)
+	}
+
+	var missing []string
+
+	for p := dir; ; p = filepath.Dir(p) {
+		if _, err := os.Stat(p); err == nil || p == filepath.Dir(p) {
+			break
+		}
+
+		missing = append(missing, p)
+	}
+
+	if err := os.MkdirAll(dir, d.DirMode); err != nil {
+		return err
+	}
+
+	for _, p := range missing {
+		if err := os.Chmod(p, d.DirMode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MemFS keeps generated files in memory, it is safe for concurrent use.
+// Directories are implied by file names, like in a zip archive.
+type MemFS struct {
+	mu    sync.RWMutex
+	files map[string]*fstest.MapFile
+}
+
+func NewMemFS() *MemFS {
+	return &MemFS{files: make(map[string]*fstest.MapFile)}
+}
+
+func (m *MemFS) WriteFile(name string, data []byte) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.files[name] = &fstest.MapFile{
+		Data:    bytes.Clone(data),
+		Mode:    0644,
+		ModTime: time.Now(),
+	}
+
+	return nil
+}
+
+// Open opens the named file from a snapshot of the current files.
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mu.RLock()
+	snapshot := make(fstest.MapFS, len(m.files))
+	for name, f := range m.files {
+		snapshot[name] = f
+	}
+	m.mu.RUnlock()
+
+	return snapshot.Open(name)
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	f, ok := m.files[path.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return bytes.Clone(f.Data), nil
+}
+
+func writeFile(out Output, name string, data []byte) error {
+	return out.WriteFile(name, data)
+}
+
+// executor is an html/template or text/template template.
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+func writeTemplate(out Output, name string, tmpl executor, data any) error {
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	return out.WriteFile(name, buf.Bytes())
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	neturl "net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Path styles of the paths given to external commands, see cmdPath.
+var pathStyles = []string{"native", "slash"}
+
+// cmdPath returns the OS path p as given to external commands like git and
+// rsync. The slash style uses forward slashes, for Windows builds of those
+// tools (MSYS, Cygwin) that don't understand backslashes.
+func (c cmdRunner) cmdPath(p string) string {
+	if c.slashPaths {
+		return filepath.ToSlash(p)
+	}
+
+	return p
+}
+
+// repoName returns the name of the repository, the last element of its
+// path. Local repositories may use backslashes on Windows.
+func repoName(url string) string {
+	url = strings.TrimRight(url, `/\`)
+	if _, _, p, ok := scpURL(url); ok {
+		url = p
+	}
+
+	return url[strings.LastIndexAny(url, `/\`)+1:]
+}
+
+// repoPath returns the slash path of the source code directory of the
+// repository, and of its bare mirror with a .git suffix: the host and path
+// of its URL, or local and the path for local repositories, so repositories
+// of different owners and hosts with the same name don't share them.
+func repoPath(url string) string {
+	key := repoKey(url)
+	host, p := "local", key
+
+	if _, h, sp, ok := scpURL(key); ok {
+		host, p = h, sp
+	} else if u, err := neturl.Parse(key); err == nil && u.Host != "" && u.Scheme != "file" {
+		host, p = u.Host, u.Path
+	} else if err == nil && u.Scheme == "file" {
+		p = u.Path
+	}
+
+	// Ports and Windows drive letters aren't valid in Windows file names.
+	p = strings.NewReplacer(`\`, "/", ":", "_").Replace(p)
+
+	return strings.ReplaceAll(host, ":", "_") + path.Clean("/"+p)
+}
+
+// repoDir returns the source code directory of the repository.
+func (g *Generator) repoDir(r *Repo) string {
+	return filepath.Join(g.opts.Source, filepath.FromSlash(repoPath(r.URL)))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+)
+
+// pkgDataFiles write the metadata of a package into index.<name> files
+// next to its pages, for scripting against the site.
+var pkgDataFiles = map[string]func(pkg Package) ([]byte, error){
+	"json": packageJSON,
+	"txt":  packageText,
+}
+
+// PackageData is the metadata of a package in its data files.
+type PackageData struct {
+	ImportPath  string            `json:"import_path"`
+	Module      string            `json:"module"`
+	VCS         string            `json:"vcs"`
+	Source      string            `json:"source"`
+	Description string            `json:"description,omitempty"`
+	URL         string            `json:"url"`
+	Version     string            `json:"version,omitempty"`
+	VersionTime *time.Time        `json:"version_time,omitempty"`
+	Go          string            `json:"go,omitempty"`
+	Branch      string            `json:"branch,omitempty"`
+	FirstSeen   *time.Time        `json:"first_seen,omitempty"` // With a state database.
+	Refreshed   *time.Time        `json:"refreshed,omitempty"`
+	Stale       bool              `json:"stale,omitempty"`
+	Meta        map[string]string `json:"meta,omitempty"`
+	Packages    []string          `json:"packages,omitempty"` // Only for modules.
+	SBOM        string            `json:"sbom,omitempty"`     // Only for modules, with -sbom.
+}
+
+func packageData(pkg Package) PackageData {
+	d := PackageData{
+		ImportPath:  pkg.ImportPath,
+		Module:      pkg.Module,
+		VCS:         "git",
+		Source:      pkg.Source,
+		Description: pkg.Description,
+		URL:         pkg.URL(),
+	}
+
+	if info := pkg.Info; info != nil {
+		d.Version = info.Latest
+		d.Branch = info.Branch
+		d.Meta = info.Meta
+
+		if info.Latest != "" {
+			d.VersionTime = &info.LatestTime
+		}
+
+		if !info.FirstSeen.IsZero() {
+			d.FirstSeen = &info.FirstSeen
+		}
+
+		if !info.Refreshed.IsZero() {
+			d.Refreshed = &info.Refreshed
+		}
+
+		d.Stale = info.Stale
+
+		if info.GoMod != nil {
+			d.Go = info.GoMod.Go
+		}
+
+		if pkg.IsModule() {
+			for _, ref := range info.Packages {
+				d.Packages = append(d.Packages, ref.ImportPath)
+			}
+
+			d.SBOM = info.SBOM
+		}
+	}
+
+	return d
+}
+
+func packageJSON(pkg Package) ([]byte, error) {
+	data, err := json.MarshalIndent(packageData(pkg), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// packageText returns the metadata as "key: value" lines, packages are
+// repeated package lines.
+func packageText(pkg Package) ([]byte, error) {
+	d := packageData(pkg)
+
+	var b bytes.Buffer
+
+	field := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", key, value)
+		}
+	}
+
+	field("import_path", d.ImportPath)
+	field("module", d.Module)
+	field("vcs", d.VCS)
+	field("source", d.Source)
+	field("description", d.Description)
+	field("url", d.URL)
+	field("version", d.Version)
+
+	if d.VersionTime != nil {
+		field("version_time", d.VersionTime.UTC().Format(time.RFC3339))
+	}
+
+	field("go", d.Go)
+	field("branch", d.Branch)
+
+	if d.FirstSeen != nil {
+		field("first_seen", d.FirstSeen.UTC().Format(time.RFC3339))
+	}
+
+	if d.Refreshed != nil {
+		field("refreshed", d.Refreshed.UTC().Format(time.RFC3339))
+	}
+
+	if d.Stale {
+		field("stale", "true")
+	}
+
+	for _, k := range sortedKeys(d.Meta) {
+		field("meta."+k, d.Meta[k])
+	}
+
+	for _, p := range d.Packages {
+		field("package", p)
+	}
+
+	field("sbom", d.SBOM)
+
+	return b.Bytes(), nil
+}
+
+// writePackageData writes the given data files of the package.
+func writePackageData(out Output, pkg Package, files []string) error {
+	for _, name := range files {
+		data, err := pkgDataFiles[name](pkg)
+		if err != nil {
+			return err
+		}
+
+		if err := out.WriteFile(path.Join(pkg.ImportPath, "index."+name), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// policies are the publishing requirements of the modules, checked before
+// writing their pages. They return the reason the module doesn't meet the
+// requirement, or an empty string.
+var policies = map[string]func(m policyModule) string{
+	// The module or the repository has a license file.
+	"license": func(m policyModule) string {
+		if _, ok := findLicense(m.mod); !ok {
+			return "no license file"
+		}
+
+		return ""
+	},
+
+	"go-mod": func(m policyModule) string {
+		if m.info.GoMod == nil {
+			return "no go.mod file"
+		}
+
+		return ""
+	},
+
+	// The module path is a vanity import path, not the path of the
+	// repository in its forge (like github.com/org/x), and it is under the
+	// -host host if any.
+	"host": func(m policyModule) string {
+		host, _, _ := strings.Cut(m.mod.Path, "/")
+
+		if fp, ok := forgePath(m.mod.Repo.URL); ok && strings.HasPrefix(fp, host+"/") {
+			return fmt.Sprintf("module path %s uses the repository host", m.mod.Path)
+		}
+
+		if m.host != "" && host != m.host {
+			return fmt.Sprintf("module path %s isn't under %s", m.mod.Path, m.host)
+		}
+
+		return ""
+	},
+
+	// The module has a tagged version.
+	"tag": func(m policyModule) string {
+		if m.info.Latest == "" {
+			return "no tagged versions"
+		}
+
+		return ""
+	},
+
+	// Replace directives of released modules are ignored by their users,
+	// local ones break the builds of the module outside of the repository.
+	"local-replace": func(m policyModule) string {
+		if m.info.GoMod == nil {
+			return ""
+		}
+
+		var local []string
+
+		for _, r := range m.info.GoMod.Replace {
+			if r.Local() {
+				local = append(local, r.Old+" => "+r.New)
+			}
+		}
+
+		if local != nil {
+			return "local replace directives: " + strings.Join(local, ", ")
+		}
+
+		return ""
+	},
+}
+
+// Policy modes, violations of warn policies are only reported.
+const (
+	policyOff     = "off"
+	policyWarn    = "warn"
+	policyEnforce = "enforce"
+)
+
+type policyModule struct {
+	mod  *Module
+	info *ModuleInfo
+	host string // Options.Host.
+}
+
+// PolicyViolation is a policy a module doesn't meet.
+type PolicyViolation struct {
+	Policy string `json:"policy"`
+	Mode   string `json:"mode"`
+	Reason string `json:"reason"`
+}
+
+// parsePolicy parses a policy directive argument or attribute value, like
+// license or tag=warn, enforced by default.
+func parsePolicy(s string) (name, mode string, err error) {
+	name, mode, ok := strings.Cut(s, "=")
+	if !ok {
+		mode = policyEnforce
+	}
+
+	if _, ok := policies[name]; !ok {
+		return "", "", fmt.Errorf("unknown policy %q", name)
+	}
+
+	if mode != policyOff && mode != policyWarn && mode != policyEnforce {
+		return "", "", fmt.Errorf("invalid policy mode %q, expected off, warn or enforce", mode)
+	}
+
+	return name, mode, nil
+}
+
+// checkPolicies returns the violations of the configured policies by the
+// module, repository policies override the configuration ones. Warnings
+// are logged, enforced violations are an error unless only reporting (see
+// policyCmd).
+func (g *Generator) checkPolicies(cfg *Config, r *Repo, mod *Module, info *ModuleInfo) ([]PolicyViolation, error) {
+	modes := make(map[string]string)
+	for name, mode := range cfg.Policies {
+		modes[name] = mode
+	}
+
+	for name, mode := range r.Policies {
+		modes[name] = mode
+	}
+
+	var (
+		violations []PolicyViolation
+		enforced   []string
+	)
+
+	for _, name := range sortedKeys(modes) {
+		mode := modes[name]
+		if mode == policyOff {
+			continue
+		}
+
+		reason := policies[name](policyModule{mod: mod, info: info, host: g.opts.Host})
+		if reason == "" {
+			continue
+		}
+
+		violations = append(violations, PolicyViolation{Policy: name, Mode: mode, Reason: reason})
+
+		if mode == policyEnforce {
+			enforced = append(enforced, name+": "+reason)
+		} else if !g.policyReport {
+			log.Printf("policy: %s: %s: %s", mod.Path, name, reason)
+		}
+	}
+
+	if enforced != nil && !g.policyReport {
+		return violations, fmt.Errorf("policy: %s: %s", mod.Path, strings.Join(enforced, "; "))
+	}
+
+	return violations, nil
+}
+
+// policyCmd reports the policy violations of every module, without writing
+// the output directory. It fails if any enforced policy is violated.
+func policyCmd(args []string) error {
+	opts := DefaultOptions()
+	fset := opts.FlagSet("vanitic policy")
+
+	asJSON := fset.Bool(
+		"json", false,
+		"Write the report as JSON, the violations by module path.",
+	)
+
+	if err := opts.Parse(fset, args); err != nil {
+		return err
+	}
+
+	g, err := NewGenerator(opts)
+	if err != nil {
+		return err
+	}
+
+	g.policyReport = true
+
+	sum, err := g.GenerateTo(NewMemFS())
+	if err != nil {
+		return err
+	}
+
+	report := make(map[string][]PolicyViolation)
+	enforced := 0
+
+	for _, rs := range sum.Repos {
+		if rs.Module == "" {
+			continue
+		}
+
+		report[rs.Module] = rs.Policy
+
+		for _, v := range rs.Policy {
+			if v.Mode == policyEnforce {
+				enforced++
+			}
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, m := range sortedKeys(report) {
+			if report[m] == nil {
+				fmt.Printf("%s: ok\n", m)
+			}
+
+			for _, v := range report[m] {
+				fmt.Printf("%s: %s (%s): %s\n", m, v.Policy, v.Mode, v.Reason)
+			}
+		}
+	}
+
+	if enforced > 0 {
+		return fmt.Errorf("policy: %d violations of enforced policies", enforced)
+	}
+
+	return nil
+}
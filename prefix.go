@@ -0,0 +1,105 @@
+package main
+
+import (
+	"html/template"
+	"path"
+	"sort"
+	"strings"
+)
+
+// prefixes returns the paths between the site host and its packages that
+// aren't packages (like go.example.com/x for go.example.com/x/foo), sorted.
+func (s *Site) prefixes() []string {
+	pkgs := make(map[string]bool)
+	for _, pkg := range s.Packages {
+		pkgs[pkg.ImportPath] = true
+	}
+
+	seen := make(map[string]bool)
+
+	var prefixes []string
+
+	for _, pkg := range s.Packages {
+		for p := path.Dir(pkg.ImportPath); strings.Contains(p, "/"); p = path.Dir(p) {
+			if !pkgs[p] && !seen[p] {
+				seen[p] = true
+				prefixes = append(prefixes, p)
+			}
+		}
+	}
+
+	sort.Strings(prefixes)
+
+	return prefixes
+}
+
+// writePrefixPages writes pages for every prefix of the site, so the Go
+// toolchain gets a response while walking the import path and humans find
+// what is there. Prefixes inside a module get its package pages without
+// documentation, others get a listing of the packages under them without
+// go-import meta tag, since no repository contains them.
+func writePrefixPages(s *Site, pages *pageTemplates) error {
+prefixes:
+	for _, p := range s.prefixes() {
+		for _, pkg := range s.Packages {
+			if pkg.IsModule() && strings.HasPrefix(p, pkg.Module+"/") {
+				pkg.ImportPath = p
+				pkg.Description, pkg.RichDescription = "", ""
+				pkg.Doc = nil
+				pkg.Files = nil
+				pkg.Breadcrumbs = breadcrumbs(pkg.Module, p, nil, s.Flat)
+
+				if err := pages.write(s.out, pkg); err != nil {
+					return err
+				}
+
+				continue prefixes
+			}
+		}
+
+		var pkgs []Package
+
+		for _, pkg := range s.Packages {
+			if strings.HasPrefix(pkg.ImportPath, p+"/") {
+				pkgs = append(pkgs, pkg)
+			}
+		}
+
+		err := writeListing(p, pkgs, s.PageSize, s.Flat, func(name string, pkgs []Package, pager Pager) error {
+			data := struct {
+				*Site
+				Prefix   string
+				Packages []Package
+				Pager    Pager
+			}{s, displayPath(p), pkgs, pager}
+
+			return writeTemplate(s.out, name, prefixTmpl, data)
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var prefixTmpl = template.Must(template.Must(template.New("prefix").Parse(pagerTmpl)).Parse(`<!DOCTYPE html>
+<html lang="{{ .Locale.HTMLLang }}">
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="viewport" content="width=device-width, initial-scale=1"/>
+  <link rel="stylesheet" href="/style.css"/>
+  <title>{{ .Prefix }}</title>
+</head>
+<body>
+  <h1>{{ .Prefix }}</h1>
+  <ul>
+  {{- range .Packages }}
+    <li><a href="{{ $.Path . }}">{{ .DisplayPath }}</a>{{ if .Description }} - {{ .DescriptionHTML }}{{ end }}</li>
+  {{- end }}
+  </ul>
+  {{- template "pager" . }}
+</body>
+</html>
+`))
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"time"
+)
+
+// Profiling writes CPU and memory profiles and execution traces of a
+// command, for "go tool pprof" and "go tool trace".
+type Profiling struct {
+	CPUProfile string
+	MemProfile string
+	Trace      string
+}
+
+func (p *Profiling) AddFlags(fset *flag.FlagSet) {
+	fset.StringVar(
+		&p.CPUProfile, "cpuprofile", p.CPUProfile,
+		"Write a CPU profile to the given file.",
+	)
+
+	fset.StringVar(
+		&p.MemProfile, "memprofile", p.MemProfile,
+		"Write a memory profile to the given file when done.",
+	)
+
+	fset.StringVar(
+		&p.Trace, "trace", p.Trace,
+		"Write an execution trace to the given file.",
+	)
+}
+
+// Start starts the CPU profile and the trace, the returned function stops
+// them and writes the memory profile.
+func (p *Profiling) Start() (stop func() error, err error) {
+	var stops []func() error
+
+	stop = func() error {
+		for i := len(stops) - 1; i >= 0; i-- {
+			if err := stops[i](); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if p.CPUProfile != "" {
+		f, err := os.Create(p.CPUProfile)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		stops = append(stops, func() error {
+			pprof.StopCPUProfile()
+			return f.Close()
+		})
+	}
+
+	if p.Trace != "" {
+		f, err := os.Create(p.Trace)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stop()
+
+			return nil, err
+		}
+
+		stops = append(stops, func() error {
+			trace.Stop()
+			return f.Close()
+		})
+	}
+
+	if p.MemProfile != "" {
+		stops = append([]func() error{func() error {
+			f, err := os.Create(p.MemProfile)
+			if err != nil {
+				return err
+			}
+
+			runtime.GC()
+
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				f.Close()
+				return err
+			}
+
+			return f.Close()
+		}}, stops...)
+	}
+
+	return stop, nil
+}
+
+// logPhases logs the per-phase timing breakdown of the summary.
+func logPhases(sum *Summary) {
+	var b strings.Builder
+
+	for _, phase := range []string{"clone", "list", "render", "write"} {
+		fmt.Fprintf(&b, " %s=%s", phase, time.Duration(sum.Phases[phase]).Round(time.Millisecond))
+	}
+
+	log.Printf("generated in %s:%s", time.Duration(sum.Duration).Round(time.Millisecond), b.String())
+
+	for _, rs := range sum.Repos {
+		b.Reset()
+
+		for _, phase := range []string{"clone", "list", "render"} {
+			fmt.Fprintf(&b, " %s=%s", phase, time.Duration(rs.Phases[phase]).Round(time.Millisecond))
+		}
+
+		log.Printf("  %s:%s", rs.URL, b.String())
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyHandler implements the module proxy protocol for the generated
+// modules, serving them from the local checkouts:
+//
+//	<module>/@v/list
+//	<module>/@v/<version>.info
+//	<module>/@v/<version>.mod
+//	<module>/@v/<version>.zip
+//	<module>/@latest
+//
+// Paths are relative to the handler prefix, module paths and versions are
+// escaped as the protocol requires. Versions not available locally are
+// fetched from the upstream proxy, if any.
+type proxyHandler struct {
+	g     *Generator
+	cache *proxyCache
+
+	zips zipCache
+}
+
+// zipCacheSize bounds the size of the module zips kept in memory.
+const zipCacheSize = 256 << 20
+
+// zipCache keeps the module zips built from the checkouts, by module and
+// version. The oldest ones are dropped when they exceed zipCacheSize.
+type zipCache struct {
+	mu    sync.Mutex
+	zips  map[string][]byte
+	order []string
+	size  int
+}
+
+func (c *zipCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, ok := c.zips[key]
+
+	return data, ok
+}
+
+func (c *zipCache) add(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.zips[key]; ok || len(data) > zipCacheSize {
+		return
+	}
+
+	if c.zips == nil {
+		c.zips = make(map[string][]byte)
+	}
+
+	for c.size+len(data) > zipCacheSize {
+		c.size -= len(c.zips[c.order[0]])
+		delete(c.zips, c.order[0])
+		c.order = c.order[1:]
+	}
+
+	c.zips[key] = data
+	c.order = append(c.order, key)
+	c.size += len(data)
+}
+
+func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, "/")
+
+	var escMod, file string
+
+	if mod, ok := strings.CutSuffix(p, "/@latest"); ok {
+		escMod, file = mod, "@latest"
+	} else if mod, f, ok := strings.Cut(p, "/@v/"); ok {
+		escMod, file = mod, f
+	} else {
+		http.NotFound(w, r)
+		return
+	}
+
+	modPath, ok := unescapeModulePath(escMod)
+	if !ok {
+		http.Error(w, "invalid module path", http.StatusBadRequest)
+		return
+	}
+
+	mod := h.g.Module(modPath)
+	if mod == nil {
+		http.Error(w, "unknown module", http.StatusNotFound)
+		return
+	}
+
+	// Generations update the checkout holding its lock, it is released
+	// before waiting for the upstream proxy.
+	lock, err := lockSource(mod.RepoDir)
+	if err != nil {
+		http.Error(w, "can't lock the repository", http.StatusInternalServerError)
+		return
+	}
+
+	unlock := sync.OnceFunc(lock)
+	defer unlock()
+
+	switch {
+	case file == "list":
+		versions, err := mod.Versions()
+		if err != nil {
+			http.Error(w, "can't list versions", http.StatusInternalServerError)
+			return
+		}
+
+		versions = h.withUpstream(p, versions)
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=60")
+
+		for _, v := range versions {
+			w.Write([]byte(v + "\n"))
+		}
+	case file == "@latest":
+		versions, err := mod.Versions()
+		if err != nil {
+			http.Error(w, "can't list versions", http.StatusInternalServerError)
+			return
+		}
+
+		latest := latestSemver(versions)
+		if latest == "" && h.cache != nil {
+			unlock()
+			h.serveUpstream(w, r, p, false)
+			return
+		}
+
+		if latest == "" {
+			http.Error(w, "no versions", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		h.serveInfo(w, mod, latest)
+	default:
+		i := strings.LastIndexByte(file, '.')
+		if i < 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		version, ok := unescapeModulePath(file[:i])
+		if !ok {
+			http.Error(w, "invalid version", http.StatusBadRequest)
+			return
+		}
+
+		if !h.known(mod, version) {
+			if h.cache != nil {
+				unlock()
+				h.serveUpstream(w, r, p, true)
+				return
+			}
+
+			http.Error(w, "unknown version", http.StatusNotFound)
+
+			return
+		}
+
+		// Tagged versions are immutable in the module ecosystem.
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+		switch file[i:] {
+		case ".info":
+			h.serveInfo(w, mod, version)
+		case ".mod":
+			data, err := mod.GoMod(version)
+			if err != nil {
+				// Modules without go.mod get a synthesized one.
+				data = []byte("module " + mod.Path + "\n")
+			}
+
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write(data)
+		case ".zip":
+			key := mod.Path + "@" + version
+
+			data, ok := h.zips.get(key)
+			if !ok {
+				var buf bytes.Buffer
+
+				if err := mod.WriteZip(&buf, version); err != nil {
+					http.Error(w, "can't create module zip", http.StatusInternalServerError)
+					return
+				}
+
+				data = buf.Bytes()
+				h.zips.add(key, data)
+			}
+
+			unlock()
+
+			w.Header().Set("Content-Type", "application/zip")
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// withUpstream adds the upstream versions to the local ones.
+func (h *proxyHandler) withUpstream(listPath string, versions []string) []string {
+	if h.cache == nil {
+		return versions
+	}
+
+	data, err := h.cache.fetch(listPath)
+	if err != nil {
+		return versions
+	}
+
+	for _, v := range strings.Fields(string(data)) {
+		if !slices.Contains(versions, v) {
+			versions = append(versions, v)
+		}
+	}
+
+	sortSemver(versions)
+
+	return versions
+}
+
+// serveUpstream serves a file from the upstream proxy, immutable files are
+// cached.
+func (h *proxyHandler) serveUpstream(w http.ResponseWriter, r *http.Request, p string, immutable bool) {
+	var (
+		data []byte
+		err  error
+	)
+
+	if immutable {
+		data, err = h.cache.get(p)
+	} else {
+		data, err = h.cache.fetch(p)
+	}
+
+	switch {
+	case errors.Is(err, errUpstreamNotFound):
+		http.Error(w, "unknown version", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "upstream proxy error", http.StatusBadGateway)
+		return
+	}
+
+	switch path.Ext(p) {
+	case ".zip":
+		w.Header().Set("Content-Type", "application/zip")
+	case ".mod":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	default:
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	if immutable {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=60")
+	}
+
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+func (h *proxyHandler) known(mod *Module, version string) bool {
+	versions, err := mod.Versions()
+	if err != nil {
+		return false
+	}
+
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *proxyHandler) serveInfo(w http.ResponseWriter, mod *Module, version string) {
+	t, err := mod.VersionTime(version)
+	if err != nil {
+		http.Error(w, "can't get version time", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Version string
+		Time    time.Time
+	}{version, t})
+}
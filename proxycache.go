@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyCache fetches module files from an upstream module proxy, caching
+// immutable files (.info, .mod and .zip) in a directory. When the cache
+// exceeds its size, the least recently used files are removed.
+type proxyCache struct {
+	upstream string
+	dir      string
+	maxSize  int64
+
+	mu sync.Mutex // Serializes cache writes and evictions.
+}
+
+var errUpstreamNotFound = errors.New("not found in upstream proxy")
+
+// maxUpstreamFile bounds the size of files fetched from the upstream proxy,
+// module zips can't be larger than 500 MiB.
+const maxUpstreamFile = 500 << 20
+
+// fetch returns the upstream file at the given escaped path, relative to
+// the proxy root.
+func (c *proxyCache) fetch(p string) ([]byte, error) {
+	res, err := http.Get(c.upstream + "/" + p)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, errUpstreamNotFound
+	default:
+		return nil, fmt.Errorf("upstream proxy: GET %s: %s", p, res.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, maxUpstreamFile))
+}
+
+// get returns the file at the given escaped path from the cache, fetching
+// it from the upstream proxy if it isn't cached.
+func (c *proxyCache) get(p string) ([]byte, error) {
+	name := filepath.Join(c.dir, filepath.FromSlash(p))
+
+	if data, err := os.ReadFile(name); err == nil {
+		now := time.Now()
+		os.Chtimes(name, now, now)
+
+		return data, nil
+	}
+
+	data, err := c.fetch(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.store(name, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (c *proxyCache) store(name string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return c.evict()
+}
+
+// evict removes the least recently used files until the cache fits its
+// size.
+func (c *proxyCache) evict() error {
+	type entry struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+
+	var (
+		entries []entry
+		total   int64
+	)
+
+	err := filepath.WalkDir(c.dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return err
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, entry{name, fi.Size(), fi.ModTime()})
+		total += fi.Size()
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	for _, e := range entries {
+		if total <= c.maxSize {
+			break
+		}
+
+		if err := os.Remove(e.name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		total -= e.size
+	}
+
+	return nil
+}
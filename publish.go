@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// defaultPublishMessage is the commit message template of PublishGit, the
+// template data is the Summary of the generation.
+const defaultPublishMessage = `Update vanity pages
+{{- range .Repos }}{{ if .Module }}
+
+{{ .Module }}{{ with .Version }} {{ . }}{{ end }}{{ end }}{{ end }}
+`
+
+// PublishGit commits the output directory into the publish directory of the
+// publish branch of the given repository and pushes it. Unlike
+// PublishGHPages, the branch history is kept and commits are only made when
+// the files change. The branch is created if it doesn't exist.
+func (g *Generator) PublishGit(url string, sum *Summary) error {
+	var msg bytes.Buffer
+
+	tmpl, err := template.New("message").Parse(g.opts.PublishMessage)
+	if err != nil {
+		return err
+	}
+
+	if err := tmpl.Execute(&msg, sum); err != nil {
+		return fmt.Errorf("publish: commit message: %w", err)
+	}
+
+	repo := &Repo{URL: url}
+	if repos := g.ReposOf(url); repos != nil {
+		repo = repos[0] // Modules of a repository share its credentials.
+	}
+
+	env, err := gitEnv(repo, g.state.Load().netrc)
+	if err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	workTree, err := os.MkdirTemp("", "vanitic-publish-")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(workTree)
+
+	branch := g.opts.PublishBranch
+
+	git := func(args ...string) error {
+		if err := g.cmd.runCmd(workTree, env, gitCmd(env != nil, args...)...); err != nil {
+			return fmt.Errorf("publish: git %s: %w", args[0], err)
+		}
+
+		return nil
+	}
+
+	// ls-remote exits with 2 when the branch doesn't exist.
+	if err := g.cmd.runCmd(workTree, env, gitCmd(env != nil, "ls-remote", "-q", "--exit-code", "--heads", url, branch)...); err == nil {
+		if err := git("clone", "-q", "--depth", "1", "--single-branch", "--branch", branch, url, "."); err != nil {
+			return err
+		}
+	} else if err := git("init", "-q"); err != nil {
+		return err
+	} else if err := git("symbolic-ref", "HEAD", "refs/heads/"+branch); err != nil {
+		return err
+	}
+
+	dst := filepath.Join(workTree, filepath.FromSlash(g.opts.PublishDir))
+	if err := replaceTree(dst, g.opts.Output, g.opts.PublishDir == ""); err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	if err := git("add", "-A"); err != nil {
+		return err
+	}
+
+	if err := g.cmd.runCmd(workTree, nil, "git", "diff", "--cached", "--quiet"); err == nil {
+		log.Printf("publish: %s %s is up to date", url, branch)
+		return nil
+	}
+
+	commit := []string{"commit", "-q", "-m", msg.String()}
+	if _, err := g.cmd.runCmdOutput(workTree, env, "git", "config", "user.email"); err != nil {
+		commit = append([]string{"-c", "user.name=vanitic", "-c", "user.email=vanitic@localhost"}, commit...)
+	}
+
+	if err := git(commit...); err != nil {
+		return err
+	}
+
+	return git("push", "-q", url, "HEAD:refs/heads/"+branch)
+}
+
+// replaceTree replaces the files of dst with the files of src, but the
+// generation manifest. The .git directory of dst is kept if it is the
+// repository root.
+func replaceTree(dst, src string, root bool) error {
+	entries, err := os.ReadDir(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, e := range entries {
+		if root && e.Name() == ".git" {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(dst, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	return filepath.WalkDir(src, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, name)
+		if err != nil || rel == manifestFile {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(target, data, 0644)
+	})
+}
+
+// validBranch reports whether name is a valid branch name for git, without
+// the rarer rules git check-ref-format enforces.
+func validBranch(name string) bool {
+	return name != "" && name[0] != '-' && !strings.Contains(name, "..") &&
+		!strings.ContainsAny(name, " ~^:?*[\\") && !strings.HasSuffix(name, "/") &&
+		!strings.HasSuffix(name, ".lock")
+}
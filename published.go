@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// unpublishedVersions returns the versions of the module unknown to the
+// public module proxy, like tags pushed but never fetched with the go
+// command, or rejected ones. Versions are asked with the
+// Disable-Module-Fetch header, so the proxy doesn't fetch them, neither
+// does the checksum database, since it only knows the versions the proxy
+// has fetched but fetches the missing ones on lookups.
+func unpublishedVersions(proxy, modPath string, versions []string) ([]string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	base := strings.TrimSuffix(proxy, "/") + "/" + escapeModulePath(modPath) + "/@v/"
+
+	var missing []string
+
+	for _, v := range versions {
+		req, err := http.NewRequest(http.MethodGet, base+escapeModulePath(v)+".info", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Disable-Module-Fetch", "true")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound, http.StatusGone:
+			missing = append(missing, v)
+		default:
+			return nil, fmt.Errorf("%s@%s: %s", modPath, v, resp.Status)
+		}
+	}
+
+	return missing, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// releaseCmd tags the next version of the module of a configured
+// repository, pushes the tag and regenerates its pages:
+//
+//	vanitic release [flags] <repository>
+//
+// Tags of modules in subdirectories have their prefix (see
+// Module.TagPrefix), and major versions must match the /vN suffix of the
+// module path, so releasing v2 of a module without one is an error. Site
+// files (see Site) are left for the next generation of every repository.
+func releaseCmd(args []string) error {
+	opts := DefaultOptions()
+	fset := opts.FlagSet("vanitic release")
+
+	bump := fset.String(
+		"bump", "patch",
+		"Part of the version to increment (major, minor or patch).",
+	)
+
+	subdir := fset.String(
+		"subdir", "",
+		"Module subdirectory, for repositories configured with several modules.",
+	)
+
+	message := fset.String(
+		"m", "",
+		"Message of an annotated tag (default a lightweight tag).",
+	)
+
+	dryRun := fset.Bool(
+		"n", false,
+		"Only print the tag that would be pushed.",
+	)
+
+	if err := opts.Parse(fset, args); err != nil {
+		return err
+	}
+
+	if fset.NArg() != 1 {
+		return errors.New("release: expected a single repository")
+	}
+
+	g, err := NewGenerator(opts)
+	if err != nil {
+		return err
+	}
+
+	repos, err := selectRepos(g.Repos(), []string{fset.Arg(0)}, nil)
+	if err != nil {
+		return fmt.Errorf("release: %w", err)
+	}
+
+	var r *Repo
+
+	for _, c := range repos {
+		if c.Subdir == *subdir {
+			r = c
+		}
+	}
+
+	if r == nil {
+		return fmt.Errorf("release: %s has no module in %q, use -subdir", fset.Arg(0), *subdir)
+	}
+
+	st := g.state.Load()
+
+	// The checkout is updated and the module loaded, without writing its
+	// pages yet.
+	g.mu.Lock()
+	rs, err := g.genRepo(st, NewMemFS(), r)
+	g.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	mod := g.Module(rs.Module)
+	if mod == nil {
+		return fmt.Errorf("release: %s has no go.mod", r.URL)
+	}
+
+	// Fetches of other runs would replace the tags while releasing, the
+	// lock is released before generating the pages, which takes it too.
+	lock, err := lockSource(mod.RepoDir)
+	if err != nil {
+		return err
+	}
+
+	unlock := sync.OnceFunc(lock)
+	defer unlock()
+
+	versions, err := mod.Versions()
+	if err != nil {
+		return err
+	}
+
+	latest := latestSemver(versions)
+
+	next, err := nextVersion(latest, *bump, pathMajor(mod.Path))
+	if err != nil {
+		return fmt.Errorf("release: %s: %w", mod.Path, err)
+	}
+
+	if latest != "" {
+		changes, err := g.cmd.runCmdOutput(mod.RepoDir, nil, "git", "rev-list", "--count", mod.TagPrefix()+latest+"..HEAD", "--", cmp.Or(mod.Subdir, "."))
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(string(changes)) == "0" {
+			return fmt.Errorf("release: %s has no changes since %s", mod.Path, latest)
+		}
+	}
+
+	tag := mod.TagPrefix() + next
+
+	if *dryRun {
+		fmt.Println(tag)
+		return nil
+	}
+
+	tagArgs := []string{"git", "tag", tag, "HEAD"}
+	if *message != "" {
+		tagArgs = []string{"git", "tag", "-a", "-m", *message, tag, "HEAD"}
+	}
+
+	if err := g.cmd.runCmd(mod.RepoDir, nil, tagArgs...); err != nil {
+		return err
+	}
+
+	env, err := gitEnv(r, st.netrc)
+	if err != nil {
+		return err
+	}
+
+	if err := g.cmd.runCmd(mod.RepoDir, env, gitCmd(env != nil, "push", "-q", "origin", "refs/tags/"+tag)...); err != nil {
+		// The local tag would be released by the next fetch otherwise.
+		g.cmd.runCmd(mod.RepoDir, nil, "git", "tag", "-d", tag)
+		return err
+	}
+
+	log.Printf("release: pushed %s of %s", tag, mod.Path)
+
+	unlock()
+
+	// Only the loaded repository is known, the site files would lose the
+	// other ones.
+	_, err = g.GenerateRepos([]*Repo{r})
+
+	return err
+}
+
+// nextVersion returns the version after latest incrementing the given part,
+// pre-releases are released by the increments they are part of (like
+// v1.1.0-rc.1 to v1.1.0 with minor). Modules with a major version suffix
+// (like v2) start at it and can't leave it.
+func nextVersion(latest, bump, major string) (string, error) {
+	if bump != "major" && bump != "minor" && bump != "patch" {
+		return "", fmt.Errorf("unknown version part %q", bump)
+	}
+
+	if latest == "" {
+		if major != "" {
+			return major + ".0.0", nil
+		}
+
+		latest = "v0.0.0"
+	}
+
+	sv, ok := parseSemver(latest)
+	if !ok {
+		return "", fmt.Errorf("invalid version %q", latest)
+	}
+
+	pre := sv.pre != ""
+	sv.pre, sv.build = "", ""
+
+	switch bump {
+	case "patch":
+		if !pre {
+			sv.patch++
+		}
+	case "minor":
+		if !pre || sv.patch != 0 {
+			sv.minor, sv.patch = sv.minor+1, 0
+		}
+	case "major":
+		if !pre || sv.minor != 0 || sv.patch != 0 {
+			sv.major, sv.minor, sv.patch = sv.major+1, 0, 0
+		}
+	}
+
+	if m := "v" + strconv.Itoa(sv.major); sv.major > 1 && m != major {
+		return "", fmt.Errorf("%s needs a module path ending in /%s", m, m)
+	}
+
+	return sv.String(), nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// scpURL splits a scp-like repository URL, like git@github.com:org/x.git,
+// into its user, host and path.
+func scpURL(repoURL string) (user, host, p string, ok bool) {
+	if strings.Contains(repoURL, "://") {
+		return "", "", "", false
+	}
+
+	user, rest, ok := strings.Cut(repoURL, "@")
+	if !ok || strings.Contains(user, "/") {
+		return "", "", "", false
+	}
+
+	host, p, ok = strings.Cut(rest, ":")
+	if !ok || host == "" || strings.Contains(host, "/") {
+		return "", "", "", false
+	}
+
+	return user, host, p, true
+}
+
+// publicURL returns the repository URL of the go-import tags: the
+// replacement of the longest rewrite prefix it has, or the URL itself, as a
+// ssh:// URL if it is scp-like (which the go command doesn't accept).
+func (cfg *Config) publicURL(repoURL string) string {
+	var match [2]string
+
+	for _, rw := range cfg.Rewrites {
+		if strings.HasPrefix(repoURL, rw[0]) && len(rw[0]) > len(match[0]) {
+			match = rw
+		}
+	}
+
+	if match[0] != "" {
+		return match[1] + strings.TrimPrefix(repoURL, match[0])
+	}
+
+	if user, host, p, ok := scpURL(repoURL); ok {
+		return "ssh://" + user + "@" + host + "/" + strings.TrimPrefix(p, "/")
+	}
+
+	return repoURL
+}
+
+// webURL returns the web page of a repository URL, for source links: HTTPS
+// URLs without the .git suffix, and the HTTPS URL of the same host and path
+// for ssh and git URLs. Other URLs, like file ones, don't have one.
+func webURL(repoURL string) string {
+	host, p := "", ""
+
+	if _, h, sp, ok := scpURL(repoURL); ok {
+		host, p = h, sp
+	} else if u, err := url.Parse(repoURL); err == nil {
+		switch u.Scheme {
+		case "http", "https":
+			return strings.TrimSuffix(strings.TrimSuffix(repoURL, "/"), ".git")
+		case "ssh", "git", "git+ssh":
+			host, p = u.Hostname(), u.Path
+		}
+	}
+
+	p = strings.TrimSuffix(strings.Trim(p, "/"), ".git")
+	if host == "" || p == "" {
+		return ""
+	}
+
+	return "https://" + host + "/" + p
+}
+
+// networkURL reports if the repository URL is cloned from a remote host,
+// unlike file URLs and local paths.
+func networkURL(repoURL string) bool {
+	if _, _, _, ok := scpURL(repoURL); ok {
+		return true
+	}
+
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "http", "https", "ssh", "git", "git+ssh":
+		return true
+	}
+
+	return false
+}
+
+// normalizeRepoURL returns the repository URL without trailing slashes,
+// with its scheme and host in lower case.
+func normalizeRepoURL(repoURL string) string {
+	if s := strings.TrimRight(repoURL, "/"); s != "" {
+		repoURL = s
+	}
+
+	if user, host, p, ok := scpURL(repoURL); ok {
+		return user + "@" + strings.ToLower(host) + ":" + p
+	}
+
+	scheme, rest, ok := strings.Cut(repoURL, "://")
+	if !ok {
+		return repoURL
+	}
+
+	authority, p, _ := strings.Cut(rest, "/")
+	userinfo, host, ok := strings.Cut(authority, "@") // Users are case sensitive.
+	if !ok {
+		userinfo, host = "", authority
+	} else {
+		userinfo += "@"
+	}
+
+	repoURL = strings.ToLower(scheme) + "://" + userinfo + strings.ToLower(host)
+	if p != "" {
+		repoURL += "/" + p
+	}
+
+	return repoURL
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// uploadRsync syncs the directory to a remote host over SSH with rsync,
+// with URLs like ssh://user@host:port/path. Only changed files are
+// transferred, by checksum, and remote files that don't exist locally are
+// deleted. SSH authentication is up to the SSH client configuration.
+func uploadRsync(u *url.URL, dir string, c cmdRunner) error {
+	if u.Hostname() == "" || u.Path == "" {
+		return errors.New("rsync: upload URL must have a host and a path")
+	}
+
+	ssh := "ssh"
+	if port := u.Port(); port != "" {
+		ssh += " -p " + port
+	}
+
+	// Paths are absolute, or relative to the home directory when they start
+	// with /~/, as with git.
+	p := u.Path
+	if rel, ok := strings.CutPrefix(p, "/~/"); ok {
+		p = rel
+	}
+
+	dst := u.Hostname() + ":" + strings.TrimSuffix(p, "/") + "/"
+
+	if u.User != nil {
+		dst = u.User.Username() + "@" + dst
+	}
+
+	args := []string{"rsync", "-rlt", "--checksum", "--delete", "--chmod=D755,F644", "-e", ssh, c.cmdPath(dir) + "/", dst}
+
+	if err := c.runCmd(".", os.Environ(), args...); err != nil {
+		return err
+	}
+
+	log.Printf("rsync: synced %s to %s", dir, u.Redacted())
+
+	return nil
+}
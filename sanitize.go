@@ -0,0 +1,131 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// htmlMarker prefixes configured descriptions written in HTML instead of
+// plain text, like "html:Bindings for <a href=\"https://sqlite.org\">SQLite</a>".
+const htmlMarker = "html:"
+
+// parseDescription returns the plain text of a configured description and,
+// for HTML ones, its sanitized HTML.
+func parseDescription(value string) (string, template.HTML) {
+	src, ok := strings.CutPrefix(value, htmlMarker)
+	if !ok {
+		return value, ""
+	}
+
+	return htmlText(src), sanitizeHTML(src)
+}
+
+var htmlTagRe = regexp.MustCompile(`<(/?)([A-Za-z][A-Za-z0-9]*)((?:\s+[A-Za-z-]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'>]+))?)*)\s*/?>`)
+
+var htmlAttrRe = regexp.MustCompile(`([A-Za-z-]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+
+// allowedTags are the inline elements kept by sanitizeHTML, with their
+// allowed attributes.
+var allowedTags = map[string][]string{
+	"a":      {"href", "title"},
+	"abbr":   {"title"},
+	"b":      nil,
+	"br":     nil,
+	"code":   nil,
+	"em":     nil,
+	"i":      nil,
+	"kbd":    nil,
+	"strong": nil,
+	"sub":    nil,
+	"sup":    nil,
+}
+
+// sanitizeHTML returns the HTML snippet with only the allowed inline
+// elements and attributes, links only to http, https and mailto URLs or
+// relative ones. Other tags are removed keeping their text, and the text is
+// escaped, so the result is balanced and safe to render as it is.
+func sanitizeHTML(src string) template.HTML {
+	var (
+		b    strings.Builder
+		open []string
+	)
+
+	text := func(s string) {
+		b.WriteString(html.EscapeString(html.UnescapeString(s)))
+	}
+
+	last := 0
+
+	for _, m := range htmlTagRe.FindAllStringSubmatchIndex(src, -1) {
+		text(src[last:m[0]])
+		last = m[1]
+
+		closing := src[m[2]:m[3]] == "/"
+		name := strings.ToLower(src[m[4]:m[5]])
+
+		attrs, ok := allowedTags[name]
+		if !ok {
+			continue
+		}
+
+		if closing {
+			if i := slices.Index(open, name); i >= 0 {
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+
+				open = open[:i]
+			}
+
+			continue
+		}
+
+		b.WriteString("<" + name)
+
+		for _, a := range htmlAttrRe.FindAllStringSubmatch(src[m[6]:m[7]], -1) {
+			key, value := strings.ToLower(a[1]), html.UnescapeString(a[2]+a[3]+a[4])
+			if !slices.Contains(attrs, key) || key == "href" && !safeURL(value) {
+				continue
+			}
+
+			b.WriteString(" " + key + `="` + html.EscapeString(value) + `"`)
+		}
+
+		b.WriteString(">")
+
+		if name != "br" {
+			open = append(open, name)
+		}
+	}
+
+	text(src[last:])
+
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+
+	return template.HTML(b.String())
+}
+
+func safeURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	}
+
+	return false
+}
+
+// htmlText returns the text of an HTML snippet, without tags.
+func htmlText(src string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(htmlTagRe.ReplaceAllString(src, ""))), " ")
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sbomFormats write the software bill of materials of a module into a
+// file next to its page, by format name.
+var sbomFormats = map[string]struct {
+	file  string
+	write func(b *sbom) any
+}{
+	"cyclonedx": {"sbom.cdx.json", cycloneDX},
+	"spdx":      {"sbom.spdx.json", spdxDocument},
+}
+
+// sbom is the bill of materials of a module at the checked out revision,
+// its components are the requirements of the go.mod file.
+type sbom struct {
+	Module   string
+	Version  string // Latest if tagged at the revision, a pseudo-version otherwise.
+	Revision string
+	Time     time.Time // Of the revision, so unchanged revisions give the same files.
+	License  string
+	Source   string
+
+	Components []sbomComponent
+}
+
+type sbomComponent struct {
+	Path     string
+	Version  string
+	Indirect bool
+	Hash     string // SHA-256 of the go.sum h1 hash, in hex.
+}
+
+func (c sbomComponent) purl() string {
+	return purl(c.Path, c.Version)
+}
+
+// purl returns the package URL of a Go module version.
+func purl(modPath, version string) string {
+	return "pkg:golang/" + modPath + "@" + strings.ReplaceAll(version, "+", "%2B")
+}
+
+// writeSBOM writes the bill of materials of the module in the given format,
+// and returns its URL path. Non-local replacements are applied to the
+// requirements, like the builds of the revision do, local ones are part of
+// the repository and left as required.
+func writeSBOM(out Output, mod *Module, info *ModuleInfo, format string, flat bool) (string, error) {
+	f := sbomFormats[format]
+
+	modDir := filepath.Join(mod.RepoDir, mod.Subdir)
+
+	rev, err := mod.cmd.gitRevision(mod.RepoDir, "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	t, err := mod.cmd.gitCommitTime(mod.RepoDir, rev)
+	if err != nil {
+		return "", err
+	}
+
+	b := &sbom{
+		Module:   mod.Path,
+		Version:  info.Latest,
+		Revision: rev,
+		Time:     t,
+		License:  info.License,
+		Source:   strings.TrimSuffix(mod.Repo.URL, ".git"),
+	}
+
+	if tagged, _ := mod.cmd.gitRevision(mod.RepoDir, info.LatestTag); info.Latest == "" || tagged != rev {
+		b.Version = pseudoVersion(info.Latest, pathMajor(mod.Path), t, rev)
+	}
+
+	sums, err := readGoSum(filepath.Join(modDir, "go.sum"))
+	if err != nil {
+		return "", err
+	}
+
+	if info.GoMod != nil {
+		for _, req := range info.GoMod.Require {
+			c := sbomComponent{Path: req.Path, Version: req.Version, Indirect: req.Indirect}
+
+			for _, r := range info.GoMod.Replace {
+				if r.Old == req.Path && (r.OldVersion == "" || r.OldVersion == req.Version) && !r.Local() {
+					c.Path, c.Version = r.New, r.NewVersion
+				}
+			}
+
+			c.Hash = sums[c.Path+" "+c.Version]
+			b.Components = append(b.Components, c)
+		}
+	}
+
+	data, err := json.MarshalIndent(f.write(b), "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := out.WriteFile(path.Join(mod.Path, f.file), append(data, '\n')); err != nil {
+		return "", err
+	}
+
+	return path.Join(pageURL(mod.Path, flat), f.file), nil
+}
+
+// readGoSum returns the hashes of the module trees in a go.sum file as
+// hexadecimal SHA-256 sums, by "path version". go.mod hashes are skipped.
+// A missing file has no hashes.
+func readGoSum(name string) (map[string]string, error) {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]string)
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+
+		h, ok := strings.CutPrefix(fields[2], "h1:")
+		if !ok {
+			continue
+		}
+
+		sum, err := base64.StdEncoding.DecodeString(h)
+		if err != nil || len(sum) != sha256.Size {
+			continue
+		}
+
+		sums[fields[0]+" "+fields[1]] = hex.EncodeToString(sum)
+	}
+
+	return sums, sc.Err()
+}
+
+// pseudoVersion returns the Go pseudo-version of an untagged revision,
+// latest is the latest version before it, if any.
+func pseudoVersion(latest, major string, t time.Time, rev string) string {
+	suffix := t.UTC().Format("20060102150405") + "-" + rev[:min(12, len(rev))]
+
+	sv, ok := parseSemver(latest)
+	switch {
+	case !ok:
+		return cmp.Or(major, "v0") + ".0.0-" + suffix
+	case sv.pre != "":
+		return sv.String() + ".0." + suffix
+	default:
+		sv.patch++
+		return sv.String() + "-0." + suffix
+	}
+}
+
+// serialNumber returns an UUID derived from the bill of materials, so the
+// same revision has the same serial number.
+func (b *sbom) serialNumber() string {
+	h := sha256.Sum256([]byte(b.Module + "@" + b.Version + " " + b.Revision))
+	h[6] = h[6]&0x0f | 0x50
+	h[8] = h[8]&0x3f | 0x80
+
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+// cycloneDX returns the bill of materials as a CycloneDX 1.5 document.
+func cycloneDX(b *sbom) any {
+	type hash struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	}
+
+	type license struct {
+		License struct {
+			ID string `json:"id"`
+		} `json:"license"`
+	}
+
+	type ref struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	}
+
+	type component struct {
+		BOMRef             string    `json:"bom-ref"`
+		Type               string    `json:"type"`
+		Name               string    `json:"name"`
+		Version            string    `json:"version"`
+		PURL               string    `json:"purl"`
+		Scope              string    `json:"scope,omitempty"`
+		Hashes             []hash    `json:"hashes,omitempty"`
+		Licenses           []license `json:"licenses,omitempty"`
+		ExternalReferences []ref     `json:"externalReferences,omitempty"`
+	}
+
+	type dependency struct {
+		Ref       string   `json:"ref"`
+		DependsOn []string `json:"dependsOn"`
+	}
+
+	root := component{
+		BOMRef:             purl(b.Module, b.Version),
+		Type:               "library",
+		Name:               b.Module,
+		Version:            b.Version,
+		PURL:               purl(b.Module, b.Version),
+		ExternalReferences: []ref{{"vcs", b.Source}},
+	}
+
+	if b.License != "" {
+		var l license
+		l.License.ID = b.License
+		root.Licenses = []license{l}
+	}
+
+	components := []component{}
+	deps := []dependency{{Ref: root.BOMRef, DependsOn: []string{}}}
+
+	for _, c := range b.Components {
+		cc := component{
+			BOMRef:  c.purl(),
+			Type:    "library",
+			Name:    c.Path,
+			Version: c.Version,
+			PURL:    c.purl(),
+			Scope:   "required",
+		}
+
+		if c.Hash != "" {
+			cc.Hashes = []hash{{"SHA-256", c.Hash}}
+		}
+
+		components = append(components, cc)
+
+		if !c.Indirect {
+			deps[0].DependsOn = append(deps[0].DependsOn, cc.BOMRef)
+		}
+	}
+
+	return map[string]any{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": b.serialNumber(),
+		"version":      1,
+		"metadata": map[string]any{
+			"timestamp": b.Time.Format(time.RFC3339),
+			"tools": map[string]any{
+				"components": []map[string]string{{"type": "application", "name": "vanitic"}},
+			},
+			"component": root,
+		},
+		"components":   components,
+		"dependencies": deps,
+	}
+}
+
+// spdxDocument returns the bill of materials as a SPDX 2.3 document.
+func spdxDocument(b *sbom) any {
+	const none = "NOASSERTION"
+
+	type checksum struct {
+		Algorithm string `json:"algorithm"`
+		Value     string `json:"checksumValue"`
+	}
+
+	type ref struct {
+		Category string `json:"referenceCategory"`
+		Type     string `json:"referenceType"`
+		Locator  string `json:"referenceLocator"`
+	}
+
+	type pkg struct {
+		ID               string     `json:"SPDXID"`
+		Name             string     `json:"name"`
+		Version          string     `json:"versionInfo"`
+		DownloadLocation string     `json:"downloadLocation"`
+		FilesAnalyzed    bool       `json:"filesAnalyzed"`
+		LicenseConcluded string     `json:"licenseConcluded"`
+		LicenseDeclared  string     `json:"licenseDeclared"`
+		CopyrightText    string     `json:"copyrightText"`
+		Checksums        []checksum `json:"checksums,omitempty"`
+		ExternalRefs     []ref      `json:"externalRefs"`
+		Comment          string     `json:"comment,omitempty"`
+	}
+
+	type relationship struct {
+		Element string `json:"spdxElementId"`
+		Type    string `json:"relationshipType"`
+		Related string `json:"relatedSpdxElement"`
+	}
+
+	root := pkg{
+		ID:               "SPDXRef-Package-0",
+		Name:             b.Module,
+		Version:          b.Version,
+		DownloadLocation: "git+" + b.Source + "@" + b.Revision,
+		LicenseConcluded: none,
+		LicenseDeclared:  none,
+		CopyrightText:    none,
+		ExternalRefs:     []ref{{"PACKAGE-MANAGER", "purl", purl(b.Module, b.Version)}},
+	}
+
+	if b.License != "" {
+		root.LicenseDeclared = b.License
+	}
+
+	pkgs := []pkg{root}
+	rels := []relationship{{"SPDXRef-DOCUMENT", "DESCRIBES", root.ID}}
+
+	for i, c := range b.Components {
+		p := pkg{
+			ID:               "SPDXRef-Package-" + strconv.Itoa(i+1),
+			Name:             c.Path,
+			Version:          c.Version,
+			DownloadLocation: none,
+			LicenseConcluded: none,
+			LicenseDeclared:  none,
+			CopyrightText:    none,
+			ExternalRefs:     []ref{{"PACKAGE-MANAGER", "purl", c.purl()}},
+		}
+
+		if c.Hash != "" {
+			p.Checksums = []checksum{{"SHA256", c.Hash}}
+		}
+
+		if c.Indirect {
+			p.Comment = "Indirect requirement."
+		} else {
+			rels = append(rels, relationship{root.ID, "DEPENDS_ON", p.ID})
+		}
+
+		pkgs = append(pkgs, p)
+	}
+
+	return map[string]any{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              b.Module + "@" + b.Version,
+		"documentNamespace": "https://" + b.Module + "/sbom/" + b.Revision,
+		"creationInfo": map[string]any{
+			"created":  b.Time.Format(time.RFC3339),
+			"creators": []string{"Tool: vanitic"},
+		},
+		"packages":      pkgs,
+		"relationships": rels,
+	}
+}
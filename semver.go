@@ -0,0 +1,175 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// semver is a parsed semantic version, as used by Go modules (vMAJOR.MINOR.PATCH
+// with optional pre-release and build metadata).
+type semver struct {
+	major, minor, patch int
+	pre                 string
+	build               string
+}
+
+func parseSemver(v string) (semver, bool) {
+	var sv semver
+
+	rest, ok := strings.CutPrefix(v, "v")
+	if !ok {
+		return sv, false
+	}
+
+	var hasPre, hasBuild bool
+
+	rest, sv.build, hasBuild = strings.Cut(rest, "+")
+	rest, sv.pre, hasPre = strings.Cut(rest, "-")
+
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return sv, false
+	}
+
+	nums := []*int{&sv.major, &sv.minor, &sv.patch}
+
+	for i, p := range parts {
+		if p == "" || (len(p) > 1 && p[0] == '0') {
+			return sv, false
+		}
+
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return sv, false
+		}
+
+		*nums[i] = n
+	}
+
+	if hasPre && !validIdents(sv.pre) || hasBuild && !validIdents(sv.build) {
+		return sv, false
+	}
+
+	return sv, true
+}
+
+func validIdents(s string) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+
+		for _, r := range id {
+			if !(r == '-' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (sv semver) String() string {
+	s := "v" + strconv.Itoa(sv.major) + "." + strconv.Itoa(sv.minor) + "." + strconv.Itoa(sv.patch)
+
+	if sv.pre != "" {
+		s += "-" + sv.pre
+	}
+
+	return s
+}
+
+// compareSemver compares two versions following the semver precedence
+// rules, build metadata is ignored.
+func compareSemver(a, b semver) int {
+	for _, d := range []int{a.major - b.major, a.minor - b.minor, a.patch - b.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+
+	switch {
+	case a.pre == b.pre:
+		return 0
+	case a.pre == "":
+		return 1
+	case b.pre == "":
+		return -1
+	}
+
+	as, bs := strings.Split(a.pre, "."), strings.Split(b.pre, ".")
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := comparePreIdent(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+
+	return sign(len(as) - len(bs))
+}
+
+func comparePreIdent(a, b string) int {
+	an, aerr := strconv.Atoi(a)
+	bn, berr := strconv.Atoi(b)
+
+	switch {
+	case aerr == nil && berr == nil:
+		return sign(an - bn)
+	case aerr == nil:
+		return -1
+	case berr == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+
+	return 0
+}
+
+// sortSemver sorts the given versions in ascending order.
+func sortSemver(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, _ := parseSemver(versions[i])
+		b, _ := parseSemver(versions[j])
+
+		return compareSemver(a, b) < 0
+	})
+}
+
+// latestSemver returns the highest release version, or the highest
+// pre-release if there are no releases.
+func latestSemver(versions []string) string {
+	var latest, latestPre string
+
+	for _, v := range versions {
+		sv, ok := parseSemver(v)
+		if !ok {
+			continue
+		}
+
+		cur := &latest
+		if sv.pre != "" {
+			cur = &latestPre
+		}
+
+		if c, _ := parseSemver(*cur); *cur == "" || compareSemver(sv, c) > 0 {
+			*cur = v
+		}
+	}
+
+	if latest != "" {
+		return latest
+	}
+
+	return latestPre
+}
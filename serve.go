@@ -0,0 +1,938 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"math/rand/v2"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+func serveCmd(args []string) error {
+	opts := DefaultOptions()
+	fset := opts.FlagSet("vanitic serve")
+	sopts := DefaultServeOptions()
+
+	fset.StringVar(
+		&sopts.Addr, "addr", sopts.Addr,
+		"TCP address to listen for HTTP requests.",
+	)
+
+	fset.StringVar(
+		&sopts.WebhookSecret, "webhook-secret", sopts.WebhookSecret,
+		"Enable webhooks at /-/webhook with the given secret source (env:NAME or file:PATH).",
+	)
+
+	fset.BoolVar(
+		&sopts.NoGenerate, "no-gen", sopts.NoGenerate,
+		"Serve the output directory as is, without generating files at start.",
+	)
+
+	fset.BoolVar(
+		&sopts.Dynamic, "dynamic", sopts.Dynamic,
+		"Generate files of repositories added to the configuration on first request of a path with their name.",
+	)
+
+	fset.DurationVar(
+		&sopts.DynamicInterval, "dynamic-interval", sopts.DynamicInterval,
+		"Minimum time between on demand generations for the same unknown path.",
+	)
+
+	fset.DurationVar(
+		&sopts.Refresh, "refresh", sopts.Refresh,
+		"Interval for regenerating all the files in the background, zero disables it.",
+	)
+
+	fset.BoolVar(
+		&sopts.Watch, "watch", sopts.Watch,
+		"Regenerate the files when the configuration, templates or static files change, and reload the open pages.",
+	)
+
+	fset.DurationVar(
+		&sopts.WatchInterval, "watch-interval", sopts.WatchInterval,
+		"Interval for checking the watched files for changes.",
+	)
+
+	fset.DurationVar(
+		&sopts.ShutdownTimeout, "shutdown-timeout", sopts.ShutdownTimeout,
+		"Maximum time to wait for active connections when shutting down.",
+	)
+
+	fset.StringVar(
+		&sopts.Domains, "domains", sopts.Domains,
+		"Comma separated list of domains to obtain TLS certificates for with ACME. HTTP-01 challenges are answered at -addr, which must be reachable at port 80.",
+	)
+
+	fset.StringVar(
+		&sopts.TLSAddr, "tls-addr", sopts.TLSAddr,
+		"TCP address to listen for HTTPS requests when using -domains.",
+	)
+
+	fset.StringVar(
+		&sopts.ACMEDirectory, "acme-directory", sopts.ACMEDirectory,
+		"ACME directory URL.",
+	)
+
+	fset.StringVar(
+		&sopts.ACMEEmail, "acme-email", sopts.ACMEEmail,
+		"Contact email for the ACME account.",
+	)
+
+	fset.StringVar(
+		&sopts.ACMECache, "acme-cache", sopts.ACMECache,
+		"Directory where certificates and the ACME account key are cached.",
+	)
+
+	fset.StringVar(
+		&sopts.AdminToken, "admin-token", sopts.AdminToken,
+		"Enable the admin API at /-/ with the given bearer token source (env:NAME or file:PATH).",
+	)
+
+	fset.StringVar(
+		&sopts.ProxyPath, "proxy", sopts.ProxyPath,
+		"Path where the module proxy protocol is served for the generated modules (e.g. /-/proxy/), empty disables it.",
+	)
+
+	fset.StringVar(
+		&sopts.ProxyUpstream, "proxy-upstream", sopts.ProxyUpstream,
+		"Upstream module proxy (e.g. https://proxy.golang.org) for versions of the generated modules not available locally.",
+	)
+
+	fset.StringVar(
+		&sopts.ProxyCache, "proxy-cache", sopts.ProxyCache,
+		"Directory where files from the upstream module proxy are cached.",
+	)
+
+	fset.Int64Var(
+		&sopts.ProxyCacheSize, "proxy-cache-size", sopts.ProxyCacheSize,
+		"Maximum size in bytes of the upstream module proxy cache.",
+	)
+
+	fset.StringVar(
+		&sopts.MetricsPath, "metrics", sopts.MetricsPath,
+		"Path where Prometheus metrics are exposed, empty disables them.",
+	)
+
+	fset.StringVar(
+		&sopts.AccessLog, "access-log", sopts.AccessLog,
+		"Access log destination, a file path or - for stdout. Empty disables access logs.",
+	)
+
+	fset.StringVar(
+		&sopts.AccessLogFormat, "access-log-format", sopts.AccessLogFormat,
+		"Access log format, common or json.",
+	)
+
+	fset.StringVar(
+		&sopts.StatsPath, "stats", sopts.StatsPath,
+		"Path where the request counts of the modules since the server start are served (e.g. /-/stats, and /-/stats.json for JSON), protected by the admin token. Empty disables them.",
+	)
+
+	fset.StringVar(
+		&sopts.PagesCacheControl, "cache-pages", sopts.PagesCacheControl,
+		"Cache-Control header for HTML pages, which include go-get responses.",
+	)
+
+	fset.StringVar(
+		&sopts.AssetsCacheControl, "cache-assets", sopts.AssetsCacheControl,
+		"Cache-Control header for any other file.",
+	)
+
+	sopts.Limits.AddFlags(fset)
+
+	if err := opts.Parse(fset, args); err != nil {
+		return err
+	}
+
+	g, err := NewGenerator(opts)
+	if err != nil {
+		return err
+	}
+
+	srv, err := NewServer(g, sopts)
+	if err != nil {
+		return err
+	}
+
+	if !sopts.NoGenerate {
+		if _, err := g.Generate(); err != nil {
+			return err
+		}
+	}
+
+	return srv.Run()
+}
+
+// Run listens for requests until SIGINT or SIGTERM is received, then waits
+// for active connections to finish. SIGHUP reloads the configuration and
+// regenerates the served files.
+func (s *Server) Run() error {
+	var servers []*http.Server
+
+	if s.opts.Domains == "" {
+		servers = append(servers, s.opts.Limits.Server(s.opts.Addr, s))
+		log.Printf("serving %s on %s", s.g.opts.Output, s.opts.Addr)
+	} else {
+		// The HTTP listener only answers ACME challenges and redirects to
+		// HTTPS.
+		domains := strings.Split(s.opts.Domains, ",")
+
+		m, err := newCertManager(s.opts.ACMEDirectory, s.opts.ACMEEmail, s.opts.ACMECache, domains)
+		if err != nil {
+			return err
+		}
+
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go m.Run(stop)
+
+		redirect := s.opts.Limits.Handler(m.HTTPHandler(http.HandlerFunc(redirectHTTPS)))
+		tlsSrv := s.opts.Limits.Server(s.opts.TLSAddr, s)
+		tlsSrv.TLSConfig = &tls.Config{GetCertificate: m.GetCertificate}
+
+		servers = append(servers, s.opts.Limits.Server(s.opts.Addr, redirect), tlsSrv)
+
+		log.Printf("serving %s on %s (HTTPS) and %s", s.g.opts.Output, s.opts.TLSAddr, s.opts.Addr)
+	}
+
+	if s.opts.Refresh > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go s.refresh(stop)
+	}
+
+	if s.live != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+
+		go newWatcher(s.g.opts).Run(stop, s.opts.WatchInterval, func() {
+			if _, err := s.Reload(); err == nil {
+				s.live.notify()
+			}
+		})
+	}
+
+	errc := make(chan error, len(servers))
+
+	for _, srv := range servers {
+		go func() {
+			if srv.TLSConfig != nil {
+				errc <- srv.ListenAndServeTLS("", "")
+			} else {
+				errc <- srv.ListenAndServe()
+			}
+		}()
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigc)
+
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case sig := <-sigc:
+			if sig == syscall.SIGHUP {
+				go s.Reload()
+				continue
+			}
+
+			log.Printf("shutting down, waiting up to %s for active connections", s.opts.ShutdownTimeout)
+
+			ctx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+			defer cancel()
+
+			var err error
+
+			for _, srv := range servers {
+				if e := srv.Shutdown(ctx); e != nil && err == nil {
+					err = e
+				}
+			}
+
+			return err
+		}
+	}
+}
+
+// Reload reads the configuration again and regenerates all the files in the
+// background, the served tree is replaced when the generation succeeds.
+// Only one reload runs at a time, calls during a reload are ignored.
+func (s *Server) Reload() (*Summary, error) {
+	if !s.reloading.CompareAndSwap(false, true) {
+		return nil, errReloading
+	}
+
+	defer s.reloading.Store(false)
+
+	if err := s.g.Reload(); err != nil {
+		log.Printf("reload: %v", err)
+		return nil, err
+	}
+
+	sum, err := s.g.GenerateAtomic(s.swap)
+	if err != nil {
+		log.Printf("reload: %v", err)
+		return sum, err
+	}
+
+	log.Printf("reload: done in %s", time.Duration(sum.Duration))
+
+	return sum, nil
+}
+
+var errReloading = errors.New("reload in progress")
+
+// refresh reloads periodically until stop is closed. Intervals have up to 10%
+// of random jitter, so multiple instances don't hit forges at the same time.
+func (s *Server) refresh(stop <-chan struct{}) {
+	for {
+		d := s.opts.Refresh
+		jitter := int64(d / 10)
+
+		if jitter > 0 {
+			d += time.Duration(rand.Int64N(2*jitter) - jitter)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(d):
+		}
+
+		if _, err := s.Reload(); errors.Is(err, errReloading) {
+			log.Printf("refresh: skipped, %v", err)
+		}
+	}
+}
+
+// swap replaces the output directory with next. Requests are blocked while
+// directories are renamed, so they always see a complete tree.
+func (s *Server) swap(next string) error {
+	prev := s.root + ".prev"
+
+	if err := os.RemoveAll(prev); err != nil {
+		return err
+	}
+
+	s.rootMu.Lock()
+
+	if err := os.Rename(s.root, prev); err != nil && !os.IsNotExist(err) {
+		s.rootMu.Unlock()
+		return err
+	}
+
+	if err := os.Rename(next, s.root); err != nil {
+		os.Rename(prev, s.root)
+		s.rootMu.Unlock()
+
+		return err
+	}
+
+	s.rootMu.Unlock()
+
+	return os.RemoveAll(prev)
+}
+
+func redirectHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+type ServeOptions struct {
+	Addr          string
+	WebhookSecret string
+	NoGenerate    bool
+
+	Refresh         time.Duration
+	ShutdownTimeout time.Duration
+
+	Watch         bool
+	WatchInterval time.Duration
+
+	Dynamic         bool
+	DynamicInterval time.Duration
+
+	Domains       string
+	TLSAddr       string
+	ACMEDirectory string
+	ACMEEmail     string
+	ACMECache     string
+
+	PagesCacheControl  string
+	AssetsCacheControl string
+
+	MetricsPath string
+	AdminToken  string
+	ProxyPath   string
+
+	ProxyUpstream  string
+	ProxyCache     string
+	ProxyCacheSize int64
+
+	AccessLog       string
+	AccessLogFormat string
+
+	StatsPath string // See statsHandler.
+
+	Limits Limits
+}
+
+func DefaultServeOptions() *ServeOptions {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+
+	return &ServeOptions{
+		Addr:            ":8080",
+		ShutdownTimeout: 30 * time.Second,
+		WatchInterval:   time.Second,
+		DynamicInterval: time.Minute,
+		TLSAddr:         ":443",
+		ACMEDirectory:   letsEncryptURL,
+		ACMECache:       filepath.Join(cacheDir, "vanitic", "acme"),
+
+		PagesCacheControl:  pagesCacheControl,
+		AssetsCacheControl: assetsCacheControl,
+
+		MetricsPath: "/metrics",
+
+		ProxyCache:     filepath.Join(cacheDir, "vanitic", "proxy"),
+		ProxyCacheSize: 1 << 30,
+
+		AccessLogFormat: "common",
+
+		Limits: DefaultLimits(),
+	}
+}
+
+// Server serves the generated files over HTTP.
+type Server struct {
+	g    *Generator
+	opts *ServeOptions
+	mux  *http.ServeMux
+
+	handler http.Handler // The mux with the request limits.
+
+	rootMu    sync.RWMutex // Held for writing while the served tree is replaced.
+	root      string
+	reloading atomic.Bool
+
+	metrics   *Metrics
+	accessLog *accessLog
+	stats     *Stats
+	live      *liveReload // Only in watch mode.
+
+	dynMu       sync.Mutex
+	misses      map[string]time.Time
+	lastReload  time.Time
+	lastDynamic time.Time                 // Of the last on demand generation.
+	dynFailures map[string]dynamicFailure // By repository key.
+
+	etagsMu sync.Mutex
+	etags   map[string]etag
+}
+
+type etag struct {
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+func NewServer(g *Generator, opts *ServeOptions) (*Server, error) {
+	s := &Server{
+		g:      g,
+		opts:   opts,
+		root:   g.opts.Output,
+		mux:    http.NewServeMux(),
+		misses: make(map[string]time.Time),
+		etags:  make(map[string]etag),
+
+		dynFailures: make(map[string]dynamicFailure),
+	}
+
+	if opts.WebhookSecret != "" {
+		secret, err := readSecret(opts.WebhookSecret)
+		if err != nil {
+			return nil, err
+		}
+
+		s.mux.Handle("/-/webhook", NewWebhookHandler(g, secret))
+	}
+
+	if opts.AdminToken != "" {
+		token, err := readSecret(opts.AdminToken)
+		if err != nil {
+			return nil, err
+		}
+
+		admin := &adminHandler{s: s, token: []byte(token)}
+		s.mux.Handle("/-/rebuild", admin)
+		s.mux.Handle("/-/rebuild/", admin)
+	}
+
+	if opts.ProxyPath != "" {
+		prefix := s.proxyPrefix()
+		h := &proxyHandler{g: g}
+
+		if opts.ProxyUpstream != "" {
+			h.cache = &proxyCache{
+				upstream: strings.TrimSuffix(opts.ProxyUpstream, "/"),
+				dir:      opts.ProxyCache,
+				maxSize:  opts.ProxyCacheSize,
+			}
+		}
+
+		s.mux.Handle(prefix+"/", http.StripPrefix(prefix, h))
+	}
+
+	if opts.MetricsPath != "" {
+		s.metrics = NewMetrics()
+		g.metrics = s.metrics
+		s.mux.Handle(opts.MetricsPath, s.metrics)
+	}
+
+	if opts.AccessLog != "" {
+		l, err := newAccessLog(opts.AccessLog, opts.AccessLogFormat)
+		if err != nil {
+			return nil, err
+		}
+
+		s.accessLog = l
+	}
+
+	if opts.StatsPath != "" {
+		if opts.AdminToken == "" {
+			return nil, errors.New("the stats need an admin token")
+		}
+
+		token, err := readSecret(opts.AdminToken)
+		if err != nil {
+			return nil, err
+		}
+
+		s.stats = newStats(time.Now())
+
+		p := "/" + strings.Trim(opts.StatsPath, "/")
+		h := &statsHandler{stats: s.stats, token: []byte(token)}
+		s.mux.Handle(p, h)
+		s.mux.Handle(p+".json", h)
+	}
+
+	if opts.Watch {
+		s.live = newLiveReload()
+		s.mux.Handle(liveReloadPath, s.live)
+	}
+
+	s.mux.HandleFunc("/", s.serveFile)
+	s.handler = opts.Limits.Handler(s.mux)
+
+	return s, nil
+}
+
+// proxyPrefix returns the path of the module proxy, without trailing
+// slash, or an empty string if it's disabled.
+func (s *Server) proxyPrefix() string {
+	if s.opts.ProxyPath == "" {
+		return ""
+	}
+
+	return "/" + strings.Trim(s.opts.ProxyPath, "/")
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.metrics == nil && s.accessLog == nil && s.stats == nil {
+		s.handler.ServeHTTP(w, r)
+		return
+	}
+
+	start := time.Now()
+
+	// Only paths of served files and handlers are used as labels, so
+	// unknown paths can't increase the metrics cardinality.
+	_, pattern := s.mux.Handler(r)
+	if pattern == "/" {
+		pattern = "other"
+	}
+
+	sw := &statusWriter{ResponseWriter: w, path: pattern}
+	s.handler.ServeHTTP(sw, r)
+
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+
+	if s.metrics != nil {
+		s.metrics.observeRequest(sw.path, requestClient(r), sw.status)
+	}
+
+	if s.accessLog == nil && s.stats == nil {
+		return
+	}
+
+	e := newAccessEntry(r, sw, start)
+
+	if s.accessLog != nil {
+		s.accessLog.log(e)
+	}
+
+	if s.stats != nil {
+		s.stats.record(e, s.proxyPrefix(), func(host, p string) string {
+			return moduleResolver(s.g.modulePaths())(host, p)
+		})
+	}
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	goGet := requestClient(r) == "go-get"
+
+	f, name, err := s.open(r.Host, r.URL.Path, goGet)
+	if err != nil && s.opts.Dynamic {
+		if _, err = s.resolveDynamic(r.Host, r.URL.Path); err == nil {
+			f, name, err = s.open(r.Host, r.URL.Path, goGet)
+		}
+	}
+
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	setPathLabel(w, path.Clean("/"+r.URL.Path))
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tag, err := s.etag(name, fi, f)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	cacheControl := s.opts.AssetsCacheControl
+	if filepath.Ext(name) == ".html" {
+		cacheControl = s.opts.PagesCacheControl
+	}
+
+	var content io.ReadSeeker = f
+
+	if s.live != nil {
+		cacheControl = "no-cache"
+
+		if filepath.Ext(name) == ".html" && !goGet {
+			data, err := io.ReadAll(f)
+			if err != nil {
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			content = bytes.NewReader(append(data, liveReloadScript...))
+		}
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", contentType(name))
+	h.Set("ETag", tag)
+
+	if cacheControl != "" {
+		h.Set("Cache-Control", cacheControl)
+	}
+
+	// ServeContent handles conditional requests with the ETag and
+	// modification time.
+	http.ServeContent(w, r, name, fi.ModTime(), content)
+}
+
+// etag returns the entity tag of the given file, computed from its content.
+// Tags are cached until the file changes.
+func (s *Server) etag(name string, fi os.FileInfo, f io.ReadSeeker) (string, error) {
+	s.etagsMu.Lock()
+	e, ok := s.etags[name]
+	s.etagsMu.Unlock()
+
+	if ok && e.modTime.Equal(fi.ModTime()) && e.size == fi.Size() {
+		return e.value, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	e = etag{
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+		value:   `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`,
+	}
+
+	s.etagsMu.Lock()
+	if len(s.etags) >= maxETags {
+		clear(s.etags)
+	}
+
+	s.etags[name] = e
+	s.etagsMu.Unlock()
+
+	return e.value, nil
+}
+
+// maxETags bounds the memory used for caching entity tags.
+const maxETags = 100000
+
+var errNotFound = errors.New("not found")
+
+// open opens the file for the given request path.
+func (s *Server) open(host, urlPath string, goGet bool) (*os.File, string, error) {
+	s.rootMu.RLock()
+	defer s.rootMu.RUnlock()
+
+	name, err := s.resolve(host, urlPath, goGet)
+	if err != nil {
+		return nil, "", err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return f, name, nil
+}
+
+// resolve returns the file for the given request path. Pages are looked up
+// under a directory named as the requested host first, since that is where
+// import paths are written to, and then at the output root. Directories
+// resolve to their minimal go-get page for Go toolchain requests, and paths
+// without a directory to the HTML file of the same name. Dot files aren't
+// served, besides the /.well-known/ directory.
+func (s *Server) resolve(host, urlPath string, goGet bool) (string, error) {
+	urlPath = path.Clean("/" + urlPath)
+
+	for i, elem := range strings.Split(urlPath, "/") {
+		if strings.HasPrefix(elem, ".") && (i != 1 || elem != wellKnownDir) {
+			return "", errNotFound
+		}
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	var candidates []string
+
+	if host != "" && !strings.ContainsAny(host, `/\`) && !strings.HasPrefix(host, ".") {
+		candidates = append(candidates, filepath.Join(s.root, host, filepath.FromSlash(urlPath)))
+	}
+
+	candidates = append(candidates, filepath.Join(s.root, filepath.FromSlash(urlPath)))
+
+	indexes := []string{"index.html"}
+	if goGet {
+		indexes = []string{goGetPage, "index.html"}
+	}
+
+	for _, name := range candidates {
+		if fi, err := os.Stat(name); err == nil {
+			if !fi.IsDir() {
+				return name, nil
+			}
+
+			for _, index := range indexes {
+				if fi, err := os.Stat(filepath.Join(name, index)); err == nil && !fi.IsDir() {
+					return filepath.Join(name, index), nil
+				}
+			}
+		}
+
+		// Pages of the flat layout and page variants.
+		if urlPath != "/" {
+			if fi, err := os.Stat(name + ".html"); err == nil && !fi.IsDir() {
+				return name + ".html", nil
+			}
+		}
+	}
+
+	return "", errNotFound
+}
+
+// maxMisses bounds the memory used for remembering unknown paths.
+const maxMisses = 10000
+
+// dynamicGap is the minimum time between on demand generations of any
+// path, and maxDynamicBackoff bounds the wait before generating again a
+// repository that failed.
+const (
+	dynamicGap        = 5 * time.Second
+	maxDynamicBackoff = time.Hour
+)
+
+// dynamicFailure is the last failed on demand generation of a repository,
+// and the failures in a row.
+type dynamicFailure struct {
+	time  time.Time
+	count int
+}
+
+// resolveDynamic generates the pending repository of the given request path
+// until it exists, the one named like the first element of the path that
+// names a pending repository. Repositories that failed are skipped for a
+// while, doubling the wait with every failure in a row.
+func (s *Server) resolveDynamic(host, urlPath string) (string, error) {
+	s.dynMu.Lock()
+	defer s.dynMu.Unlock()
+
+	if name, err := s.resolve(host, urlPath, false); err == nil {
+		return name, nil
+	}
+
+	now := time.Now()
+	urlPath = path.Clean("/" + urlPath)
+
+	if t, ok := s.misses[urlPath]; ok && now.Sub(t) < s.opts.DynamicInterval {
+		return "", errNotFound
+	}
+
+	// Unknown paths are free to request, they can't make the server
+	// generate more often than this.
+	if now.Sub(s.lastDynamic) < dynamicGap {
+		return "", errNotFound
+	}
+
+	if len(s.misses) >= maxMisses {
+		clear(s.misses)
+	}
+
+	s.misses[urlPath] = now
+
+	if now.Sub(s.lastReload) >= s.opts.DynamicInterval {
+		s.lastReload = now
+
+		if err := s.g.Reload(); err != nil {
+			log.Printf("dynamic: can't reload configuration: %v", err)
+		}
+	}
+
+	r := s.dynamicRepo(urlPath)
+	if r == nil {
+		return "", errNotFound
+	}
+
+	if f, ok := s.dynFailures[r.key()]; ok && now.Sub(f.time) < s.dynamicBackoff(f.count) {
+		return "", errNotFound
+	}
+
+	s.lastDynamic = now
+
+	// Site files need every repository, they are written with the last
+	// pending one (like with -no-gen).
+	last := len(s.g.Pending()) == 1
+
+	if _, err := s.g.generateRepos([]*Repo{r}, last); err != nil {
+		log.Printf("dynamic: %s: %v", r.URL, err)
+
+		f := s.dynFailures[r.key()]
+		s.dynFailures[r.key()] = dynamicFailure{time: time.Now(), count: f.count + 1}
+
+		return "", errNotFound
+	}
+
+	delete(s.dynFailures, r.key())
+
+	name, err := s.resolve(host, urlPath, false)
+	if err == nil {
+		delete(s.misses, urlPath)
+	}
+
+	return name, err
+}
+
+// dynamicRepo returns the pending repository named like the first element
+// of the path that names one, nil if there is none.
+func (s *Server) dynamicRepo(urlPath string) *Repo {
+	pending := s.g.Pending()
+
+	for _, elem := range strings.Split(urlPath, "/") {
+		for _, r := range pending {
+			if elem != "" && strings.TrimSuffix(repoName(r.URL), ".git") == elem {
+				return r
+			}
+		}
+	}
+
+	return nil
+}
+
+// dynamicBackoff returns the wait before generating again a repository
+// after the given failures in a row.
+func (s *Server) dynamicBackoff(failures int) time.Duration {
+	wait := max(s.opts.DynamicInterval, dynamicGap) << min(failures-1, 10)
+	if wait > maxDynamicBackoff {
+		return maxDynamicBackoff
+	}
+
+	return wait
+}
+
+var contentTypes = map[string]string{
+	".css":  "text/css; charset=utf-8",
+	".html": "text/html; charset=utf-8",
+	".js":   "text/javascript; charset=utf-8",
+	".json": "application/json",
+	".svg":  "image/svg+xml",
+	".txt":  "text/plain; charset=utf-8",
+	".xml":  "application/xml; charset=utf-8",
+}
+
+func contentType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+
+	if ct, ok := contentTypes[ext]; ok {
+		return ct
+	}
+
+	if ct := mime.TypeByExtension(ext); ct != "" {
+		return ct
+	}
+
+	return "application/octet-stream"
+}
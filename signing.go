@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Files of the signed manifest. The sums are the SHA-256 sums of the
+// written files, in the sha256sum format, signed with Options.SigningKey.
+// The signature is base64 encoded like cosign signatures, so the sums can
+// be verified with cosign verify-blob, or with check-signature.
+const (
+	sumsFile   = ".vanitic-sums"
+	sigFile    = sumsFile + ".sig"
+	pubKeyFile = sumsFile + ".pub"
+)
+
+// readSigningKey reads an Ed25519 or ECDSA private key in PKCS #8 PEM form
+// (like from openssl genpkey) from a secret source (see readSecret).
+func readSigningKey(src string) (crypto.Signer, error) {
+	data, err := readSecret(src)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(data))
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("signing key: expected a PKCS #8 PEM private key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("signing key: unsupported %T key, expected Ed25519 or ECDSA", key)
+}
+
+func signData(key crypto.Signer, data []byte) ([]byte, error) {
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		return key.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+
+	sum := sha256.Sum256(data)
+
+	return key.Sign(rand.Reader, sum[:], crypto.SHA256)
+}
+
+func verifySignature(pub crypto.PublicKey, data, sig []byte) bool {
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(pub, data, sig)
+	case *ecdsa.PublicKey:
+		sum := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(pub, sum[:], sig)
+	}
+
+	return false
+}
+
+// signManifest writes the sums of the listed files of the output directory,
+// their signature and the public key, and adds them to the list. Verifiers
+// must get the public key from a trusted source, the published one is for
+// checking that it is the expected one.
+func signManifest(out DirOutput, names map[string]bool, key crypto.Signer) error {
+	var b bytes.Buffer
+
+	for _, name := range sortedKeys(names) {
+		if name == sumsFile || name == sigFile || name == pubKeyFile {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(out.Dir, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(&b, "%x  %s\n", sum, name)
+	}
+
+	sig, err := signData(key, b.Bytes())
+	if err != nil {
+		return err
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return err
+	}
+
+	out.manifest = nil
+
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{sumsFile, b.Bytes()},
+		{sigFile, []byte(base64.StdEncoding.EncodeToString(sig))},
+		{pubKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})},
+	} {
+		if err := out.WriteFile(f.name, f.data); err != nil {
+			return err
+		}
+
+		names[f.name] = true
+	}
+
+	return nil
+}
+
+// checkSignatureCmd verifies the signed manifest of a generated site, in a
+// directory or deployed at a base URL:
+//
+//	vanitic check-signature -key <public key> <directory or URL>
+//
+// Every file in the sums must have the same contents, files not in the sums
+// aren't checked.
+func checkSignatureCmd(args []string) error {
+	fset := flag.NewFlagSet("vanitic check-signature", flag.ExitOnError)
+
+	keyFile := fset.String(
+		"key", "",
+		"PEM file with the public key of the signing key.",
+	)
+
+	timeout := fset.Duration(
+		"timeout", 30*time.Second,
+		"Timeout of every request, for deployed sites.",
+	)
+
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	if *keyFile == "" || fset.NArg() != 1 {
+		return errors.New("check-signature: expected -key and a directory or URL")
+	}
+
+	data, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("check-signature: %s isn't a PEM file", *keyFile)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("check-signature: %w", err)
+	}
+
+	readFile := func(name string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(fset.Arg(0), filepath.FromSlash(name)))
+	}
+
+	if base := fset.Arg(0); strings.HasPrefix(base, "http://") || strings.HasPrefix(base, "https://") {
+		client := &http.Client{Timeout: *timeout}
+
+		readFile = func(name string) ([]byte, error) {
+			resp, err := client.Get(strings.TrimSuffix(base, "/") + "/" + name)
+			if err != nil {
+				return nil, err
+			}
+
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("%s: %s", name, resp.Status)
+			}
+
+			return io.ReadAll(resp.Body)
+		}
+	}
+
+	sums, err := readFile(sumsFile)
+	if err != nil {
+		return err
+	}
+
+	sig64, err := readFile(sigFile)
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig64)))
+	if err != nil || !verifySignature(pub, sums, sig) {
+		return errors.New("check-signature: invalid signature of " + sumsFile)
+	}
+
+	failed := 0
+
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		want, name, ok := strings.Cut(sc.Text(), "  ")
+		if !ok {
+			continue
+		}
+
+		data, err := readFile(name)
+		if err != nil {
+			log.Printf("FAIL %s: %v", name, err)
+			failed++
+
+			continue
+		}
+
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != want {
+			log.Printf("FAIL %s: modified", name)
+			failed++
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("check-signature: %d files don't match the signed sums", failed)
+	}
+
+	return nil
+}
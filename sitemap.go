@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// sitemapLimit is the maximum number of URLs of a sitemap file, sites with
+// more packages get a sitemap index.
+const sitemapLimit = 50000
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// writeSitemap writes the sitemap.xml of the site package pages, split into
+// sitemap-<n>.xml files listed by sitemap.xml when they don't fit into one.
+func writeSitemap(s *Site) error {
+	var urls []sitemapURL
+
+	for _, pkg := range s.Packages {
+		u := sitemapURL{Loc: s.BaseURL + pkg.URL()}
+		if pkg.Info != nil && pkg.Info.Latest != "" {
+			u.LastMod = pkg.Info.LatestTime.UTC().Format(time.RFC3339)
+		}
+
+		urls = append(urls, u)
+	}
+
+	if len(urls) <= sitemapLimit {
+		return writeXML(s, "sitemap.xml", sitemapURLSet{URLs: urls})
+	}
+
+	var index sitemapIndex
+
+	for i := 0; i < len(urls); i += sitemapLimit {
+		name := fmt.Sprintf("sitemap-%d.xml", i/sitemapLimit+1)
+		if err := writeXML(s, name, sitemapURLSet{URLs: urls[i:min(i+sitemapLimit, len(urls))]}); err != nil {
+			return err
+		}
+
+		index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: s.BaseURL + "/" + name})
+	}
+
+	return writeXML(s, "sitemap.xml", index)
+}
+
+func writeXML(s *Site, name string, v any) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return s.writeFile(name, append([]byte(xml.Header+string(data)), '\n'))
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"go/scanner"
+	"go/token"
+	"html"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sourceDir is the directory of the source pages in the package page
+// directory. The go command ignores directories starting with an
+// underscore, so it can't be a package.
+const sourceDir = "_src"
+
+// SourceFile is the data of a source page.
+type SourceFile struct {
+	Package Package
+	Name    string
+	Lines   []template.HTML // Highlighted lines.
+}
+
+// writeSourcePages writes a page for every Go file of the package in dir,
+// and returns their names.
+func writeSourcePages(out Output, dir string, pkg Package) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+
+		src, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		sf := SourceFile{Package: pkg, Name: name, Lines: highlightGo(src)}
+		dst := path.Join(pkg.ImportPath, sourceDir, name+".html")
+
+		if err := writeTemplate(out, dst, sourceTmpl, sf); err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// highlightGo returns the HTML lines of the Go source, with tokens wrapped
+// in spans by their class (kw, str, com, num). Spans never cross lines.
+func highlightGo(src []byte) []template.HTML {
+	var (
+		b    strings.Builder
+		s    scanner.Scanner
+		last int
+	)
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+
+		off := file.Offset(pos)
+		if off < last {
+			continue // Automatic semicolon.
+		}
+
+		text := lit
+		if text == "" || tok == token.SEMICOLON {
+			if tok == token.SEMICOLON && lit == "\n" {
+				continue
+			}
+
+			text = tok.String()
+		}
+
+		if off+len(text) > len(src) || string(src[off:off+len(text)]) != text {
+			continue
+		}
+
+		b.WriteString(html.EscapeString(string(src[last:off])))
+
+		class := ""
+		switch {
+		case tok.IsKeyword():
+			class = "kw"
+		case tok == token.STRING || tok == token.CHAR:
+			class = "str"
+		case tok == token.COMMENT:
+			class = "com"
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			class = "num"
+		}
+
+		for i, seg := range strings.Split(text, "\n") {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+
+			if class == "" || seg == "" {
+				b.WriteString(html.EscapeString(seg))
+				continue
+			}
+
+			b.WriteString(`<span class="` + class + `">` + html.EscapeString(seg) + `</span>`)
+		}
+
+		last = off + len(text)
+	}
+
+	b.WriteString(html.EscapeString(string(src[last:])))
+
+	var lines []template.HTML
+	for _, l := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		lines = append(lines, template.HTML(l))
+	}
+
+	return lines
+}
+
+var sourceTmpl = template.Must(template.New("source").Funcs(template.FuncMap{
+	"inc": func(i int) string { return strconv.Itoa(i + 1) },
+}).Parse(`<!DOCTYPE html>
+<html lang="{{ .Package.Locale.HTMLLang }}">
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="viewport" content="width=device-width, initial-scale=1"/>
+  <link rel="stylesheet" href="/style.css"/>
+  <title>{{ .Name }} - {{ .Package.DisplayPath }}</title>
+</head>
+<body>
+  <h1><a href="{{ .Package.URL }}">{{ .Package.DisplayPath }}</a>/{{ .Name }}</h1>
+  <pre class="source">
+{{- range $i, $l := .Lines }}
+<span id="L{{ inc $i }}"><a href="#L{{ inc $i }}">{{ inc $i }}</a> {{ $l }}</span>
+{{- end }}
+</pre>
+</body>
+</html>
+`))
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StateDB is the history of the generated modules across runs, stored as a
+// JSON file (see Options.State). Writes lock the file and merge with its
+// current content, so concurrent runs don't lose each other's records.
+type StateDB struct {
+	path string
+
+	mu      sync.Mutex
+	Modules map[string]*ModuleState `json:"modules"` // By module path.
+}
+
+// ModuleState is the history of a module.
+type ModuleState struct {
+	Repo      string    `json:"repo"`
+	FirstSeen time.Time `json:"first_seen"`
+
+	// Releases in the order they were first generated.
+	Releases []StateRelease `json:"releases,omitempty"`
+
+	LastSuccess   *time.Time `json:"last_success,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+type StateRelease struct {
+	Version string    `json:"version"`
+	Seen    time.Time `json:"seen"`
+}
+
+func openStateDB(path string) (*StateDB, error) {
+	db := &StateDB{path: path}
+	if err := db.read(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *StateDB) read() error {
+	db.Modules = make(map[string]*ModuleState)
+
+	data, err := os.ReadFile(db.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, db); err != nil {
+		return fmt.Errorf("state %s: %w", db.path, err)
+	}
+
+	if db.Modules == nil {
+		db.Modules = make(map[string]*ModuleState)
+	}
+
+	return nil
+}
+
+// FirstSeen returns when the module was first generated, the zero time if
+// it is new.
+func (db *StateDB) FirstSeen(modPath string) time.Time {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if m, ok := db.Modules[modPath]; ok {
+		return m.FirstSeen
+	}
+
+	return time.Time{}
+}
+
+// Record adds the results of the generation to the history and writes it.
+// Failed repositories without a module path are recorded into the modules
+// of their repository.
+func (db *StateDB) Record(sum *Summary) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(db.path), 0755); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(db.path + ".lock")
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	if err := db.read(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	for _, rs := range sum.Repos {
+		if rs.Skipped {
+			continue
+		}
+
+		mods := []*ModuleState{db.Modules[rs.Module]}
+		if rs.Module == "" {
+			mods = nil
+
+			for _, m := range db.Modules {
+				if m.Repo == rs.URL {
+					mods = append(mods, m)
+				}
+			}
+		} else if mods[0] == nil {
+			mods[0] = &ModuleState{Repo: rs.URL, FirstSeen: now}
+			db.Modules[rs.Module] = mods[0]
+		}
+
+		for _, m := range mods {
+			m.Repo = rs.URL
+
+			if rs.Error != "" {
+				m.LastError, m.LastErrorTime = rs.Error, &now
+				continue
+			}
+
+			m.LastSuccess, m.LastError, m.LastErrorTime = &now, "", nil
+
+			if rs.Version != "" && (len(m.Releases) == 0 || m.Releases[len(m.Releases)-1].Version != rs.Version) {
+				m.Releases = append(m.Releases, StateRelease{Version: rs.Version, Seen: now})
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := db.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, db.path)
+}
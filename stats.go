@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stats are the request counts of the modules, from the served requests
+// (see ServeOptions.StatsPath) or from access log files (see statsCmd).
+type Stats struct {
+	mu sync.Mutex
+
+	Since   time.Time               `json:"since"`
+	Modules map[string]*ModuleStats `json:"modules"` // By module path.
+}
+
+// ModuleStats counts the successful requests of a module: go-get lookups
+// by the go command, page views, and module proxy zip downloads.
+type ModuleStats struct {
+	GoGet     int64            `json:"go_get"`
+	Views     int64            `json:"views"`
+	Downloads map[string]int64 `json:"downloads,omitempty"` // By version.
+}
+
+// Total returns the number of module proxy zip downloads.
+func (ms *ModuleStats) Total() int64 {
+	var n int64
+	for _, d := range ms.Downloads {
+		n += d
+	}
+
+	return n
+}
+
+func newStats(since time.Time) *Stats {
+	return &Stats{Since: since, Modules: make(map[string]*ModuleStats)}
+}
+
+// record counts the request of the access entry. module returns the module
+// of an import path, whose host may be empty if the entry has no host.
+// Requests under proxyPrefix are module proxy requests.
+func (s *Stats) record(e accessEntry, proxyPrefix string, module func(host, p string) string) {
+	if e.Method != http.MethodGet || e.Status != http.StatusOK && e.Status != http.StatusNotModified {
+		return
+	}
+
+	p, _, _ := strings.Cut(e.URI, "?")
+
+	var modPath, version string
+
+	if rest, ok := strings.CutPrefix(p, proxyPrefix+"/"); ok && proxyPrefix != "" {
+		escMod, file, ok := strings.Cut(rest, "/@v/")
+		if !ok || path.Ext(file) != ".zip" {
+			return
+		}
+
+		modPath, _ = unescapeModulePath(escMod)
+		version, _ = unescapeModulePath(strings.TrimSuffix(file, ".zip"))
+
+		if modPath == "" || version == "" || module(hostOf(modPath), stripHost(modPath)) != modPath {
+			return
+		}
+	} else {
+		if ext := path.Ext(p); ext != "" && ext != ".html" {
+			return // Assets.
+		}
+
+		p = strings.TrimSuffix(strings.TrimSuffix(strings.Trim(p, "/"), ".html"), "/index")
+		if modPath = module(e.Host, p); modPath == "" {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms := s.Modules[modPath]
+	if ms == nil {
+		ms = &ModuleStats{}
+		s.Modules[modPath] = ms
+	}
+
+	switch {
+	case version != "":
+		if ms.Downloads == nil {
+			ms.Downloads = make(map[string]int64)
+		}
+
+		ms.Downloads[version]++
+	case e.GoGet:
+		ms.GoGet++
+	default:
+		ms.Views++
+	}
+}
+
+func hostOf(p string) string {
+	host, _, _ := strings.Cut(p, "/")
+	return host
+}
+
+// moduleResolver returns the module of import paths among the given module
+// paths, the longest one wins. Import paths without host are matched
+// against the module paths without host.
+func moduleResolver(mods []string) func(host, p string) string {
+	return func(host, p string) string {
+		importPath := p
+		if host != "" {
+			importPath = path.Join(strings.ToLower(host), p)
+		}
+
+		var match string
+
+		for _, m := range mods {
+			if host == "" {
+				m2 := stripHost(m)
+				if (p == m2 || strings.HasPrefix(p, m2+"/")) && len(m) > len(match) {
+					match = m
+				}
+
+				continue
+			}
+
+			if (importPath == m || strings.HasPrefix(importPath, m+"/")) && len(m) > len(match) {
+				match = m
+			}
+		}
+
+		return match
+	}
+}
+
+// StatsRow is a module in the stats page.
+type StatsRow struct {
+	Module string
+	*ModuleStats
+}
+
+// rows returns the modules sorted by go-get lookups, then by downloads and
+// views.
+func (s *Stats) rows() []StatsRow {
+	var rows []StatsRow
+	for m, ms := range s.Modules {
+		rows = append(rows, StatsRow{m, ms})
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.GoGet != b.GoGet {
+			return a.GoGet > b.GoGet
+		}
+
+		if a.Total() != b.Total() {
+			return a.Total() > b.Total()
+		}
+
+		if a.Views != b.Views {
+			return a.Views > b.Views
+		}
+
+		return a.Module < b.Module
+	})
+
+	return rows
+}
+
+func (s *Stats) writeJSON(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+func (s *Stats) writeHTML(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return statsTmpl.Execute(w, struct {
+		Since time.Time
+		Rows  []StatsRow
+	}{s.Since, s.rows()})
+}
+
+var statsTmpl = template.Must(template.New("stats").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+  <meta name="viewport" content="width=device-width, initial-scale=1"/>
+  <meta name="robots" content="noindex"/>
+  <title>Module stats</title>
+  <style>
+    body { font-family: sans-serif; margin: 2em; }
+    th, td { padding: 0.25em 1em; text-align: right; }
+    th:first-child, td:first-child { text-align: left; }
+  </style>
+</head>
+<body>
+  <h1>Module stats</h1>
+  <p>Successful requests since {{ .Since.Format "2006-01-02 15:04 MST" }}.</p>
+  <table>
+    <tr><th>Module</th><th>go get</th><th>Downloads</th><th>Views</th></tr>
+  {{- range .Rows }}
+    <tr><td>{{ .Module }}</td><td>{{ .GoGet }}</td><td>{{ .Total }}</td><td>{{ .Views }}</td></tr>
+  {{- end }}
+  </table>
+</body>
+</html>
+`))
+
+// statsHandler serves the stats as HTML, or as JSON for paths with a .json
+// suffix. Like the admin API, requests must be authenticated with the admin
+// token, as a bearer token or as the password of basic authentication for
+// browsers.
+type statsHandler struct {
+	stats *Stats
+	token []byte
+}
+
+func (h *statsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		_, token, _ = r.BasicAuth()
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), h.token) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="vanitic"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if strings.HasSuffix(r.URL.Path, ".json") {
+		w.Header().Set("Content-Type", "application/json")
+		h.stats.writeJSON(w)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	h.stats.writeHTML(w)
+}
+
+// statsCmd aggregates the module stats from access log files written with
+// -access-log, in any format. Modules are the ones of the generated site in
+// the output directory.
+func statsCmd(args []string) error {
+	fset := flag.NewFlagSet("vanitic stats", flag.ExitOnError)
+
+	out := fset.String(
+		"out", "pkg",
+		"Directory of the generated site.",
+	)
+
+	proxyPath := fset.String(
+		"proxy", "",
+		"Path where the module proxy protocol was served (see vanitic serve -proxy).",
+	)
+
+	htmlFile := fset.String(
+		"html", "",
+		"Write the stats page into the given file.",
+	)
+
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	if fset.NArg() == 0 {
+		return errors.New("stats: expected access log files")
+	}
+
+	snap, err := takeSnapshot(*out)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+
+	var mods []string
+
+	for _, pkg := range snap.Packages {
+		if !seen[pkg.Module] {
+			seen[pkg.Module] = true
+			mods = append(mods, pkg.Module)
+		}
+	}
+
+	stats := newStats(time.Time{})
+	module := moduleResolver(mods)
+	prefix := strings.TrimSuffix("/"+strings.Trim(*proxyPath, "/"), "/")
+
+	for _, name := range fset.Args() {
+		if err := readAccessLog(name, func(e accessEntry) {
+			if stats.Since.IsZero() || e.Time.Before(stats.Since) {
+				stats.Since = e.Time
+			}
+
+			stats.record(e, prefix, module)
+		}); err != nil {
+			return fmt.Errorf("stats: %w", err)
+		}
+	}
+
+	if *htmlFile != "" {
+		f, err := os.Create(*htmlFile)
+		if err != nil {
+			return err
+		}
+
+		if err := stats.writeHTML(f); err != nil {
+			f.Close()
+			return err
+		}
+
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	return stats.writeJSON(os.Stdout)
+}
+
+// Quoted fields of the common format are Go quoted strings.
+var commonLogRe = regexp.MustCompile(`^(\S+) - - \[([^\]]+)\] "(\S+) (\S+) (\S+)" (\d+) (\d+) "(?:[^"\\]|\\.)*" "(?:[^"\\]|\\.)*" [\d.]+ go-get=(true|false)$`)
+
+// readAccessLog calls fn with every entry of the access log file, JSON or
+// common format lines. Malformed lines are skipped.
+func readAccessLog(name string, fn func(accessEntry)) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+
+	for sc.Scan() {
+		line := sc.Text()
+
+		var e accessEntry
+
+		if strings.HasPrefix(line, "{") {
+			if json.Unmarshal([]byte(line), &e) != nil {
+				continue
+			}
+		} else {
+			m := commonLogRe.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+
+			e.Time, _ = time.Parse("02/Jan/2006:15:04:05 -0700", m[2])
+			e.Method, e.URI, e.Proto = m[3], m[4], m[5]
+			e.Status, _ = strconv.Atoi(m[6])
+			e.GoGet = m[8] == "true"
+		}
+
+		fn(e)
+	}
+
+	return sc.Err()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"regexp"
+	"text/template"
+)
+
+// styleSheet is the stylesheet of the HTML pages, written at the root of
+// every site.
+const styleSheet = "style.css"
+
+var accentRe = regexp.MustCompile(`^(#[0-9A-Fa-f]{3,8}|[A-Za-z]+)$`)
+
+var styleTmpl = template.Must(template.New("style").Parse(`:root {
+  color-scheme: light dark;
+  --fg: #202224;
+  --bg: #fff;
+  --muted: #6e7072;
+  --code-bg: #f2f4f6;
+  --accent: {{ . }};
+}
+
+@media (prefers-color-scheme: dark) {
+  :root {
+    --fg: #e8eaec;
+    --bg: #1b1d1f;
+    --muted: #9a9c9e;
+    --code-bg: #26292c;
+  }
+}
+
+* { box-sizing: border-box; }
+
+body {
+  max-width: 60rem;
+  margin: 0 auto;
+  padding: 1rem;
+  font: 1rem/1.5 system-ui, -apple-system, "Segoe UI", Roboto, sans-serif;
+  color: var(--fg);
+  background: var(--bg);
+  overflow-wrap: break-word;
+}
+
+a { color: var(--accent); }
+h1 { font-size: 1.75rem; }
+h1, h2, h3, h4 { line-height: 1.25; }
+img { max-width: 100%; }
+
+pre, code { font-family: ui-monospace, Menlo, Consolas, monospace; font-size: .9em; }
+pre { padding: .75rem; overflow-x: auto; background: var(--code-bg); border-radius: 4px; }
+code { background: var(--code-bg); padding: .1em .25em; border-radius: 3px; }
+pre code { padding: 0; }
+
+blockquote { margin-left: 0; padding-left: 1rem; border-left: 3px solid var(--muted); color: var(--muted); }
+dl.meta dt { font-weight: bold; }
+dl.meta dd { margin: 0 0 .5rem; }
+.version, .go-version, .refreshed, .breadcrumbs { color: var(--muted); }
+.stale { padding: .5rem .75rem; border-left: 3px solid #c62828; background: var(--code-bg); }
+.badges img { vertical-align: middle; }
+
+.badge { font-size: .75em; padding: .1em .5em; border-radius: 1em; color: #fff; background: var(--muted); vertical-align: middle; }
+.badge-vulns { background: #c62828; }
+.badge-ok { background: #2e7d32; }
+.pager { display: flex; gap: 1rem; color: var(--muted); }
+
+.source a { color: var(--muted); text-decoration: none; display: inline-block; min-width: 3em; text-align: right; user-select: none; }
+.source span:target { background: color-mix(in srgb, var(--accent) 25%, transparent); }
+.kw { color: #a626a4; }
+.str { color: #50a14f; }
+.com { color: var(--muted); font-style: italic; }
+.num { color: #986801; }
+
+@media (prefers-color-scheme: dark) {
+  .kw { color: #c678dd; }
+  .str { color: #98c379; }
+  .num { color: #d19a66; }
+}
+
+@media (max-width: 40rem) {
+  body { padding: .5rem; }
+  h1 { font-size: 1.4rem; }
+}
+`))
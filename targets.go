@@ -0,0 +1,245 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Site is the set of generated pages of a host, the directory to publish at
+// the host with static hosting services.
+type Site struct {
+	Host     string
+	Packages []Package // Sorted by import path.
+
+	// Directory of the site, if the output is an OS directory.
+	Dir string
+
+	BaseURL string // https:// and the host, if not set with -base-url.
+
+	// Packages per page of the listings, 0 for no pagination.
+	PageSize int
+
+	DocsRedirect bool
+
+	// Package pages are HTML files named after the package, see layouts.
+	Flat bool
+
+	Locale *Locale
+
+	out Output
+}
+
+// DisplayHost returns the host in Unicode form.
+func (s *Site) DisplayHost() string {
+	return hostToUnicode(s.Host)
+}
+
+// Path returns the URL path of the given package in the site.
+func (s *Site) Path(pkg Package) string {
+	return "/" + strings.TrimPrefix(strings.TrimPrefix(pkg.ImportPath, s.Host), "/")
+}
+
+func (s *Site) writeFile(name string, data []byte) error {
+	return writeFile(s.out, path.Join(s.Host, name), data)
+}
+
+func (s *Site) writeTemplate(name string, tmpl executor, data any) error {
+	return writeTemplate(s.out, path.Join(s.Host, name), tmpl, data)
+}
+
+// targets write configuration files for static hosting services into the
+// site directory.
+var targets = map[string]func(s *Site) error{
+	"caddy":      writeCaddy,
+	"cloudflare": writeCloudflare,
+	"gh-pages":   writeGHPages,
+	"htaccess":   writeHtaccess,
+	"netlify":    writeNetlify,
+	"vercel":     writeVercel,
+}
+
+// Headers set on every response by the static hosting targets.
+var securityHeaders = [][2]string{
+	{"X-Content-Type-Options", "nosniff"},
+	{"Referrer-Policy", "strict-origin-when-cross-origin"},
+	{"Content-Security-Policy", "default-src 'none'; style-src 'self'; img-src https:"}, // Badges and README images.
+}
+
+const (
+	pagesCacheControl  = "public, max-age=300"
+	assetsCacheControl = "public, max-age=86400"
+)
+
+// writeSites writes the data files of the output format and the
+// configuration files of the enabled targets for every site.
+func (g *Generator) writeSites(st *genState, out Output) error {
+	sites := g.sites(st, out)
+
+	if _, ok := out.(DirOutput); ok && g.opts.Layout != "tree" && len(sites) > 1 {
+		return fmt.Errorf("the %s layout needs a single host, found %d", g.opts.Layout, len(sites))
+	}
+
+	if data := formats[g.opts.Format].data; data != nil {
+		var pkgs []Package
+		for _, s := range sites {
+			pkgs = append(pkgs, s.Packages...)
+		}
+
+		if err := data(out, pkgs); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range sites {
+		if g.opts.Format == "html" {
+			if err := s.writeTemplate(styleSheet, styleTmpl, g.opts.Accent); err != nil {
+				return err
+			}
+		}
+
+		if g.opts.PrefixPages && g.opts.Format == "html" {
+			if err := writePrefixPages(s, st.pages); err != nil {
+				return err
+			}
+		}
+
+		if g.opts.Sitemap && g.opts.Format == "html" {
+			if err := writeSitemap(s); err != nil {
+				return err
+			}
+		}
+
+		if g.opts.Format == "html" {
+			if err := writeWellKnown(s, st.cfg); err != nil {
+				return err
+			}
+		}
+
+		if g.opts.Inventory != nil {
+			if err := g.writeInventories(s); err != nil {
+				return err
+			}
+		}
+
+		if g.opts.Static != "" {
+			if err := s.writeStatic(g.opts.Static); err != nil {
+				return err
+			}
+		}
+
+		if g.opts.DepsGraph {
+			if err := writeDepsGraph(s); err != nil {
+				return err
+			}
+		}
+
+		if g.opts.ImportGraph {
+			if err := writeImportGraph(s); err != nil {
+				return err
+			}
+		}
+
+		for _, t := range g.opts.Targets {
+			if err := targets[t](s); err != nil {
+				return err
+			}
+		}
+
+		if g.opts.CheckPages && g.opts.Format == "html" {
+			if err := s.validate(st.pages); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validate checks the written pages of the site packages, see
+// validatePage. Outputs that can't be read back aren't checked.
+func (s *Site) validate(pages *pageTemplates) error {
+	r, ok := s.out.(interface {
+		ReadFile(name string) ([]byte, error)
+	})
+	if !ok {
+		return nil
+	}
+
+	for _, pkg := range s.Packages {
+		for _, name := range pages.names(pkg) {
+			data, err := r.ReadFile(name)
+			if err != nil {
+				return err
+			}
+
+			if err := validatePage(name, pkg.ImportPath, data); err != nil {
+				return fmt.Errorf("validation: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sites returns the sites of the configured repositories modules.
+func (g *Generator) sites(st *genState, out Output) []*Site {
+	configured := make(map[string]bool)
+	for _, r := range st.cfg.Repos {
+		configured[r.key()] = true
+	}
+
+	g.modsMu.RLock()
+	defer g.modsMu.RUnlock()
+
+	sites := make(map[string]*Site)
+
+	for _, m := range g.modules {
+		if !configured[m.Repo.key()] {
+			continue
+		}
+
+		host, _, _ := strings.Cut(m.Path, "/")
+
+		s, ok := sites[host]
+		if !ok {
+			s = &Site{
+				Host:         host,
+				BaseURL:      cmp.Or(g.opts.BaseURL, "https://"+host),
+				PageSize:     g.opts.PageSize,
+				DocsRedirect: g.opts.DocsRedirect,
+				Flat:         g.opts.Layout == "flat",
+				Locale:       st.locale,
+				out:          out,
+			}
+
+			if dir, ok := out.(DirOutput); ok {
+				s.Dir = dir.Dir
+				if g.opts.Layout == "tree" {
+					s.Dir = filepath.Join(dir.Dir, host)
+				}
+			}
+
+			sites[host] = s
+		}
+
+		s.Packages = append(s.Packages, m.Packages...)
+	}
+
+	var list []*Site
+
+	for _, host := range sortedKeys(sites) {
+		s := sites[host]
+
+		sort.Slice(s.Packages, func(i, j int) bool {
+			return s.Packages[i].ImportPath < s.Packages[j].ImportPath
+		})
+
+		list = append(list, s)
+	}
+
+	return list
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Team owns a configuration fragment, from the team directive:
+//
+//	team: payments teams/payments.vanitic namespace=go.example.com/payments token=env:PAYMENTS_TOKEN
+//
+// Fragments have the configuration file format, their paths are relative
+// to the configuration file. They may only have repositories, discovery
+// directives and descriptions, and the modules of their repositories must
+// be under the team namespace, which no other repository may use. The token
+// is a secret source (see readSecret) of the admin API token of the team,
+// which regenerates its repositories only.
+//
+// Fragments may only use network repository URLs, and secret sources under
+// the secrets prefix of the team, like secrets=env:PAYMENTS_ for
+// environment variables starting with PAYMENTS_, or secrets=file:/run/payments
+// for files in that directory. Without it, fragments can't use secrets.
+// Policies are set by the main configuration only, fragment repositories
+// can't have policy.<name>=mode attributes.
+type Team struct {
+	Name      string
+	File      string
+	Namespace string
+	Token     string
+	Secrets   string
+}
+
+func parseTeam(args []string) (*Team, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("invalid team, expected a name and a configuration file")
+	}
+
+	t := &Team{Name: args[0], File: args[1]}
+	if !validMetaKey(t.Name) {
+		return nil, fmt.Errorf("invalid team name %q", t.Name)
+	}
+
+	err := parseAttrs(args[2:], func(key, value string) error {
+		switch key {
+		case "namespace":
+			host, rest, _ := strings.Cut(strings.Trim(value, "/"), "/")
+
+			host, err := hostToASCII(host)
+			if err != nil {
+				return err
+			}
+
+			if rest == "" {
+				return fmt.Errorf("team namespace %q is a whole host", value)
+			}
+
+			t.Namespace = host + "/" + rest
+		case "token":
+			if _, _, ok := strings.Cut(value, ":"); !ok {
+				return fmt.Errorf("invalid secret source %q", value)
+			}
+
+			t.Token = value
+		case "secrets":
+			kind, ref, _ := strings.Cut(value, ":")
+			if kind != "env" && kind != "file" || ref == "" {
+				return fmt.Errorf("invalid secrets prefix %q", value)
+			}
+
+			t.Secrets = value
+		default:
+			return fmt.Errorf("unknown attribute %q", key)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Namespace == "" {
+		return nil, fmt.Errorf("team %s without namespace", t.Name)
+	}
+
+	return t, nil
+}
+
+// readTeams reads the fragments of the teams into the configuration, dir is
+// the directory of the configuration file.
+func (cfg *Config) readTeams(dir string) error {
+	for i, t := range cfg.Teams {
+		for _, o := range cfg.Teams[:i] {
+			if o.Name == t.Name {
+				return fmt.Errorf("duplicated team %s", t.Name)
+			}
+
+			if underPath(t.Namespace, o.Namespace) || underPath(o.Namespace, t.Namespace) {
+				return fmt.Errorf("teams %s and %s have overlapping namespaces", o.Name, t.Name)
+			}
+		}
+
+		name := t.path(dir)
+		frag, err := parseConfigFile(name)
+		if err != nil {
+			return fmt.Errorf("team %s: %w", t.Name, err)
+		}
+
+		for _, d := range []struct {
+			name string
+			set  bool
+		}{
+			{"env", frag.Env != nil},
+			{"mirrors", frag.Mirrors != nil},
+			{"security", frag.Security != nil},
+			{"well-known", frag.WellKnown != nil},
+			{"team", frag.Teams != nil},
+			{"policy", frag.Policies != nil},
+			{"rewrite", frag.Rewrites != nil},
+		} {
+			if d.set {
+				return fmt.Errorf("team %s: %s: the %s directive is only allowed in the main configuration", t.Name, name, d.name)
+			}
+		}
+
+		for importPath, d := range frag.Descriptions {
+			if !underPath(importPath, t.Namespace) {
+				return fmt.Errorf("team %s: description of %s, outside of %s", t.Name, importPath, t.Namespace)
+			}
+
+			if cfg.Descriptions == nil {
+				cfg.Descriptions = make(map[string]string)
+				cfg.RichDescriptions = make(map[string]template.HTML)
+			}
+
+			cfg.Descriptions[importPath] = d
+			cfg.RichDescriptions[importPath] = frag.RichDescriptions[importPath]
+		}
+
+		for _, r := range frag.Repos {
+			if !networkURL(r.URL) {
+				return fmt.Errorf("team %s: %s: %s isn't a network repository URL", t.Name, r.pos, r.URL)
+			}
+
+			if r.Token != "" && !t.allowsSecret(r.Token) {
+				return fmt.Errorf("team %s: %s: secret %s outside of the team secrets", t.Name, r.pos, r.Token)
+			}
+
+			// Policies are set by the main configuration, teams can't turn
+			// them off.
+			if r.Policies != nil {
+				return fmt.Errorf("team %s: %s: policy attributes are only allowed in the main configuration", t.Name, r.pos)
+			}
+
+			r.Team = t.Name
+			cfg.Repos = append(cfg.Repos, r)
+		}
+
+		for _, d := range frag.Discoveries {
+			if d.Repo.Token != "" && !t.allowsSecret(d.Repo.Token) {
+				return fmt.Errorf("team %s: %s %s: secret %s outside of the team secrets", t.Name, d.Kind, d.Name, d.Repo.Token)
+			}
+
+			if d.Repo.Policies != nil {
+				return fmt.Errorf("team %s: %s %s: policy attributes are only allowed in the main configuration", t.Name, d.Kind, d.Name)
+			}
+
+			d.Repo.Team = t.Name
+			cfg.Discoveries = append(cfg.Discoveries, d)
+		}
+	}
+
+	return nil
+}
+
+// allowsSecret reports if the secret source is under the secrets prefix of
+// the team.
+func (t *Team) allowsSecret(src string) bool {
+	kind, ref, _ := strings.Cut(src, ":")
+	pkind, prefix, _ := strings.Cut(t.Secrets, ":")
+
+	if t.Secrets == "" || kind != pkind {
+		return false
+	}
+
+	if kind == "file" {
+		dir := filepath.Clean(prefix)
+		return filepath.IsAbs(ref) && strings.HasPrefix(filepath.Clean(ref), dir+string(filepath.Separator))
+	}
+
+	return strings.HasPrefix(ref, prefix)
+}
+
+// path returns the path of the team fragment, dir is the directory of the
+// configuration file.
+func (t *Team) path(dir string) string {
+	if filepath.IsAbs(t.File) {
+		return t.File
+	}
+
+	return filepath.Join(dir, t.File)
+}
+
+// checkNamespace returns an error if the module of the repository is
+// outside of the namespace of its team, or inside the namespace of another
+// team.
+func (cfg *Config) checkNamespace(r *Repo, modPath string) error {
+	for _, t := range cfg.Teams {
+		switch in := underPath(modPath, t.Namespace); {
+		case t.Name == r.Team && !in:
+			return fmt.Errorf("module %s isn't under the %s namespace of team %s", modPath, t.Namespace, t.Name)
+		case t.Name != r.Team && in:
+			return fmt.Errorf("module %s is under the %s namespace of team %s", modPath, t.Namespace, t.Name)
+		}
+	}
+
+	return nil
+}
+
+// teamOf returns the team with the given admin API token, if any.
+func (cfg *Config) teamOf(token []byte) *Team {
+	for _, t := range cfg.Teams {
+		if t.Token == "" {
+			continue
+		}
+
+		secret, err := readSecret(t.Token)
+		if err == nil && secret != "" && subtle.ConstantTimeCompare(token, []byte(secret)) == 1 {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// teamRepos returns the repositories of the given teams.
+func teamRepos(repos []*Repo, teams []string) []*Repo {
+	var selected []*Repo
+
+	for _, r := range repos {
+		if slices.Contains(teams, r.Team) {
+			selected = append(selected, r)
+		}
+	}
+
+	return selected
+}
+
+// underPath reports if p is prefix or under it.
+func underPath(p, prefix string) bool {
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
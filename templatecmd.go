@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// templateCmd runs the template subcommands:
+//
+//	vanitic template check [flags]
+//	vanitic template preview [flags] <import path>
+//
+// check renders the page templates (see Options.Templates) with a sample
+// package that has every field set and validates their meta tags. preview
+// generates the repositories of the import path in memory and writes its
+// page to the standard output, or opens it in a browser with -open.
+func templateCmd(args []string) error {
+	if len(args) == 0 {
+		return errors.New("template: expected check or preview")
+	}
+
+	switch args[0] {
+	case "check":
+		return templateCheckCmd(args[1:])
+	case "preview":
+		return templatePreviewCmd(args[1:])
+	}
+
+	return fmt.Errorf("template: unknown subcommand %q", args[0])
+}
+
+func templateCheckCmd(args []string) error {
+	opts := DefaultOptions()
+	fset := opts.FlagSet("vanitic template check")
+
+	if err := opts.Parse(fset, args); err != nil {
+		return err
+	}
+
+	pages, err := readTemplates(opts)
+	if err != nil {
+		return err
+	}
+
+	locale, err := readLocale(opts.Lang, opts.Messages)
+	if err != nil {
+		return err
+	}
+
+	mem := NewMemFS()
+
+	for _, pkg := range samplePackages(locale) {
+		if err := pages.write(mem, pkg); err != nil {
+			return fmt.Errorf("template: %s: %w", pkg.ImportPath, err)
+		}
+
+		for _, name := range pages.names(pkg) {
+			data, err := mem.ReadFile(name)
+			if err != nil {
+				return err
+			}
+
+			if err := validatePage(name, pkg.ImportPath, data); err != nil {
+				return fmt.Errorf("template: %w", err)
+			}
+		}
+	}
+
+	log.Print("template: ok")
+
+	return nil
+}
+
+// samplePackages returns a module and one of its packages with every field
+// set, so templates run all their branches.
+func samplePackages(locale *Locale) []Package {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	info := &ModuleInfo{
+		README:     "<p>Example is an example module.</p>",
+		Latest:     "v1.2.3",
+		LatestTag:  "v1.2.3",
+		LatestTime: now,
+		Branch:     "master",
+		Refreshed:  now.Add(-72 * time.Hour),
+		Stale:      true,
+		License:    "MIT",
+		FirstSeen:  now,
+		Packages: []PackageRef{
+			{ImportPath: "go.example.com/example", Description: "Package example is an example."},
+			{ImportPath: "go.example.com/example/sub", Description: "Package sub is a subpackage.", RichDescription: "Package <b>sub</b> is a subpackage."},
+		},
+		GoMod: &GoModFile{
+			Module:    "go.example.com/example",
+			Go:        "1.22",
+			Toolchain: "go1.22.4",
+			Require: []ModRequire{
+				{Path: "go.example.com/dep", Version: "v0.1.0", URL: "https://go.example.com/dep"},
+				{Path: "example.org/indirect", Version: "v1.0.0", Indirect: true, URL: "https://pkg.go.dev/example.org/indirect@v1.0.0"},
+			},
+		},
+		Changelog:      true,
+		Contributors:   []Contributor{{Name: "Gopher", Email: "g…@example.com", Commits: 42}},
+		Badges:         []Badge{{Name: "ci", Image: "https://example.com/badge.svg", Link: "https://example.com/ci"}},
+		Meta:           map[string]string{"maintainer": "Gopher"},
+		VulnChecked:    true,
+		Vulns:          []Vuln{{ID: "GO-2024-0001", Summary: "Example vulnerability.", Called: true}},
+		PublishChecked: true,
+		Unpublished:    []string{"v1.2.4"},
+		SourcePages:    true,
+	}
+
+	decl := DocDecl{Name: "Example", Decl: "func Example() error", Doc: "<p>Example does it.</p>"}
+
+	mod := Package{
+		Source:      "https://github.com/example/example",
+		Subdir:      "go",
+		Module:      "go.example.com/example",
+		ImportPath:  "go.example.com/example",
+		Description: "Package example is an example.",
+		Info:        info,
+		Doc: &PackageDoc{
+			Doc:    "<p>Package example is an example.</p>",
+			Consts: []DocDecl{decl},
+			Vars:   []DocDecl{decl},
+			Funcs:  []DocDecl{decl},
+			Types: []DocType{{
+				DocDecl: decl,
+				Consts:  []DocDecl{decl},
+				Vars:    []DocDecl{decl},
+				Funcs:   []DocDecl{decl},
+				Methods: []DocDecl{decl},
+			}},
+			Examples: []DocExample{{Name: "Example", ID: "example-Example", Doc: "<p>Example.</p>", Code: "Example()", Output: "ok"}},
+			Symbols:  []DocSymbol{{Kind: "func", Name: "Example", Anchor: "Example"}},
+		},
+		Files: []string{"example.go"},
+		Site: &SiteInfo{
+			Title:     "go.example.com",
+			BaseURL:   "https://go.example.com",
+			Generated: now,
+		},
+		Locale: locale,
+	}
+
+	sub := mod
+	sub.ImportPath = "go.example.com/example/sub"
+	sub.Description, sub.RichDescription = parseDescription("html:Package <b>sub</b> is a subpackage.")
+	sub.Breadcrumbs = breadcrumbs(mod.Module, sub.ImportPath, map[string]bool{mod.Module: true}, false)
+
+	return []Package{mod, sub}
+}
+
+func templatePreviewCmd(args []string) error {
+	opts := DefaultOptions()
+	fset := opts.FlagSet("vanitic template preview")
+
+	open := fset.Bool(
+		"open", false,
+		"Open the page in a browser instead of writing it to the standard output.",
+	)
+
+	if err := opts.Parse(fset, args); err != nil {
+		return err
+	}
+
+	if fset.NArg() != 1 {
+		return errors.New("template preview: expected an import path")
+	}
+
+	importPath := strings.TrimSuffix(fset.Arg(0), "/")
+
+	g, err := NewGenerator(opts)
+	if err != nil {
+		return err
+	}
+
+	mem := NewMemFS()
+	if err := g.previewRepos(mem, importPath); err != nil {
+		return err
+	}
+
+	data, err := mem.ReadFile(path.Join(importPath, "index.html"))
+	if err != nil {
+		return fmt.Errorf("template preview: %s isn't a generated package", importPath)
+	}
+
+	if !*open {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	// The site style sheet is inlined, since the page isn't served from the
+	// site root.
+	if style, err := mem.ReadFile(path.Join(strings.SplitN(importPath, "/", 2)[0], styleSheet)); err == nil {
+		data = []byte(strings.Replace(string(data), `<link rel="stylesheet" href="/style.css"/>`, "<style>\n"+string(style)+"</style>", 1))
+	}
+
+	f, err := os.CreateTemp("", "vanitic-preview-*.html")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return openBrowser(f.Name())
+}
+
+// previewRepos generates into out the repositories whose checkouts in the
+// source directory contain the import path, or every repository if none
+// does (like before the first generation). Package pages are written with
+// the site files.
+func (g *Generator) previewRepos(out Output, importPath string) error {
+	st := g.state.Load()
+
+	var repos []*Repo
+
+	for _, r := range st.cfg.Repos {
+		dir := filepath.Join(g.repoDir(r), filepath.FromSlash(r.Subdir))
+
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			continue
+		}
+
+		if m := parseGoMod(data).Module; m != "" && (importPath == m || strings.HasPrefix(importPath, m+"/")) {
+			repos = append(repos, r)
+		}
+	}
+
+	if repos == nil {
+		_, err := g.GenerateTo(out)
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, r := range repos {
+		if _, err := g.genRepo(st, out, r); err != nil {
+			return err
+		}
+	}
+
+	if g.opts.Format == "html" {
+		host, _, _ := strings.Cut(importPath, "/")
+		return writeTemplate(out, path.Join(host, styleSheet), styleTmpl, g.opts.Accent)
+	}
+
+	return nil
+}
+
+func openBrowser(name string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", name)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", name)
+	default:
+		cmd = exec.Command("xdg-open", name)
+	}
+
+	cmd.Stderr = os.Stderr
+
+	return cmd.Start()
+}
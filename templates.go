@@ -0,0 +1,154 @@
+package main
+
+import (
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pageTemplates are the templates of the HTML package pages.
+type pageTemplates struct {
+	page  *template.Template
+	goGet *template.Template
+
+	variants []string // See pageVariants.
+	flat     bool     // Only index.html, see layouts.
+}
+
+var defaultPages = &pageTemplates{page: goPkgTmpl, goGet: goGetTmpl}
+
+// pageVariants are the extensions of the extra pages written next to the
+// package directories (like go.example.com/pkg.html), for static hosts that
+// resolve extension-less paths to these files instead of directory indexes.
+var pageVariants = map[string]string{
+	"html": ".html",
+	"htm":  ".htm",
+}
+
+// readTemplates returns the package page templates with the ones found in
+// the templates directory (index.html and go-get.html) replacing the
+// built-in ones. Replacements can use the templates defined by the built-in
+// ones, like "decl".
+func readTemplates(opts *Options) (*pageTemplates, error) {
+	dir := opts.Templates
+	t := *defaultPages
+
+	if t.flat = opts.Layout == "flat"; !t.flat {
+		t.variants = opts.PageVariants
+	}
+
+	if dir == "" {
+		return &t, nil
+	}
+
+	for _, p := range []struct {
+		name string
+		tmpl **template.Template
+	}{
+		{"index.html", &t.page},
+		{goGetPage, &t.goGet},
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, p.name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		tmpl, err := (*p.tmpl).Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		if *p.tmpl, err = tmpl.Parse(string(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &t, nil
+}
+
+// write writes the HTML pages of the package.
+func (t *pageTemplates) write(out Output, pkg Package) error {
+	for i, name := range t.names(pkg) {
+		tmpl := variantTmpl
+
+		switch i {
+		case 0:
+			tmpl = t.page
+		case 1:
+			tmpl = t.goGet
+		}
+
+		if err := writeTemplate(out, name, tmpl, pkg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// names returns the names of the package pages: index.html, go-get.html
+// and the variants. Packages at the root of their host don't have variants.
+func (t *pageTemplates) names(pkg Package) []string {
+	names := []string{path.Join(pkg.ImportPath, "index.html")}
+	if t.flat {
+		return names
+	}
+
+	names = append(names, path.Join(pkg.ImportPath, goGetPage))
+
+	if strings.Contains(pkg.ImportPath, "/") {
+		for _, v := range t.variants {
+			names = append(names, pkg.ImportPath+pageVariants[v])
+		}
+	}
+
+	return names
+}
+
+// variantTmpl is the page of the variants, it has the meta tags for the Go
+// toolchain and redirects browsers to the package directory.
+var variantTmpl = template.Must(template.New("variant").Parse(`<!DOCTYPE html>
+<meta name="go-import" content="{{ .GoImport }}">
+<meta name="go-source" content="{{ .GoSource }}">
+<link rel="canonical" href="{{ .URL }}">
+<meta http-equiv="refresh" content="0; url={{ .URL }}">
+`))
+
+// writeStatic copies the files of dir into the site root, dot files are
+// skipped besides the .well-known directory.
+func (s *Site) writeStatic(dir string) error {
+	return filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if name != dir && d.Name()[0] == '.' && (d.Name() != wellKnownDir || filepath.Dir(name) != filepath.Clean(dir)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		return s.writeFile(filepath.ToSlash(rel), data)
+	})
+}
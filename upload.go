@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// uploaders sync the output directory to a storage service, by URL scheme.
+// Only changed files are uploaded, and remote files that don't exist
+// locally are deleted. External commands are run with the given runner.
+var uploaders = map[string]func(u *url.URL, dir string, c cmdRunner) error{
+	"azure": uploadAzure,
+	"gs":    uploadGCS,
+	"ssh":   uploadRsync,
+}
+
+// Upload syncs the output directory to the given storage URL.
+func Upload(rawURL, dir string, c cmdRunner) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	up, ok := uploaders[u.Scheme]
+	if !ok {
+		return fmt.Errorf("upload: unsupported URL %q", rawURL)
+	}
+
+	return up(u, dir, c)
+}
+
+// localFile is a file to upload, named by its slash separated path relative
+// to the output directory.
+type localFile struct {
+	Name         string
+	Path         string
+	MD5          []byte
+	Size         int64
+	ContentType  string
+	CacheControl string
+}
+
+func localFiles(dir string) ([]localFile, error) {
+	var files []localFile
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		h := md5.New()
+
+		n, err := io.Copy(h, f)
+		if err != nil {
+			return err
+		}
+
+		cacheControl := assetsCacheControl
+		if filepath.Ext(p) == ".html" {
+			cacheControl = pagesCacheControl
+		}
+
+		files = append(files, localFile{
+			Name:         filepath.ToSlash(rel),
+			Path:         p,
+			MD5:          h.Sum(nil),
+			Size:         n,
+			ContentType:  contentType(p),
+			CacheControl: cacheControl,
+		})
+
+		return nil
+	})
+
+	return files, err
+}
+
+// syncPlan returns the local files that are missing or changed in remote,
+// by MD5 checksum, and the remote names to delete. Remote names are relative
+// to prefix.
+func syncPlan(local []localFile, remote map[string]string, sum func([]byte) string) (upload []localFile, remove []string) {
+	names := make(map[string]bool, len(local))
+
+	for _, f := range local {
+		names[f.Name] = true
+
+		if remote[f.Name] != sum(f.MD5) {
+			upload = append(upload, f)
+		}
+	}
+
+	for name := range remote {
+		if !names[name] {
+			remove = append(remove, name)
+		}
+	}
+
+	sort.Strings(remove)
+
+	return upload, remove
+}
+
+// storagePrefix returns the object names prefix from the URL path.
+func storagePrefix(u *url.URL) string {
+	prefix := strings.Trim(u.Path, "/")
+	if prefix == "" {
+		return ""
+	}
+
+	return path.Clean(prefix) + "/"
+}
+
+// storageDo sends the request to a storage API, decoding the JSON response
+// into v if not nil.
+func storageDo(req *http.Request, v any) error {
+	req.Header.Set("User-Agent", "vanitic")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), res.Status, strings.TrimSpace(string(body)))
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
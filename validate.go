@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	metaTagRe  = regexp.MustCompile(`(?is)<meta\b[^>]*>`)
+	metaAttrRe = regexp.MustCompile(`(?is)([a-z][a-z0-9-]*)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+	// {dir}, {file}, {line} and the like in go-source templates.
+	sourcePlaceholderRe = regexp.MustCompile(`\{/?[a-z]+\}`)
+)
+
+var importVCS = []string{"bzr", "fossil", "git", "hg", "mod", "svn"}
+
+// validatePage checks the go-import and go-source meta tags of a generated
+// page: they must be well-formed, their prefix must contain the import path
+// of the page and their URLs must parse.
+func validatePage(name, importPath string, data []byte) error {
+	metas := goMetas(data)
+
+	imports := metas["go-import"]
+	if len(imports) != 1 {
+		return fmt.Errorf("%s: expected one go-import meta tag, found %d", name, len(imports))
+	}
+
+	fields := strings.Fields(imports[0])
+	if len(fields) != 3 && len(fields) != 4 {
+		return fmt.Errorf("%s: go-import %q: expected prefix, vcs, repository URL and optional subdirectory", name, imports[0])
+	}
+
+	prefix := fields[0]
+	if importPath != prefix && !strings.HasPrefix(importPath, prefix+"/") {
+		return fmt.Errorf("%s: go-import prefix %q doesn't contain the page import path", name, prefix)
+	}
+
+	if !slices.Contains(importVCS, fields[1]) {
+		return fmt.Errorf("%s: go-import: unknown vcs %q", name, fields[1])
+	}
+
+	if u, err := url.Parse(fields[2]); err != nil || u.Scheme == "" {
+		return fmt.Errorf("%s: go-import: invalid repository URL %q", name, fields[2])
+	}
+
+	sources := metas["go-source"]
+	if len(sources) > 1 {
+		return fmt.Errorf("%s: expected at most one go-source meta tag, found %d", name, len(sources))
+	}
+
+	for _, src := range sources {
+		fields := strings.Fields(src)
+		if len(fields) != 4 {
+			return fmt.Errorf("%s: go-source %q: expected prefix, home, directory and file URLs", name, src)
+		}
+
+		if fields[0] != prefix {
+			return fmt.Errorf("%s: go-source prefix %q doesn't match go-import prefix %q", name, fields[0], prefix)
+		}
+
+		for _, tmpl := range fields[1:] {
+			if tmpl == "_" {
+				continue
+			}
+
+			if u, err := url.Parse(sourcePlaceholderRe.ReplaceAllString(tmpl, "x")); err != nil || u.Scheme == "" {
+				return fmt.Errorf("%s: go-source: invalid URL template %q", name, tmpl)
+			}
+		}
+	}
+
+	return nil
+}
+
+// goMetas returns the content of the go-import and go-source meta tags of
+// the page, by name.
+func goMetas(data []byte) map[string][]string {
+	metas := make(map[string][]string)
+
+	for _, tag := range metaTagRe.FindAllString(string(data), -1) {
+		attrs := make(map[string]string)
+		for _, m := range metaAttrRe.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = html.UnescapeString(m[2] + m[3])
+		}
+
+		if n := attrs["name"]; n == "go-import" || n == "go-source" {
+			metas[n] = append(metas[n], attrs["content"])
+		}
+	}
+
+	return metas
+}
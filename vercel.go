@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// writeVercel writes a vercel.json file for deploying the site directory
+// as is.
+func writeVercel(s *Site) error {
+	type header struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+
+	type condition struct {
+		Type  string `json:"type"`
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+
+	type rule struct {
+		Source      string      `json:"source"`
+		Destination string      `json:"destination,omitempty"`
+		Has         []condition `json:"has,omitempty"`
+		Missing     []condition `json:"missing,omitempty"`
+		Permanent   *bool       `json:"permanent,omitempty"`
+		Headers     []header    `json:"headers,omitempty"`
+	}
+
+	var config struct {
+		CleanURLs     bool   `json:"cleanUrls"`
+		TrailingSlash bool   `json:"trailingSlash"`
+		Headers       []rule `json:"headers"`
+		Rewrites      []rule `json:"rewrites"`
+		Redirects     []rule `json:"redirects,omitempty"`
+	}
+
+	config.CleanURLs = true
+
+	headers := rule{Source: "/(.*)"}
+	for _, h := range securityHeaders {
+		headers.Headers = append(headers.Headers, header{h[0], h[1]})
+	}
+
+	headers.Headers = append(headers.Headers, header{"Cache-Control", pagesCacheControl})
+	config.Headers = []rule{headers}
+
+	goGet := []condition{{Type: "query", Key: "go-get", Value: "1"}}
+	permanent := false
+
+	for _, pkg := range s.Packages {
+		p := s.Path(pkg)
+
+		if !s.Flat {
+			config.Rewrites = append(config.Rewrites, rule{
+				Source:      p,
+				Destination: path.Join(p, strings.TrimSuffix(goGetPage, ".html")), // Clean URL.
+				Has:         goGet,
+			})
+		}
+
+		if s.DocsRedirect {
+			config.Redirects = append(config.Redirects, rule{
+				Source:      p,
+				Destination: "https://pkg.go.dev/" + pkg.ImportPath,
+				Missing:     goGet,
+				Permanent:   &permanent,
+			})
+		}
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return s.writeFile("vercel.json", append(data, '\n'))
+}
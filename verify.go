@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// verifyCmd checks a deployment: every package of the configuration must
+// answer go-get requests with the expected go-import tag, and its module
+// must resolve with the go command.
+func verifyCmd(args []string) error {
+	opts := DefaultOptions()
+	fset := opts.FlagSet("vanitic verify")
+
+	base := fset.String(
+		"base", "",
+		"Base URL of the deployment, like http://localhost:8080 (hosts are sent in the Host header). Defaults to https://<host>.",
+	)
+
+	resolve := fset.Bool(
+		"resolve", true,
+		"Check that modules resolve with go list -m <module>@latest.",
+	)
+
+	timeout := fset.Duration(
+		"timeout", 30*time.Second,
+		"Timeout of every request.",
+	)
+
+	if err := opts.Parse(fset, args); err != nil {
+		return err
+	}
+
+	g, err := NewGenerator(opts)
+	if err != nil {
+		return err
+	}
+
+	// The expected pages, without touching the output directory.
+	mem := NewMemFS()
+	if _, err := g.GenerateTo(mem); err != nil {
+		return err
+	}
+
+	st := g.state.Load()
+	client := &http.Client{Timeout: *timeout}
+	failed := 0
+
+	check := func(what string, err error) {
+		if err != nil {
+			log.Printf("FAIL %s: %v", what, err)
+			failed++
+		} else {
+			log.Printf("ok   %s", what)
+		}
+	}
+
+	for _, s := range g.sites(st, mem) {
+		for _, pkg := range s.Packages {
+			check(pkg.ImportPath, verifyGoGet(client, *base, s, pkg))
+		}
+
+		if !*resolve {
+			continue
+		}
+
+		for _, pkg := range s.Packages {
+			if pkg.IsModule() {
+				check(pkg.Module+"@latest", g.cmd.verifyResolve(st.env, pkg.Module))
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("verify: %d checks failed", failed)
+	}
+
+	return nil
+}
+
+func verifyGoGet(client *http.Client, base string, s *Site, pkg Package) error {
+	u := "https://" + s.Host
+	if base != "" {
+		u = strings.TrimSuffix(base, "/")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u+s.Path(pkg)+"?go-get=1", nil)
+	if err != nil {
+		return err
+	}
+
+	req.Host = s.Host
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	imports := goMetas(body)["go-import"]
+
+	switch {
+	case len(imports) == 0:
+		return errors.New("no go-import meta tag")
+	case len(imports) > 1:
+		return fmt.Errorf("%d go-import meta tags", len(imports))
+	case strings.Join(strings.Fields(imports[0]), " ") != pkg.GoImport():
+		return fmt.Errorf("go-import is %q, expected %q", imports[0], pkg.GoImport())
+	}
+
+	return nil
+}
+
+func (c cmdRunner) verifyResolve(env []string, modPath string) error {
+	// The go command error is written to stderr.
+	_, err := c.runCmdOutput(os.TempDir(), env, "go", "list", "-m", modPath+"@latest")
+	return err
+}
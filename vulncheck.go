@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+// Vuln is a known vulnerability affecting a module.
+type Vuln struct {
+	ID      string
+	Summary string
+	Called  bool // The vulnerable code is reachable from the module.
+}
+
+// URL returns the page of the vulnerability in the Go vulnerability
+// database.
+func (v Vuln) URL() string {
+	return "https://pkg.go.dev/vuln/" + v.ID
+}
+
+// runVulncheck runs govulncheck on the module packages and returns the
+// findings, called vulnerabilities first.
+func runVulncheck(mod *Module, env []string) ([]Vuln, error) {
+	output, err := mod.cmd.runCmdOutput(mod.Dir(), env, "govulncheck", "-format", "json", "./...")
+	if err != nil {
+		return nil, err
+	}
+
+	type frame struct {
+		Function string `json:"function"`
+	}
+
+	var msg struct {
+		OSV *struct {
+			ID      string `json:"id"`
+			Summary string `json:"summary"`
+		} `json:"osv"`
+
+		Finding *struct {
+			OSV   string  `json:"osv"`
+			Trace []frame `json:"trace"`
+		} `json:"finding"`
+	}
+
+	summaries := make(map[string]string)
+	found := make(map[string]*Vuln)
+
+	dec := json.NewDecoder(bytes.NewReader(output))
+
+	for {
+		msg.OSV, msg.Finding = nil, nil
+
+		if err := dec.Decode(&msg); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if msg.OSV != nil {
+			summaries[msg.OSV.ID] = msg.OSV.Summary
+		}
+
+		if f := msg.Finding; f != nil {
+			v, ok := found[f.OSV]
+			if !ok {
+				v = &Vuln{ID: f.OSV}
+				found[f.OSV] = v
+			}
+
+			if len(f.Trace) > 0 && f.Trace[0].Function != "" {
+				v.Called = true
+			}
+		}
+	}
+
+	vulns := make([]Vuln, 0, len(found))
+
+	for id, v := range found {
+		v.Summary = summaries[id]
+		vulns = append(vulns, *v)
+	}
+
+	sort.Slice(vulns, func(i, j int) bool {
+		if vulns[i].Called != vulns[j].Called {
+			return vulns[i].Called
+		}
+
+		return vulns[i].ID < vulns[j].ID
+	})
+
+	return vulns, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// watcher polls the files given to the generator (configuration, messages,
+// templates and static assets) for changes, directories are walked.
+type watcher struct {
+	paths []string
+	last  map[string]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func newWatcher(opts *Options) *watcher {
+	w := &watcher{}
+
+	for _, p := range []string{opts.Config, opts.Messages, opts.Templates, opts.Static} {
+		if p != "" {
+			w.paths = append(w.paths, p)
+		}
+	}
+
+	// Fragments of the teams when the watcher starts.
+	if cfg, err := parseConfigFile(opts.Config); err == nil {
+		for _, t := range cfg.Teams {
+			w.paths = append(w.paths, t.path(filepath.Dir(opts.Config)))
+		}
+	}
+
+	w.last = w.scan()
+
+	return w
+}
+
+func (w *watcher) scan() map[string]fileStamp {
+	files := make(map[string]fileStamp)
+
+	for _, p := range w.paths {
+		// Missing files are reported by generations, not here.
+		filepath.WalkDir(p, func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+
+			if fi, err := d.Info(); err == nil {
+				files[name] = fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+			}
+
+			return nil
+		})
+	}
+
+	return files
+}
+
+// changed reports whether any file was added, removed or modified since the
+// last call.
+func (w *watcher) changed() bool {
+	files := w.scan()
+	defer func() { w.last = files }()
+
+	if len(files) != len(w.last) {
+		return true
+	}
+
+	for name, st := range files {
+		if prev, ok := w.last[name]; !ok || prev != st {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Run calls fn after every change until stop is closed. Changes are checked
+// every interval.
+func (w *watcher) Run(stop <-chan struct{}, interval time.Duration, fn func()) {
+	log.Printf("watching %v for changes", w.paths)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+
+		if w.changed() {
+			fn()
+		}
+	}
+}
+
+// liveReload notifies browsers about regenerations with server-sent events,
+// pages get liveReloadScript injected in watch mode.
+type liveReload struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+const liveReloadPath = "/-/livereload"
+
+var liveReloadScript = []byte(`<script>new EventSource("` + liveReloadPath + `").onmessage = () => location.reload();</script>
+`)
+
+func newLiveReload() *liveReload {
+	return &liveReload{clients: make(map[chan struct{}]bool)}
+}
+
+func (l *liveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ch := make(chan struct{}, 1)
+
+	l.mu.Lock()
+	l.clients[ch] = true
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		delete(l.clients, ch)
+		l.mu.Unlock()
+	}()
+
+	// Events may come later than the server write timeout.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+		}
+
+		fmt.Fprint(w, "data: reload\n\n")
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// notify tells every connected browser to reload.
+func (l *liveReload) notify() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ch := range l.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
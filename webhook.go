@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func webhookCmd(args []string) error {
+	opts := DefaultOptions()
+	fset := opts.FlagSet("vanitic webhook")
+
+	addr := fset.String(
+		"addr", ":8080",
+		"TCP address to listen for webhooks.",
+	)
+
+	secretSrc := fset.String(
+		"secret", "",
+		"Webhook secret source (env:NAME or file:PATH).",
+	)
+
+	limits := DefaultLimits()
+	limits.AddFlags(fset)
+
+	if err := opts.Parse(fset, args); err != nil {
+		return err
+	}
+
+	if *secretSrc == "" {
+		return errors.New("webhook: missing secret")
+	}
+
+	secret, err := readSecret(*secretSrc)
+	if err != nil {
+		return err
+	}
+
+	g, err := NewGenerator(opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := g.Generate(); err != nil {
+		return err
+	}
+
+	log.Printf("listening for webhooks on %s", *addr)
+
+	return limits.Server(*addr, limits.Handler(NewWebhookHandler(g, secret))).ListenAndServe()
+}
+
+// NewWebhookHandler returns an HTTP handler for GitHub and GitLab push
+// webhooks, which regenerates the files of the pushed repository.
+func NewWebhookHandler(g *Generator, secret string) http.Handler {
+	return &webhookHandler{g: g, secret: []byte(secret)}
+}
+
+type webhookHandler struct {
+	g      *Generator
+	secret []byte
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if errors.As(err, new(*http.MaxBytesError)) {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, "can't read body", http.StatusBadRequest)
+		return
+	}
+
+	var urls []string
+
+	switch {
+	case r.Header.Get("X-GitHub-Event") != "":
+		urls, err = h.github(r, body)
+	case r.Header.Get("X-Gitlab-Event") != "":
+		urls, err = h.gitlab(r, body)
+	default:
+		err = errUnknownWebhook
+	}
+
+	switch {
+	case errors.Is(err, errInvalidSignature):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case urls == nil:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var repos []*Repo
+
+	for _, u := range urls {
+		if repos = h.g.ReposOf(u); repos != nil {
+			break
+		}
+	}
+
+	if repos == nil {
+		http.Error(w, "repository not configured", http.StatusNotFound)
+		return
+	}
+
+	go func() {
+		if _, err := h.g.GenerateRepo(repos...); err != nil {
+			log.Printf("webhook: %s: %v", repos[0].URL, err)
+			return
+		}
+
+		log.Printf("webhook: %s: regenerated", repos[0].URL)
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+var (
+	errInvalidSignature = errors.New("invalid signature")
+	errUnknownWebhook   = errors.New("unknown webhook")
+)
+
+// github validates a GitHub webhook and returns its repository URLs, or nil
+// if the event doesn't require regeneration.
+func (h *webhookHandler) github(r *http.Request, body []byte) ([]string, error) {
+	sig, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return nil, errInvalidSignature
+	}
+
+	want, err := hex.DecodeString(sig)
+	if err != nil {
+		return nil, errInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(body)
+
+	if !hmac.Equal(mac.Sum(nil), want) {
+		return nil, errInvalidSignature
+	}
+
+	if r.Header.Get("X-GitHub-Event") != "push" {
+		return nil, nil
+	}
+
+	var payload struct {
+		Repository struct {
+			HTMLURL  string `json:"html_url"`
+			CloneURL string `json:"clone_url"`
+		} `json:"repository"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	return []string{payload.Repository.HTMLURL, payload.Repository.CloneURL}, nil
+}
+
+// gitlab validates a GitLab webhook and returns its project URLs, or nil if
+// the event doesn't require regeneration.
+func (h *webhookHandler) gitlab(r *http.Request, body []byte) ([]string, error) {
+	token := []byte(r.Header.Get("X-Gitlab-Token"))
+
+	if subtle.ConstantTimeCompare(token, h.secret) != 1 {
+		return nil, errInvalidSignature
+	}
+
+	switch r.Header.Get("X-Gitlab-Event") {
+	case "Push Hook", "Tag Push Hook":
+	default:
+		return nil, nil
+	}
+
+	var payload struct {
+		Project struct {
+			WebURL     string `json:"web_url"`
+			GitHTTPURL string `json:"git_http_url"`
+		} `json:"project"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	return []string{payload.Project.WebURL, payload.Project.GitHTTPURL}, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// wellKnownDir is the directory of the site metadata files (RFC 8615), the
+// only dot directory published.
+const wellKnownDir = ".well-known"
+
+// securityFields are the fields of security.txt files (RFC 9116).
+var securityFields = map[string]bool{
+	"Acknowledgments":     true,
+	"Canonical":           true,
+	"Contact":             true,
+	"Encryption":          true,
+	"Expires":             true,
+	"Hiring":              true,
+	"Policy":              true,
+	"Preferred-Languages": true,
+}
+
+// parseSecurityField parses a security directive argument, "Field=value".
+func parseSecurityField(arg string) ([2]string, error) {
+	k, v, ok := strings.Cut(arg, "=")
+	if !ok || v == "" || !securityFields[k] {
+		return [2]string{}, fmt.Errorf("invalid security.txt field %q", arg)
+	}
+
+	if k == "Expires" {
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return [2]string{}, fmt.Errorf("invalid security.txt expiration %q, expected an RFC 3339 time", v)
+		}
+	}
+
+	return [2]string{k, v}, nil
+}
+
+// writeWellKnown writes the well-known files of the configuration into the
+// site: security.txt from the security directives, expiring in a year if
+// they don't set it, and the well-known directive files.
+func writeWellKnown(s *Site, cfg *Config) error {
+	if len(cfg.Security) > 0 {
+		var b strings.Builder
+
+		set := make(map[string]bool)
+
+		for _, f := range cfg.Security {
+			fmt.Fprintf(&b, "%s: %s\n", f[0], f[1])
+			set[f[0]] = true
+		}
+
+		if !set["Contact"] {
+			return errors.New("security.txt: missing Contact field")
+		}
+
+		if !set["Expires"] {
+			fmt.Fprintf(&b, "Expires: %s\n", time.Now().UTC().AddDate(1, 0, 0).Truncate(24*time.Hour).Format(time.RFC3339))
+		}
+
+		if err := s.writeFile(path.Join(wellKnownDir, "security.txt"), []byte(b.String())); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedKeys(cfg.WellKnown) {
+		data, err := os.ReadFile(cfg.WellKnown[name])
+		if err != nil {
+			return err
+		}
+
+		if err := s.writeFile(path.Join(wellKnownDir, name), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// zipsCmd writes the module zips of the tagged versions of every
+// configured module from the local checkouts into a directory with the
+// module proxy layout (see proxyHandler), so it can seed an internal proxy
+// or be used offline with GOPROXY=file:///<dir>. Existing zips are kept,
+// since tagged versions are immutable.
+func zipsCmd(args []string) error {
+	opts := DefaultOptions()
+	fset := opts.FlagSet("vanitic zips")
+
+	latest := fset.Bool(
+		"latest", false,
+		"Only write the latest version of every module.",
+	)
+
+	dryRun := fset.Bool(
+		"n", false,
+		"Only print the files of the module zips that would be written.",
+	)
+
+	if err := opts.Parse(fset, args); err != nil {
+		return err
+	}
+
+	if fset.NArg() != 1 {
+		return errors.New("zips: expected the output directory")
+	}
+
+	dir := fset.Arg(0)
+
+	g, err := NewGenerator(opts)
+	if err != nil {
+		return err
+	}
+
+	// The modules, without touching the output directory.
+	if _, err := g.GenerateTo(NewMemFS()); err != nil {
+		return err
+	}
+
+	g.modsMu.RLock()
+	mods := make([]*Module, 0, len(g.modules))
+	for _, m := range g.modules {
+		mods = append(mods, m)
+	}
+	g.modsMu.RUnlock()
+
+	sort.Slice(mods, func(i, j int) bool { return mods[i].Path < mods[j].Path })
+
+	n := 0
+
+	for _, m := range mods {
+		versions, err := m.Versions()
+		if err != nil {
+			return fmt.Errorf("zips: %s: %w", m.Path, err)
+		}
+
+		if *latest {
+			if v := latestSemver(versions); v != "" {
+				versions = []string{v}
+			} else {
+				versions = nil
+			}
+		}
+
+		if *dryRun {
+			for _, v := range versions {
+				if err := printModuleZip(m, v); err != nil {
+					return fmt.Errorf("zips: %s@%s: %w", m.Path, v, err)
+				}
+			}
+
+			continue
+		}
+
+		written, err := writeModuleVersions(dir, m, versions)
+		if err != nil {
+			return fmt.Errorf("zips: %s: %w", m.Path, err)
+		}
+
+		n += written
+	}
+
+	if !*dryRun {
+		log.Printf("zips: %d module zips written into %s", n, dir)
+	}
+
+	return nil
+}
+
+// writeModuleVersions writes the list file and the info, go.mod and zip
+// files of the given versions of the module, returning the number of zips
+// written.
+func writeModuleVersions(dir string, m *Module, versions []string) (int, error) {
+	vdir := filepath.Join(dir, filepath.FromSlash(escapeModulePath(m.Path)), "@v")
+	if err := os.MkdirAll(vdir, 0755); err != nil {
+		return 0, err
+	}
+
+	var list bytes.Buffer
+
+	n := 0
+
+	for _, v := range versions {
+		list.WriteString(v + "\n")
+
+		base := filepath.Join(vdir, escapeModulePath(v))
+		if _, err := os.Stat(base + ".zip"); err == nil {
+			continue
+		}
+
+		t, err := m.VersionTime(v)
+		if err != nil {
+			return n, err
+		}
+
+		info, err := json.Marshal(struct {
+			Version string
+			Time    time.Time
+		}{v, t})
+
+		if err != nil {
+			return n, err
+		}
+
+		mod, err := m.GoMod(v)
+		if err != nil {
+			// Modules without go.mod get a synthesized one.
+			mod = []byte("module " + m.Path + "\n")
+		}
+
+		var zbuf bytes.Buffer
+		if err := m.WriteZip(&zbuf, v); err != nil {
+			return n, fmt.Errorf("%s: %w", v, err)
+		}
+
+		if err := os.WriteFile(base+".info", append(info, '\n'), 0644); err != nil {
+			return n, err
+		}
+
+		if err := os.WriteFile(base+".mod", mod, 0644); err != nil {
+			return n, err
+		}
+
+		// The zip goes last, it marks the version as complete.
+		if err := os.WriteFile(base+".zip.tmp", zbuf.Bytes(), 0644); err != nil {
+			return n, err
+		}
+
+		if err := os.Rename(base+".zip.tmp", base+".zip"); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	return n, os.WriteFile(filepath.Join(vdir, "list"), list.Bytes(), 0644)
+}
+
+// printModuleZip prints the files of the module zip of the given version.
+func printModuleZip(m *Module, version string) error {
+	var buf bytes.Buffer
+	if err := m.WriteZip(&buf, version); err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		fmt.Println(f.Name)
+	}
+
+	return nil
+}